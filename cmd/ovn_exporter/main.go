@@ -1,95 +1,35 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	ovn "github.com/greenpau/ovn_exporter/pkg/ovn_exporter"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
 func main() {
-	var listenAddress string
-	var metricsPath string
-	var pollTimeout int
-	var pollInterval int
+	var configFile string
 	var isShowVersion bool
-	var logLevel string
-	var systemRunDir string
-	var databaseVswitchName string
-	var databaseVswitchSocketRemote string
-	var databaseVswitchFileDataPath string
-	var databaseVswitchFileLogPath string
-	var databaseVswitchFilePidPath string
-	var databaseVswitchFileSystemIDPath string
-	var databaseNorthboundName string
-	var databaseNorthboundSocketRemote string
-	var databaseNorthboundSocketControl string
-	var databaseNorthboundFileDataPath string
-	var databaseNorthboundFileLogPath string
-	var databaseNorthboundFilePidPath string
-	var databaseNorthboundPortDefault int
-	var databaseNorthboundPortSsl int
-	var databaseNorthboundPortRaft int
-	var databaseSouthboundName string
-	var databaseSouthboundSocketRemote string
-	var databaseSouthboundSocketControl string
-	var databaseSouthboundFileDataPath string
-	var databaseSouthboundFileLogPath string
-	var databaseSouthboundFilePidPath string
-	var databaseSouthboundPortDefault int
-	var databaseSouthboundPortSsl int
-	var databaseSouthboundPortRaft int
-	var serviceVswitchdFileLogPath string
-	var serviceVswitchdFilePidPath string
-	var serviceNorthdFileLogPath string
-	var serviceNorthdFilePidPath string
-
-	flag.StringVar(&listenAddress, "web.listen-address", ":9476", "Address to listen on for web interface and telemetry.")
-	flag.StringVar(&metricsPath, "web.telemetry-path", "/metrics", "Path under which to expose metrics.")
-	flag.IntVar(&pollTimeout, "ovn.timeout", 2, "Timeout on gRPC requests to OVN.")
-	flag.IntVar(&pollInterval, "ovn.poll-interval", 15, "The minimum interval (in seconds) between collections from OVN server.")
+	var once bool
+	var textfile string
+
+	flag.StringVar(&configFile, "config.file", "", "Path to a YAML configuration file. The flags override the settings of the file.")
 	flag.BoolVar(&isShowVersion, "version", false, "version information")
-	flag.StringVar(&logLevel, "log.level", "info", "logging severity level")
-
-	flag.StringVar(&systemRunDir, "system.run.dir", "/var/run/openvswitch", "OVS default run directory.")
-
-	flag.StringVar(&databaseVswitchName, "database.vswitch.name", "Open_vSwitch", "The name of OVS db.")
-	flag.StringVar(&databaseVswitchSocketRemote, "database.vswitch.socket.remote", "unix:/var/run/openvswitch/db.sock", "JSON-RPC unix socket to OVS db.")
-	flag.StringVar(&databaseVswitchFileDataPath, "database.vswitch.file.data.path", "/etc/openvswitch/conf.db", "OVS db file.")
-	flag.StringVar(&databaseVswitchFileLogPath, "database.vswitch.file.log.path", "/var/log/openvswitch/ovsdb-server.log", "OVS db log file.")
-	flag.StringVar(&databaseVswitchFilePidPath, "database.vswitch.file.pid.path", "/var/run/openvswitch/ovsdb-server.pid", "OVS db process id file.")
-	flag.StringVar(&databaseVswitchFileSystemIDPath, "database.vswitch.file.system.id.path", "/etc/openvswitch/system-id.conf", "OVS system id file.")
-
-	flag.StringVar(&databaseNorthboundName, "database.northbound.name", "OVN_Northbound", "The name of OVN NB (northbound) db.")
-	flag.StringVar(&databaseNorthboundSocketRemote, "database.northbound.socket.remote", "unix:/run/openvswitch/ovnnb_db.sock", "JSON-RPC unix socket to OVN NB db.")
-	flag.StringVar(&databaseNorthboundSocketControl, "database.northbound.socket.control", "unix:/run/openvswitch/ovnnb_db.ctl", "JSON-RPC unix socket to OVN NB app.")
-	flag.StringVar(&databaseNorthboundFileDataPath, "database.northbound.file.data.path", "/var/lib/openvswitch/ovnnb_db.db", "OVN NB db file.")
-	flag.StringVar(&databaseNorthboundFileLogPath, "database.northbound.file.log.path", "/var/log/openvswitch/ovsdb-server-nb.log", "OVN NB db log file.")
-	flag.StringVar(&databaseNorthboundFilePidPath, "database.northbound.file.pid.path", "/run/openvswitch/ovnnb_db.pid", "OVN NB db process id file.")
-	flag.IntVar(&databaseNorthboundPortDefault, "database.northbound.port.default", 6641, "OVN NB db network socket port.")
-	flag.IntVar(&databaseNorthboundPortSsl, "database.northbound.port.ssl", 6631, "OVN NB db network socket secure port.")
-	flag.IntVar(&databaseNorthboundPortRaft, "database.northbound.port.raft", 6643, "OVN NB db network port for clustering (raft)")
-
-	flag.StringVar(&databaseSouthboundName, "database.southbound.name", "OVN_Southbound", "The name of OVN SB (southbound) db.")
-	flag.StringVar(&databaseSouthboundSocketRemote, "database.southbound.socket.remote", "unix:/run/openvswitch/ovnsb_db.sock", "JSON-RPC unix socket to OVN SB db.")
-	flag.StringVar(&databaseSouthboundSocketControl, "database.southbound.socket.control", "unix:/run/openvswitch/ovnsb_db.ctl", "JSON-RPC unix socket to OVN SB app.")
-	flag.StringVar(&databaseSouthboundFileDataPath, "database.southbound.file.data.path", "/var/lib/openvswitch/ovnsb_db.db", "OVN SB db file.")
-	flag.StringVar(&databaseSouthboundFileLogPath, "database.southbound.file.log.path", "/var/log/openvswitch/ovsdb-server-sb.log", "OVN SB db log file.")
-	flag.StringVar(&databaseSouthboundFilePidPath, "database.southbound.file.pid.path", "/run/openvswitch/ovnsb_db.pid", "OVN SB db process id file.")
-	flag.IntVar(&databaseSouthboundPortDefault, "database.southbound.port.default", 6642, "OVN SB db network socket port.")
-	flag.IntVar(&databaseSouthboundPortSsl, "database.southbound.port.ssl", 6632, "OVN SB db network socket secure port.")
-	flag.IntVar(&databaseSouthboundPortRaft, "database.southbound.port.raft", 6644, "OVN SB db network port for clustering (raft)")
-
-	flag.StringVar(&serviceVswitchdFileLogPath, "service.vswitchd.file.log.path", "/var/log/openvswitch/ovs-vswitchd.log", "OVS vswitchd daemon log file.")
-	flag.StringVar(&serviceVswitchdFilePidPath, "service.vswitchd.file.pid.path", "/var/run/openvswitch/ovs-vswitchd.pid", "OVS vswitchd daemon process id file.")
-
-	flag.StringVar(&serviceNorthdFileLogPath, "service.ovn.northd.file.log.path", "/var/log/openvswitch/ovn-northd.log", "OVN northd daemon log file.")
-	flag.StringVar(&serviceNorthdFilePidPath, "service.ovn.northd.file.pid.path", "/run/openvswitch/ovn-northd.pid", "OVN northd daemon process id file.")
+	flag.BoolVar(&once, "once", false, "Collect once, write the metrics to --output.textfile and exit. The exit status is non-zero when ovn_up is 0.")
+	flag.StringVar(&textfile, "output.textfile", "", "Path to the file the metrics are written to with --once, for the textfile collector of node_exporter.")
+	bindFlags(flag.CommandLine, ovn.DefaultConfig())
 
 	var usageHelp = func() {
 		fmt.Fprintf(os.Stderr, "\n%s - Prometheus Exporter for Open Virtual Network (OVN)\n\n", ovn.GetExporterName())
@@ -110,7 +50,18 @@ func main() {
 		os.Exit(0)
 	}
 
-	logger, err := ovn.NewLogger(logLevel)
+	if once && textfile == "" {
+		fmt.Fprintf(os.Stderr, "--once requires --output.textfile\n")
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger, err := ovn.NewLogger(cfg.Log.Level)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed initializing logger: %v", err)
 		os.Exit(1)
@@ -124,8 +75,9 @@ func main() {
 	)
 
 	opts := ovn.Options{
-		Timeout: pollTimeout,
-		Logger:  logger,
+		Timeout:    cfg.OVN.Timeout,
+		Logger:     logger,
+		Collectors: cfg.CollectorStates(),
 	}
 
 	exporter, err := ovn.NewExporter(opts)
@@ -137,68 +89,107 @@ func main() {
 		os.Exit(1)
 	}
 
-	exporter.Client.System.RunDir = systemRunDir
-
-	exporter.Client.Database.Vswitch.Name = databaseVswitchName
-	exporter.Client.Database.Vswitch.Socket.Remote = databaseVswitchSocketRemote
-	exporter.Client.Database.Vswitch.File.Data.Path = databaseVswitchFileDataPath
-	exporter.Client.Database.Vswitch.File.Log.Path = databaseVswitchFileLogPath
-	exporter.Client.Database.Vswitch.File.Pid.Path = databaseVswitchFilePidPath
-	exporter.Client.Database.Vswitch.File.SystemID.Path = databaseVswitchFileSystemIDPath
-
-	exporter.Client.Database.Northbound.Name = databaseNorthboundName
-	exporter.Client.Database.Northbound.Socket.Remote = databaseNorthboundSocketRemote
-	exporter.Client.Database.Northbound.Socket.Control = databaseNorthboundSocketControl
-	exporter.Client.Database.Northbound.File.Data.Path = databaseNorthboundFileDataPath
-	exporter.Client.Database.Northbound.File.Log.Path = databaseNorthboundFileLogPath
-	exporter.Client.Database.Northbound.File.Pid.Path = databaseNorthboundFilePidPath
-	exporter.Client.Database.Northbound.Port.Default = databaseNorthboundPortDefault
-	exporter.Client.Database.Northbound.Port.Ssl = databaseNorthboundPortSsl
-	exporter.Client.Database.Northbound.Port.Raft = databaseNorthboundPortRaft
-
-	exporter.Client.Database.Southbound.Name = databaseSouthboundName
-	exporter.Client.Database.Southbound.Socket.Remote = databaseSouthboundSocketRemote
-	exporter.Client.Database.Southbound.Socket.Control = databaseSouthboundSocketControl
-	exporter.Client.Database.Southbound.File.Data.Path = databaseSouthboundFileDataPath
-	exporter.Client.Database.Southbound.File.Log.Path = databaseSouthboundFileLogPath
-	exporter.Client.Database.Southbound.File.Pid.Path = databaseSouthboundFilePidPath
-	exporter.Client.Database.Southbound.Port.Default = databaseSouthboundPortDefault
-	exporter.Client.Database.Southbound.Port.Ssl = databaseSouthboundPortSsl
-	exporter.Client.Database.Southbound.Port.Raft = databaseSouthboundPortRaft
-
-	exporter.Client.Service.Vswitchd.File.Log.Path = serviceVswitchdFileLogPath
-	exporter.Client.Service.Vswitchd.File.Pid.Path = serviceVswitchdFilePidPath
-
-	exporter.Client.Service.Northd.File.Log.Path = serviceNorthdFileLogPath
-	exporter.Client.Service.Northd.File.Pid.Path = serviceNorthdFilePidPath
-
-	exporter, err = ovn.ExporterPerformClientCalls(exporter)
-	if err != nil {
+	if err := exporter.ApplyConfig(cfg); err != nil {
 		level.Error(logger).Log(
-			"msg", "failed to finalize exporter calls properly",
-			"exporter_name", ovn.GetExporterName(),
+			"msg", "failed to apply configuration",
 			"error", err.Error(),
 		)
+		os.Exit(1)
+	}
+
+	if cfg.OVN.Offline {
+		level.Info(logger).Log(
+			"msg", "Reading the databases from their files",
+			"northbound", cfg.Database.Northbound.File.Data.Path,
+			"southbound", cfg.Database.Southbound.File.Data.Path,
+		)
+	} else {
+		exporter, err = ovn.ExporterPerformClientCalls(exporter)
+		if err != nil {
+			level.Error(logger).Log(
+				"msg", "failed to finalize exporter calls properly, retrying in background",
+				"exporter_name", ovn.GetExporterName(),
+				"error", err.Error(),
+			)
+		}
 	}
 
 	level.Info(logger).Log("ovs_system_id", exporter.Client.System.ID)
 
-	exporter.SetPollInterval(int64(pollInterval))
+	if once {
+		os.Exit(runOnce(exporter, textfile, logger))
+	}
+
+	go exporter.Run(context.Background())
+	go exporter.SuperviseConnections(context.Background())
 	prometheus.MustRegister(exporter)
 
-	http.Handle(metricsPath, promhttp.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(`<html>
-             <head><title>OVN Exporter</title></head>
-             <body>
-             <h1>OVN Exporter</h1>
-             <p><a href='` + metricsPath + `'>Metrics</a></p>
-             </body>
-             </html>`))
-	})
+	probeHandler, err := ovn.NewProbeHandler(cfg.Probe.Modules, cfg.OVN.Timeout, logger)
+	if err != nil {
+		level.Error(logger).Log(
+			"msg", "failed to init probe handler",
+			"error", err.Error(),
+		)
+		os.Exit(1)
+	}
 
-	level.Info(logger).Log("listen_on ", listenAddress)
-	if err := http.ListenAndServe(listenAddress, nil); err != nil {
+	pusher, err := ovn.NewPusher(cfg.Push, prometheus.DefaultGatherer, logger)
+	if err != nil {
+		level.Error(logger).Log(
+			"msg", "failed to init pusher",
+			"error", err.Error(),
+		)
+		os.Exit(1)
+	}
+	prometheus.MustRegister(pusher)
+	go pusher.Run(context.Background())
+
+	otlpExporter, err := ovn.NewOTLPExporter(cfg.OTLP, exporter, logger)
+	if err != nil {
+		level.Error(logger).Log(
+			"msg", "failed to init OTLP exporter",
+			"error", err.Error(),
+		)
+		os.Exit(1)
+	}
+	prometheus.MustRegister(otlpExporter)
+	go otlpExporter.Run(context.Background())
+
+	if configFile != "" {
+		go reloadOnSighup(configFile, cfg, exporter, probeHandler, pusher, otlpExporter, logger)
+	}
+
+	if cfg.Web.PprofAddress != "" {
+		go servePprof(cfg.Web.PprofAddress, logger)
+	}
+
+	mux := http.NewServeMux()
+	if !cfg.Web.DisableMetricsEndpoint {
+		mux.Handle(cfg.Web.TelemetryPath, promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			exporter.MetricsHandler(prometheus.DefaultGatherer),
+		))
+	}
+	mux.Handle(cfg.Web.ProbePath, probeHandler)
+	mux.Handle("/-/healthy", ovn.HealthyHandler())
+	mux.Handle("/-/ready", exporter.ReadyHandler())
+	mux.Handle("/api/v1/status", exporter.StatusHandler())
+	mux.Handle("/debug/snapshot", exporter.SnapshotHandler())
+	mux.Handle("/", exporter.StatusPageHandler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadTimeout:       cfg.Web.ReadTimeout,
+		ReadHeaderTimeout: cfg.Web.ReadTimeout,
+		WriteTimeout:      cfg.Web.WriteTimeout,
+	}
+	systemdSocket := false
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: &[]string{cfg.Web.ListenAddress},
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      &cfg.Web.ConfigFile,
+	}
+	if err := web.ListenAndServe(server, webFlags, logger); err != nil {
 		level.Error(logger).Log(
 			"msg", "listener failed",
 			"error", err.Error(),
@@ -206,3 +197,265 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runOnce collects the metrics once and writes them to the textfile. It
+// returns the exit status, which is non-zero when the file could not be
+// written or when OVN is down.
+func runOnce(exporter *ovn.Exporter, textfile string, logger log.Logger) int {
+	exporter.GatherMetrics()
+	if err := exporter.WriteTextfile(textfile); err != nil {
+		level.Error(logger).Log(
+			"msg", "failed writing textfile",
+			"output_textfile", textfile,
+			"error", err.Error(),
+		)
+		return 1
+	}
+	if !exporter.IsUp() {
+		level.Error(logger).Log(
+			"msg", "OVN is down",
+			"output_textfile", textfile,
+		)
+		return 1
+	}
+	return 0
+}
+
+// servePprof serves the profiling endpoints on a listener of their own,
+// so that they are not exposed on the public listener.
+func servePprof(address string, logger log.Logger) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	level.Info(logger).Log("msg", "serving pprof", "listen_on", address)
+	if err := http.ListenAndServe(address, mux); err != nil {
+		level.Error(logger).Log(
+			"msg", "pprof listener failed",
+			"error", err.Error(),
+		)
+	}
+}
+
+// loadConfig returns the configuration of the file, or the default one
+// when no file is given, overridden by the flags set on the command line.
+func loadConfig(path string) (*ovn.Config, error) {
+	cfg := ovn.DefaultConfig()
+	if path != "" {
+		var err error
+		cfg, err = ovn.LoadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	bindFlags(fs, cfg)
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if err != nil || fs.Lookup(f.Name) == nil {
+			return
+		}
+		err = fs.Set(f.Name, f.Value.String())
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// reloadOnSighup reloads the configuration file on SIGHUP. The settings of
+// the web listener and the logger require a restart. A reload is
+// all-or-nothing: the new configuration is applied only when every
+// component accepts it, and the current one is kept otherwise.
+func reloadOnSighup(path string, cfg *ovn.Config, exporter *ovn.Exporter, probeHandler *ovn.ProbeHandler, pusher *ovn.Pusher, otlpExporter *ovn.OTLPExporter, logger log.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		newCfg, err := loadConfig(path)
+		if err != nil {
+			level.Error(logger).Log(
+				"msg", "failed reloading configuration, keeping the current one",
+				"config_file", path,
+				"error", err.Error(),
+			)
+			continue
+		}
+		// The OTLP settings are prepared last, because their connection
+		// would have to be closed if a later step failed.
+		steps := []struct {
+			name    string
+			prepare func() (func(), error)
+		}{
+			{"exporter", func() (func(), error) { return exporter.PrepareConfig(newCfg) }},
+			{"probe", func() (func(), error) { return probeHandler.PrepareModules(newCfg.Probe.Modules, newCfg.OVN.Timeout) }},
+			{"push", func() (func(), error) { return pusher.PrepareConfig(newCfg.Push) }},
+			{"otlp", func() (func(), error) { return otlpExporter.PrepareConfig(newCfg.OTLP) }},
+		}
+		applies := []func(){}
+		for _, step := range steps {
+			apply, err := step.prepare()
+			if err != nil {
+				level.Error(logger).Log(
+					"msg", "failed reloading configuration, keeping the current one",
+					"config_file", path,
+					"section", step.name,
+					"error", err.Error(),
+				)
+				applies = nil
+				break
+			}
+			applies = append(applies, apply)
+		}
+		if len(applies) != len(steps) {
+			continue
+		}
+		if newCfg.Web != cfg.Web || newCfg.Log != cfg.Log {
+			level.Warn(logger).Log(
+				"msg", "web and log settings changed, the changes take effect after a restart",
+				"config_file", path,
+			)
+		}
+		for _, apply := range applies {
+			apply()
+		}
+		cfg = newCfg
+		level.Info(logger).Log(
+			"msg", "configuration reloaded",
+			"config_file", path,
+		)
+	}
+}
+
+// bindFlags defines the flags setting the configuration. The current
+// values of the configuration are the defaults of the flags.
+func bindFlags(fs *flag.FlagSet, cfg *ovn.Config) {
+	fs.StringVar(&cfg.Web.ListenAddress, "web.listen-address", cfg.Web.ListenAddress, "Address to listen on for web interface and telemetry.")
+	fs.StringVar(&cfg.Web.TelemetryPath, "web.telemetry-path", cfg.Web.TelemetryPath, "Path under which to expose metrics.")
+	fs.StringVar(&cfg.Web.ProbePath, "web.probe-path", cfg.Web.ProbePath, "Path under which to expose metrics of remote OVN databases.")
+	fs.StringVar(&cfg.Web.ConfigFile, "web.config.file", cfg.Web.ConfigFile, "Path to a web configuration file enabling TLS or basic authentication, in the Prometheus exporter-toolkit format.")
+	fs.DurationVar(&cfg.Web.ReadTimeout, "web.read-timeout", cfg.Web.ReadTimeout, "Maximum duration of reading a request.")
+	fs.DurationVar(&cfg.Web.WriteTimeout, "web.write-timeout", cfg.Web.WriteTimeout, "Maximum duration of writing a response.")
+	fs.StringVar(&cfg.Web.PprofAddress, "web.pprof-address", cfg.Web.PprofAddress, "Address to serve /debug/pprof on. Profiling is disabled when empty.")
+	fs.BoolVar(&cfg.Web.EnableDebugSnapshot, "web.enable-debug-snapshot", cfg.Web.EnableDebugSnapshot, "Serve the raw data of the last collection on /debug/snapshot.")
+	fs.BoolVar(&cfg.Web.CollectionTimestamps, "web.collection-timestamps", cfg.Web.CollectionTimestamps, "Stamp the samples with the time of the collection, rather than the time of the scrape.")
+	fs.BoolVar(&cfg.Web.DisableMetricsEndpoint, "web.disable-metrics-endpoint", cfg.Web.DisableMetricsEndpoint, "Do not serve the metrics on the telemetry path, when they are pushed or exported over OTLP instead.")
+	fs.StringVar(&cfg.Push.Mode, "push.mode", cfg.Push.Mode, "Push the metrics to a remote_write endpoint (remote_write) or a Pushgateway (pushgateway). Push mode is disabled when empty.")
+	fs.StringVar(&cfg.Push.URL, "push.url", cfg.Push.URL, "URL of the remote_write endpoint or of the Pushgateway.")
+	fs.DurationVar(&cfg.Push.Interval, "push.interval", cfg.Push.Interval, "Interval between pushes.")
+	fs.StringVar(&cfg.OTLP.Protocol, "otlp.protocol", cfg.OTLP.Protocol, "Export the metrics over OTLP/HTTP (http/protobuf) or OTLP/gRPC (grpc). The export is disabled when empty.")
+	fs.StringVar(&cfg.OTLP.Endpoint, "otlp.endpoint", cfg.OTLP.Endpoint, "URL of the OTLP/HTTP metrics endpoint, or host:port of the OTLP/gRPC collector.")
+	fs.DurationVar(&cfg.OTLP.Interval, "otlp.interval", cfg.OTLP.Interval, "Interval between OTLP exports.")
+	fs.BoolVar(&cfg.OTLP.Insecure, "otlp.insecure", cfg.OTLP.Insecure, "Connect to the OTLP/gRPC collector without TLS.")
+	fs.IntVar(&cfg.OVN.Timeout, "ovn.timeout", cfg.OVN.Timeout, "Timeout (in seconds) of each collection step and request to OVN.")
+	fs.IntVar(&cfg.OVN.PollInterval, "ovn.poll-interval", cfg.OVN.PollInterval, "The interval (in seconds) between the runs of the collectors without an interval of their own.")
+	fs.BoolVar(&cfg.OVN.Offline, "ovn.offline", cfg.OVN.Offline, "Read the NB and SB databases from their data files instead of querying the servers. Only the chassis, logical_switch, logical_switch_port, logical_router and load_balancer collectors run.")
+	fs.StringVar(&cfg.Log.Level, "log.level", cfg.Log.Level, "logging severity level")
+
+	fs.StringVar(&cfg.System.RunDir, "system.run.dir", cfg.System.RunDir, "OVS default run directory.")
+
+	fs.StringVar(&cfg.Database.Vswitch.Name, "database.vswitch.name", cfg.Database.Vswitch.Name, "The name of OVS db.")
+	fs.StringVar(&cfg.Database.Vswitch.Socket.Remote, "database.vswitch.socket.remote", cfg.Database.Vswitch.Socket.Remote, "JSON-RPC unix socket to OVS db.")
+	fs.StringVar(&cfg.Database.Vswitch.File.Data.Path, "database.vswitch.file.data.path", cfg.Database.Vswitch.File.Data.Path, "OVS db file.")
+	fs.StringVar(&cfg.Database.Vswitch.File.Log.Path, "database.vswitch.file.log.path", cfg.Database.Vswitch.File.Log.Path, "OVS db log file.")
+	fs.StringVar(&cfg.Database.Vswitch.File.Pid.Path, "database.vswitch.file.pid.path", cfg.Database.Vswitch.File.Pid.Path, "OVS db process id file.")
+	fs.StringVar(&cfg.Database.Vswitch.File.SystemID.Path, "database.vswitch.file.system.id.path", cfg.Database.Vswitch.File.SystemID.Path, "OVS system id file.")
+
+	fs.StringVar(&cfg.Database.Northbound.Name, "database.northbound.name", cfg.Database.Northbound.Name, "The name of OVN NB (northbound) db.")
+	fs.StringVar(&cfg.Database.Northbound.Socket.Remote, "database.northbound.socket.remote", cfg.Database.Northbound.Socket.Remote, "JSON-RPC unix socket to OVN NB db.")
+	fs.StringVar(&cfg.Database.Northbound.Socket.Control, "database.northbound.socket.control", cfg.Database.Northbound.Socket.Control, "JSON-RPC unix socket to OVN NB app.")
+	fs.StringVar(&cfg.Database.Northbound.File.Data.Path, "database.northbound.file.data.path", cfg.Database.Northbound.File.Data.Path, "OVN NB db file.")
+	fs.StringVar(&cfg.Database.Northbound.File.Log.Path, "database.northbound.file.log.path", cfg.Database.Northbound.File.Log.Path, "OVN NB db log file.")
+	fs.StringVar(&cfg.Database.Northbound.File.Pid.Path, "database.northbound.file.pid.path", cfg.Database.Northbound.File.Pid.Path, "OVN NB db process id file.")
+	fs.IntVar(&cfg.Database.Northbound.Port.Default, "database.northbound.port.default", cfg.Database.Northbound.Port.Default, "OVN NB db network socket port.")
+	fs.IntVar(&cfg.Database.Northbound.Port.Ssl, "database.northbound.port.ssl", cfg.Database.Northbound.Port.Ssl, "OVN NB db network socket secure port.")
+	fs.IntVar(&cfg.Database.Northbound.Port.Raft, "database.northbound.port.raft", cfg.Database.Northbound.Port.Raft, "OVN NB db network port for clustering (raft)")
+
+	fs.StringVar(&cfg.Database.Southbound.Name, "database.southbound.name", cfg.Database.Southbound.Name, "The name of OVN SB (southbound) db.")
+	fs.StringVar(&cfg.Database.Southbound.Socket.Remote, "database.southbound.socket.remote", cfg.Database.Southbound.Socket.Remote, "JSON-RPC unix socket to OVN SB db.")
+	fs.StringVar(&cfg.Database.Southbound.Socket.Control, "database.southbound.socket.control", cfg.Database.Southbound.Socket.Control, "JSON-RPC unix socket to OVN SB app.")
+	fs.StringVar(&cfg.Database.Southbound.File.Data.Path, "database.southbound.file.data.path", cfg.Database.Southbound.File.Data.Path, "OVN SB db file.")
+	fs.StringVar(&cfg.Database.Southbound.File.Log.Path, "database.southbound.file.log.path", cfg.Database.Southbound.File.Log.Path, "OVN SB db log file.")
+	fs.StringVar(&cfg.Database.Southbound.File.Pid.Path, "database.southbound.file.pid.path", cfg.Database.Southbound.File.Pid.Path, "OVN SB db process id file.")
+	fs.IntVar(&cfg.Database.Southbound.Port.Default, "database.southbound.port.default", cfg.Database.Southbound.Port.Default, "OVN SB db network socket port.")
+	fs.IntVar(&cfg.Database.Southbound.Port.Ssl, "database.southbound.port.ssl", cfg.Database.Southbound.Port.Ssl, "OVN SB db network socket secure port.")
+	fs.IntVar(&cfg.Database.Southbound.Port.Raft, "database.southbound.port.raft", cfg.Database.Southbound.Port.Raft, "OVN SB db network port for clustering (raft)")
+
+	fs.StringVar(&cfg.Service.Vswitchd.File.Log.Path, "service.vswitchd.file.log.path", cfg.Service.Vswitchd.File.Log.Path, "OVS vswitchd daemon log file.")
+	fs.StringVar(&cfg.Service.Vswitchd.File.Pid.Path, "service.vswitchd.file.pid.path", cfg.Service.Vswitchd.File.Pid.Path, "OVS vswitchd daemon process id file.")
+
+	fs.StringVar(&cfg.Service.Northd.File.Log.Path, "service.ovn.northd.file.log.path", cfg.Service.Northd.File.Log.Path, "OVN northd daemon log file.")
+	fs.StringVar(&cfg.Service.Northd.File.Pid.Path, "service.ovn.northd.file.pid.path", cfg.Service.Northd.File.Pid.Path, "OVN northd daemon process id file.")
+
+	for _, name := range ovn.GetCollectorNames() {
+		c := cfg.Collectors[name]
+		if c.Enabled == nil {
+			enabled := ovn.IsCollectorEnabledByDefault(name)
+			c.Enabled = &enabled
+			cfg.Collectors[name] = c
+		}
+		fs.BoolVar(c.Enabled, "collector."+name, *c.Enabled, fmt.Sprintf("Enable the %s collector.", name))
+		fs.Var(&negatedBoolFlag{value: c.Enabled}, "no-collector."+name, fmt.Sprintf("Disable the %s collector.", name))
+		fs.Var(&collectorIntFlag{collectors: cfg.Collectors, name: name, field: func(c *ovn.CollectorConfig) *int { return &c.Interval }},
+			"collector."+name+".interval", fmt.Sprintf("The interval (in seconds) between the runs of the %s collector. Defaults to the poll interval.", name))
+		fs.Var(&collectorIntFlag{collectors: cfg.Collectors, name: name, field: func(c *ovn.CollectorConfig) *int { return &c.CacheTTL }},
+			"collector."+name+".cache-ttl", fmt.Sprintf("How long (in seconds) the metrics of the last successful run of the %s collector are served while the later runs fail.", name))
+	}
+}
+
+// collectorIntFlag is an integer flag setting a field of the configuration
+// of a collector, e.g. -collector.chassis.interval.
+type collectorIntFlag struct {
+	collectors map[string]ovn.CollectorConfig
+	name       string
+	field      func(c *ovn.CollectorConfig) *int
+}
+
+func (f *collectorIntFlag) String() string {
+	if f.collectors == nil {
+		return "0"
+	}
+	c := f.collectors[f.name]
+	return strconv.Itoa(*f.field(&c))
+}
+
+func (f *collectorIntFlag) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	c := f.collectors[f.name]
+	*f.field(&c) = v
+	f.collectors[f.name] = c
+	return nil
+}
+
+// negatedBoolFlag is a boolean flag setting the value it points to
+// to the opposite of its own, e.g. -no-collector.chassis.
+type negatedBoolFlag struct {
+	value *bool
+}
+
+func (f *negatedBoolFlag) String() string {
+	if f.value == nil {
+		return "false"
+	}
+	return strconv.FormatBool(!*f.value)
+}
+
+func (f *negatedBoolFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*f.value = !v
+	return nil
+}
+
+func (f *negatedBoolFlag) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,437 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is the interface implemented by every OVN subsystem collector.
+type Collector interface {
+	// Update gathers the metrics of the subsystem. A non-nil error
+	// indicates that at least one of the underlying calls failed or
+	// did not complete before the context was done.
+	Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error)
+}
+
+// collectorEntry describes a collector known to the exporter. When a
+// critical collector fails, the OVN stack is reported as down. A blocking
+// collector completes before the other collectors start. A remote collector
+// only needs database connections, so /probe can run it against a remote
+// server. An offline collector can also read the database files, so it
+// runs in offline mode. The databases are the components whose database
+// the collector queries, and the control sockets tell whether it uses the
+// control sockets of the OVSDB servers. The readiness checks only require
+// those of the enabled collectors.
+type collectorEntry struct {
+	name           string
+	defaultEnabled bool
+	critical       bool
+	blocking       bool
+	remote         bool
+	offline        bool
+	databases      []string
+	controlSockets bool
+	factory        func() Collector
+}
+
+// collectorRegistry holds the available collectors. The process collector
+// is blocking, because the control sockets and network ports used by the
+// other collectors are derived from the process IDs it discovers.
+var collectorRegistry = []collectorEntry{
+	{name: "process", defaultEnabled: true, critical: true, blocking: true, factory: newProcessCollector},
+	{name: "logs", defaultEnabled: true, factory: newLogsCollector},
+	{name: "chassis", defaultEnabled: true, critical: true, remote: true, offline: true, databases: southboundDatabase, factory: newChassisCollector},
+	{name: "logical_switch", defaultEnabled: true, critical: true, remote: true, offline: true, databases: ovnDatabases, factory: newLogicalSwitchCollector},
+	{name: "logical_switch_port", defaultEnabled: true, critical: true, remote: true, offline: true, databases: ovnDatabases, factory: newLogicalSwitchPortCollector},
+	{name: "logical_router", defaultEnabled: true, remote: true, offline: true, databases: ovnDatabases, factory: newLogicalRouterCollector},
+	{name: "load_balancer", defaultEnabled: true, remote: true, offline: true, databases: ovnDatabases, factory: newLoadBalancerCollector},
+	{name: "coverage", defaultEnabled: true, controlSockets: true, factory: newCoverageCollector},
+	{name: "memory", defaultEnabled: true, controlSockets: true, factory: newMemoryCollector},
+	{name: "cluster", defaultEnabled: true, controlSockets: true, factory: newClusterCollector},
+	{name: "network_port", defaultEnabled: true, factory: newNetworkPortCollector},
+	{name: "server_status", remote: true, databases: ovnDatabases, factory: newServerStatusCollector},
+}
+
+// The databases queried by the collectors, by component.
+var (
+	southboundDatabase = []string{"ovsdb-server-southbound"}
+	ovnDatabases       = []string{"ovsdb-server-northbound", "ovsdb-server-southbound"}
+)
+
+// requiredComponents returns the components whose database and whose
+// control socket the named collectors use. The Open_vSwitch database holds
+// the system information, so it is always used.
+func requiredComponents(names []string) (map[string]bool, map[string]bool) {
+	databases := map[string]bool{"ovsdb-server": true}
+	controlSockets := map[string]bool{}
+	for _, name := range names {
+		entry, _ := getCollectorEntry(name)
+		for _, component := range entry.databases {
+			databases[component] = true
+		}
+		if entry.controlSockets {
+			for _, component := range appComponents {
+				controlSockets[component] = true
+			}
+		}
+	}
+	return databases, controlSockets
+}
+
+// namedCollector is an enabled instance of a collector. A zero interval
+// is the poll interval, and a zero cache lifetime serves the metrics of the
+// last run only.
+type namedCollector struct {
+	name      string
+	critical  bool
+	blocking  bool
+	interval  time.Duration
+	cacheTTL  time.Duration
+	collector Collector
+}
+
+// GetCollectorNames returns the names of all available collectors.
+func GetCollectorNames() []string {
+	names := []string{}
+	for _, entry := range collectorRegistry {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// IsCollectorEnabledByDefault returns true when the collector runs unless
+// explicitly disabled.
+func IsCollectorEnabledByDefault(name string) bool {
+	for _, entry := range collectorRegistry {
+		if entry.name == name {
+			return entry.defaultEnabled
+		}
+	}
+	return false
+}
+
+// newCollectors returns the enabled collectors. The states override the
+// default state of the collectors they reference.
+func newCollectors(states map[string]bool) ([]namedCollector, error) {
+	for name := range states {
+		if !isCollectorSupported(name) {
+			return nil, fmt.Errorf("unsupported collector: %s", name)
+		}
+	}
+	collectors := []namedCollector{}
+	for _, entry := range collectorRegistry {
+		enabled := entry.defaultEnabled
+		if state, exists := states[entry.name]; exists {
+			enabled = state
+		}
+		if !enabled {
+			continue
+		}
+		collectors = append(collectors, namedCollector{
+			name:      entry.name,
+			critical:  entry.critical,
+			blocking:  entry.blocking,
+			collector: entry.factory(),
+		})
+	}
+	return collectors, nil
+}
+
+// newRemoteCollectors returns the named collectors, which must all be
+// able to run against a remote server.
+func newRemoteCollectors(names []string) ([]namedCollector, error) {
+	collectors := []namedCollector{}
+	for _, name := range names {
+		entry, exists := getCollectorEntry(name)
+		if !exists {
+			return nil, fmt.Errorf("unsupported collector: %s", name)
+		}
+		if !entry.remote {
+			return nil, fmt.Errorf("collector %s does not support remote servers", name)
+		}
+		collectors = append(collectors, namedCollector{
+			name:      entry.name,
+			critical:  entry.critical,
+			collector: entry.factory(),
+		})
+	}
+	return collectors, nil
+}
+
+// offlineCollectors returns the collectors able to run in offline mode.
+func offlineCollectors(collectors []namedCollector) []namedCollector {
+	offline := []namedCollector{}
+	for _, c := range collectors {
+		if entry, _ := getCollectorEntry(c.name); entry.offline {
+			offline = append(offline, c)
+		}
+	}
+	return offline
+}
+
+func getCollectorEntry(name string) (collectorEntry, bool) {
+	for _, entry := range collectorRegistry {
+		if entry.name == name {
+			return entry, true
+		}
+	}
+	return collectorEntry{}, false
+}
+
+func isCollectorSupported(name string) bool {
+	for _, entry := range collectorRegistry {
+		if entry.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runStep runs a single call to OVN under a deadline derived from the
+// exporter's timeout. When the deadline passes, the step is reported as
+// failed and the collection carries on without it. The abandoned call keeps
+// running until it returns, so it must not share mutable state with the
+// caller. This is why the steps operate on client views.
+func (e *Exporter) runStep(ctx context.Context, step, component string, fn func() error) error {
+	timeout := e.getTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%s timed out after %s", step, timeout)
+		} else {
+			err = fmt.Errorf("%s cancelled: %s", step, ctx.Err())
+		}
+	}
+	if err != nil {
+		e.recentErrors.add(step, component, err)
+	}
+	return err
+}
+
+// runDatabaseStep runs a step querying OVSDB. The OVSDB client caches
+// schemas without synchronization, so the queries are serialized.
+func (e *Exporter) runDatabaseStep(ctx context.Context, step string, fn func() error) error {
+	select {
+	case e.databaseLock <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("%s cancelled while waiting for database: %s", step, ctx.Err())
+	}
+	return e.runStep(ctx, step, "", func() error {
+		defer func() { <-e.databaseLock }()
+		return fn()
+	})
+}
+
+// clientView returns a shallow copy of the OVN client. The client records
+// process IDs, log offsets and control socket paths in itself, so every
+// step works on its own view, and the steps discovering state merge it back.
+func (e *Exporter) clientView() *ovsdb.OvnClient {
+	e.clientLock.RLock()
+	defer e.clientLock.RUnlock()
+	cli := *e.Client
+	return &cli
+}
+
+// updateClient applies a change to the shared OVN client.
+func (e *Exporter) updateClient(fn func(cli *ovsdb.OvnClient)) {
+	e.clientLock.Lock()
+	defer e.clientLock.Unlock()
+	fn(e.Client)
+}
+
+// forEachComponent runs fn for each of the components concurrently and
+// combines the metrics and errors it returns.
+func forEachComponent(components []string, fn func(component string) ([]prometheus.Metric, error)) ([]prometheus.Metric, error) {
+	return runConcurrently(len(components), func(i int) ([]prometheus.Metric, error) {
+		return fn(components[i])
+	})
+}
+
+// runConcurrently runs fn n times concurrently and combines the metrics and
+// errors it returns.
+func runConcurrently(n int, fn func(i int) ([]prometheus.Metric, error)) ([]prometheus.Metric, error) {
+	type result struct {
+		metrics []prometheus.Metric
+		err     error
+	}
+	results := make([]result, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i].metrics, results[i].err = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	metrics := []prometheus.Metric{}
+	errs := []error{}
+	for _, r := range results {
+		metrics = append(metrics, r.metrics...)
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// appCommandCache holds the commands supported by the control socket of
+// each component. The coverage, memory and cluster collectors all depend on
+// the list, so it is fetched once per collection.
+type appCommandCache struct {
+	mu      sync.Mutex
+	results map[string]*appCommandsResult
+}
+
+type appCommandsResult struct {
+	once sync.Once
+	cmds map[string]bool
+	err  error
+}
+
+func newAppCommandCache() *appCommandCache {
+	return &appCommandCache{
+		results: make(map[string]*appCommandsResult),
+	}
+}
+
+func (c *appCommandCache) get(component string) *appCommandsResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.results[component]; !exists {
+		c.results[component] = &appCommandsResult{}
+	}
+	return c.results[component]
+}
+
+// getAppCommands returns the commands supported by the control socket of a
+// component.
+func (e *Exporter) getAppCommands(ctx context.Context, component string) (map[string]bool, error) {
+	r := e.appCommands.get(component)
+	r.once.Do(func() {
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() calls AppListCommands()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		src := e.dataSource()
+		var cmds map[string]bool
+		err := e.runStep(ctx, "AppListCommands()", component, func() error {
+			var err error
+			cmds, err = src.AppListCommands(component)
+			return err
+		})
+		if err != nil {
+			level.Error(e.logger).Log(
+				"msg", "AppListCommands() failed",
+				"component", component,
+				"system_id", e.Client.System.ID,
+				"error", err.Error(),
+			)
+			e.IncrementErrorCounter()
+			r.err = err
+			return
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() completed AppListCommands()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		r.cmds = cmds
+	})
+	return r.cmds, r.err
+}
+
+// appComponents are the components with a control socket supporting
+// the coverage, memory and clustering commands.
+var appComponents = []string{
+	"ovsdb-server",
+	"ovsdb-server-southbound",
+	"ovsdb-server-northbound",
+}
+
+// clusterStates records which databases are clustered. The network port
+// collector waits for the cluster collector to fill it in, because the raft
+// port is only checked for clustered databases.
+type clusterStates struct {
+	mu      sync.Mutex
+	enabled map[string]bool
+	ready   chan struct{}
+	once    sync.Once
+}
+
+func newClusterStates() *clusterStates {
+	return &clusterStates{
+		enabled: make(map[string]bool),
+		ready:   make(chan struct{}),
+	}
+}
+
+func (s *clusterStates) set(component string, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled[component] = enabled
+}
+
+func (s *clusterStates) done() {
+	s.once.Do(func() {
+		close(s.ready)
+	})
+}
+
+// isEnabled returns true when the database of the component is clustered.
+// It waits until the states are complete or the context is done.
+func (s *clusterStates) isEnabled(ctx context.Context, component string) bool {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled[component]
+}
+
+// getTimeout returns the deadline of a single step of a collection.
+func (e *Exporter) getTimeout() time.Duration {
+	timeout := atomic.LoadInt64(&e.timeout)
+	if timeout < 1 {
+		return 2 * time.Second
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// SetTimeout sets the timeout, in seconds, of each step of a collection.
+func (e *Exporter) SetTimeout(i int64) {
+	atomic.StoreInt64(&e.timeout, i)
+}
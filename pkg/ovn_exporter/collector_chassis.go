@@ -0,0 +1,73 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type chassisCollector struct{}
+
+func newChassisCollector() Collector {
+	return &chassisCollector{}
+}
+
+// Update implements Collector. It reports the state of OVN chassis.
+func (c *chassisCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetChassis()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var vteps []*ovsdb.OvnChassis
+	err := e.runDatabaseStep(ctx, "GetChassis()", func() error {
+		var err error
+		vteps, err = src.GetChassis()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("chassis", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetChassis() failed",
+			"southbound_db_name", e.Client.Database.Southbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("chassis", vteps, nil)
+	for _, vtep := range vteps {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			chassisInfo,
+			prometheus.GaugeValue,
+			float64(vtep.Up),
+			e.Client.System.ID,
+			vtep.UUID,
+			vtep.Name,
+			vtep.IPAddress.String(),
+		))
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetChassis()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
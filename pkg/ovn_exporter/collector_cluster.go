@@ -0,0 +1,288 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type clusterCollector struct{}
+
+func newClusterCollector() Collector {
+	return &clusterCollector{}
+}
+
+// Update implements Collector. It reports the raft clustering state of OVSDB
+// daemons. A daemon not participating in a cluster is not an error.
+func (c *clusterCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	defer e.clusterStates.done()
+	var mu sync.Mutex
+	clusterIDs := make(map[string]string)
+	metrics, err := forEachComponent(appComponents, func(component string) ([]prometheus.Metric, error) {
+		metrics := []prometheus.Metric{}
+		cmds, err := e.getAppCommands(ctx, component)
+		if err != nil {
+			return metrics, err
+		}
+		if !cmds["cluster/status DB"] {
+			return metrics, nil
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() calls GetAppClusteringInfo()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		src := e.dataSource()
+		var cluster ovsdb.ClusterState
+		err = e.runStep(ctx, "GetAppClusteringInfo()", component, func() error {
+			var err error
+			cluster, err = src.GetAppClusteringInfo(component)
+			return err
+		})
+		if err != nil {
+			e.rawData.recordComponent("cluster", component, nil, err)
+			e.clusterStates.set(component, false)
+			level.Error(e.logger).Log(
+				"msg", "GetAppClusteringInfo() failed",
+				"component", component,
+				"system_id", e.Client.System.ID,
+				"error", err.Error(),
+			)
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				clusterEnabled,
+				prometheus.GaugeValue,
+				0,
+				e.Client.System.ID,
+				component,
+			))
+		} else {
+			e.clusterStates.set(component, true)
+			e.rawData.recordComponent("cluster", component, cluster, nil)
+			mu.Lock()
+			clusterIDs[component] = cluster.ClusterID
+			mu.Unlock()
+			metrics = append(metrics, e.newClusterMetrics(e.Client.System.ID, component, cluster)...)
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() completed GetAppClusteringInfo()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		return metrics, nil
+	})
+	northClusterID := clusterIDs["ovsdb-server-northbound"]
+	southClusterID := clusterIDs["ovsdb-server-southbound"]
+	if northClusterID != "" && southClusterID != "" {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			clusterGroup,
+			prometheus.GaugeValue,
+			1,
+			e.Client.System.ID,
+			northClusterID+southClusterID,
+		))
+	}
+	return metrics, err
+}
+
+func (e *Exporter) newClusterMetrics(systemID, component string, cluster ovsdb.ClusterState) []prometheus.Metric {
+	metrics := []prometheus.Metric{}
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterEnabled,
+		prometheus.GaugeValue,
+		1,
+		systemID,
+		component,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterRole,
+		prometheus.GaugeValue,
+		float64(cluster.Role),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.UUID,
+		cluster.ClusterID,
+		cluster.ClusterUUID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterStatus,
+		prometheus.GaugeValue,
+		float64(cluster.Status),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	// The term is kept in the database file across restarts, and when it
+	// started counting is unknown.
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterTerm,
+		prometheus.CounterValue,
+		float64(cluster.Term),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterNotCommittedEntryCount,
+		prometheus.GaugeValue,
+		float64(cluster.NotCommittedEntries),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterNotAppliedEntryCount,
+		prometheus.GaugeValue,
+		float64(cluster.NotAppliedEntries),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterNextIndex,
+		prometheus.CounterValue,
+		float64(cluster.NextIndex),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterMatchIndex,
+		prometheus.CounterValue,
+		float64(cluster.MatchIndex),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterLogLowIndex,
+		prometheus.CounterValue,
+		float64(cluster.Log.Low),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterLogHighIndex,
+		prometheus.CounterValue,
+		float64(cluster.Log.High),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterLeaderSelf,
+		prometheus.GaugeValue,
+		float64(cluster.IsLeaderSelf),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterVoteSelf,
+		prometheus.GaugeValue,
+		float64(cluster.IsVotedSelf),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterPeerCount,
+		prometheus.GaugeValue,
+		float64(len(cluster.Peers)),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterPeerInConnTotal,
+		prometheus.GaugeValue,
+		float64(cluster.Connections.Inbound),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		clusterPeerOutConnTotal,
+		prometheus.GaugeValue,
+		float64(cluster.Connections.Outbound),
+		systemID,
+		component,
+		cluster.ID,
+		cluster.ClusterID,
+	))
+	for peerID, peer := range cluster.Peers {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			clusterPeerNextIndex,
+			prometheus.CounterValue,
+			float64(peer.NextIndex),
+			systemID,
+			component,
+			cluster.ID,
+			cluster.ClusterID,
+			peerID,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			clusterPeerMatchIndex,
+			prometheus.CounterValue,
+			float64(peer.MatchIndex),
+			systemID,
+			component,
+			cluster.ID,
+			cluster.ClusterID,
+			peerID,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			clusterPeerInConnInfo,
+			prometheus.GaugeValue,
+			float64(peer.Connection.Inbound),
+			systemID,
+			component,
+			cluster.ID,
+			cluster.ClusterID,
+			peerID,
+			peer.Address,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			clusterPeerOutConnInfo,
+			prometheus.GaugeValue,
+			float64(peer.Connection.Outbound),
+			systemID,
+			component,
+			cluster.ID,
+			cluster.ClusterID,
+			peerID,
+			peer.Address,
+		))
+	}
+	return metrics
+}
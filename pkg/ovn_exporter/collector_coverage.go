@@ -0,0 +1,99 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type coverageCollector struct{}
+
+func newCoverageCollector() Collector {
+	return &coverageCollector{}
+}
+
+// Update implements Collector. It reports the coverage counters of OVSDB
+// daemons.
+func (c *coverageCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	return forEachComponent(appComponents, func(component string) ([]prometheus.Metric, error) {
+		metrics := []prometheus.Metric{}
+		cmds, err := e.getAppCommands(ctx, component)
+		if err != nil {
+			return metrics, err
+		}
+		if !cmds["coverage/show"] {
+			return metrics, nil
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() calls GetAppCoverageMetrics()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		src := e.dataSource()
+		var events map[string]map[string]float64
+		err = e.runStep(ctx, "GetAppCoverageMetrics()", component, func() error {
+			var err error
+			events, err = src.GetAppCoverageMetrics(component)
+			return err
+		})
+		if err != nil {
+			e.rawData.recordComponent("coverage", component, nil, err)
+			level.Error(e.logger).Log(
+				"msg", "GetAppCoverageMetrics() failed",
+				"component", component,
+				"system_id", e.Client.System.ID,
+				"error", err.Error(),
+			)
+			e.IncrementErrorCounter()
+			return metrics, err
+		}
+		e.rawData.recordComponent("coverage", component, events, nil)
+		// The counters are reset when the daemon restarts.
+		started := e.processStartTime(ctx, src, component)
+		for event, metric := range events {
+			for period, value := range metric {
+				if period == "total" {
+					metrics = append(metrics, newCounter(
+						covTotal,
+						value,
+						started,
+						e.Client.System.ID,
+						component,
+						event,
+					))
+				} else {
+					metrics = append(metrics, prometheus.MustNewConstMetric(
+						covAvg,
+						prometheus.GaugeValue,
+						value,
+						e.Client.System.ID,
+						component,
+						event,
+						period,
+					))
+				}
+			}
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() completed GetAppCoverageMetrics()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		return metrics, nil
+	})
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type loadBalancerCollector struct{}
+
+func newLoadBalancerCollector() Collector {
+	return &loadBalancerCollector{}
+}
+
+// Update implements Collector. It reports the configuration of OVN load
+// balancers: their VIPs, the backends of each VIP, their health checks and
+// the rows they are attached to. It also reports the status of the
+// backends of the health checked VIPs.
+func (c *loadBalancerCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	lbs, metrics, err := c.updateLoadBalancers(ctx, e)
+	if err != nil {
+		return metrics, err
+	}
+	m, err := c.updateBackendStatus(ctx, e, lbs)
+	return append(metrics, m...), err
+}
+
+func (c *loadBalancerCollector) updateLoadBalancers(ctx context.Context, e *Exporter) ([]*LoadBalancer, []prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLoadBalancers()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var lbs []*LoadBalancer
+	err := e.runDatabaseStep(ctx, "GetLoadBalancers()", func() error {
+		var err error
+		lbs, err = src.GetLoadBalancers()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("load_balancers", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLoadBalancers() failed",
+			"northbound_db_name", e.Client.Database.Northbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return nil, metrics, err
+	}
+	e.rawData.record("load_balancers", lbs, nil)
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLoadBalancers()",
+		"system_id", e.Client.System.ID,
+	)
+	for _, lb := range lbs {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			lbInfo,
+			prometheus.GaugeValue,
+			1,
+			e.Client.System.ID,
+			lb.UUID,
+			lb.Name,
+			lb.Protocol,
+			strings.Join(lb.SelectionFields, ","),
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			lbVIPs,
+			prometheus.GaugeValue,
+			float64(len(lb.VIPs)),
+			e.Client.System.ID,
+			lb.UUID,
+			lb.Name,
+			lb.Protocol,
+		))
+		for vip, backends := range lb.VIPs {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				lbVIPBackends,
+				prometheus.GaugeValue,
+				float64(len(backends)),
+				e.Client.System.ID,
+				lb.UUID,
+				lb.Name,
+				vip,
+				lb.Protocol,
+			))
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			lbHealthChecks,
+			prometheus.GaugeValue,
+			float64(len(lb.HealthChecks)),
+			e.Client.System.ID,
+			lb.UUID,
+			lb.Name,
+		))
+		for _, attachment := range []struct {
+			kind  string
+			names []string
+		}{
+			{"logical_switch", lb.LogicalSwitches},
+			{"logical_router", lb.LogicalRouters},
+			{"load_balancer_group", lb.Groups},
+		} {
+			for _, name := range attachment.names {
+				metrics = append(metrics, prometheus.MustNewConstMetric(
+					lbAttachment,
+					prometheus.GaugeValue,
+					1,
+					e.Client.System.ID,
+					lb.UUID,
+					lb.Name,
+					attachment.kind,
+					name,
+				))
+			}
+		}
+	}
+	return lbs, metrics, nil
+}
+
+// updateBackendStatus reports the status of the backends of the health
+// checked VIPs. The service monitor of a backend is found by the logical
+// port of the backend in the ip_port_mappings of its load balancer. A
+// backend without service monitor is not reported, but ovn-northd does not
+// send traffic to it, so it is counted as unhealthy.
+func (c *loadBalancerCollector) updateBackendStatus(ctx context.Context, e *Exporter, lbs []*LoadBalancer) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetServiceMonitors()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var monitors []*ServiceMonitor
+	err := e.runDatabaseStep(ctx, "GetServiceMonitors()", func() error {
+		var err error
+		monitors, err = src.GetServiceMonitors()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("service_monitors", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetServiceMonitors() failed",
+			"southbound_db_name", e.Client.Database.Southbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("service_monitors", monitors, nil)
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetServiceMonitors()",
+		"system_id", e.Client.System.ID,
+	)
+	index := indexServiceMonitors(monitors)
+	for _, lb := range lbs {
+		for _, vip := range lb.HealthCheckVIPs {
+			backends, exists := lb.VIPs[vip]
+			if !exists {
+				continue
+			}
+			healthy, unhealthy := 0, 0
+			for _, backend := range backends {
+				ip, port, ok := splitLoadBalancerBackend(backend)
+				if !ok {
+					unhealthy++
+					continue
+				}
+				logicalPort, exists := lb.backendLogicalPort(ip)
+				if !exists {
+					unhealthy++
+					continue
+				}
+				monitor, exists := index[serviceMonitorKey(ip, port, lb.Protocol, logicalPort)]
+				if !exists {
+					unhealthy++
+					continue
+				}
+				status := 0
+				if monitor.IsOnline() {
+					status = 1
+					healthy++
+				} else {
+					unhealthy++
+				}
+				metrics = append(metrics, prometheus.MustNewConstMetric(
+					lbBackendStatus,
+					prometheus.GaugeValue,
+					float64(status),
+					e.Client.System.ID,
+					lb.UUID,
+					lb.Name,
+					vip,
+					ip,
+					strconv.FormatInt(port, 10),
+					lb.Protocol,
+					monitor.LogicalPort,
+				))
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				lbVIPBackendsHealthy,
+				prometheus.GaugeValue,
+				float64(healthy),
+				e.Client.System.ID,
+				lb.UUID,
+				lb.Name,
+				vip,
+				lb.Protocol,
+			))
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				lbVIPBackendsUnhealthy,
+				prometheus.GaugeValue,
+				float64(unhealthy),
+				e.Client.System.ID,
+				lb.UUID,
+				lb.Name,
+				vip,
+				lb.Protocol,
+			))
+		}
+	}
+	return metrics, nil
+}
@@ -0,0 +1,434 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// mainRouteTable is the label of the main routing table of a logical
+// router, as ovn-nbctl lr-route-list shows it.
+const mainRouteTable = "<main>"
+
+// routerPolicyActions are the actions of the routing policies, which are
+// reported even when no policy has them.
+var routerPolicyActions = []string{"allow", "drop", "reroute"}
+
+// natTypes are the types of the NAT rules, which are reported even when no
+// rule has them.
+var natTypes = []string{"snat", "dnat", "dnat_and_snat"}
+
+type logicalRouterCollector struct{}
+
+func newLogicalRouterCollector() Collector {
+	return &logicalRouterCollector{}
+}
+
+// Update implements Collector. It reports the inventory of OVN logical
+// routers, their ports, their routing tables and their NAT rules. The
+// ports, routes, policies and NAT rules are reported even when one of the
+// others fails.
+func (c *logicalRouterCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	routers, metrics, err := c.updateRouters(ctx, e)
+	if err != nil {
+		return metrics, err
+	}
+	errs := []error{}
+	for _, fn := range []func(context.Context, *Exporter, []*LogicalRouter) ([]prometheus.Metric, error){
+		c.updatePorts,
+		c.updateStaticRoutes,
+		c.updatePolicies,
+		c.updateNAT,
+	} {
+		m, err := fn(ctx, e, routers)
+		metrics = append(metrics, m...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func (c *logicalRouterCollector) updateRouters(ctx context.Context, e *Exporter) ([]*LogicalRouter, []prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalRouters()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var routers []*LogicalRouter
+	err := e.runDatabaseStep(ctx, "GetLogicalRouters()", func() error {
+		var err error
+		routers, err = src.GetLogicalRouters()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_routers", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalRouters() failed",
+			"northbound_db_name", e.Client.Database.Northbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return nil, metrics, err
+	}
+	e.rawData.record("logical_routers", routers, nil)
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalRouters()",
+		"system_id", e.Client.System.ID,
+	)
+	for _, router := range routers {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalRouterInfo,
+			prometheus.GaugeValue,
+			1,
+			e.Client.System.ID,
+			router.UUID,
+			router.Name,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalRouterPorts,
+			prometheus.GaugeValue,
+			float64(len(router.Ports)),
+			e.Client.System.ID,
+			router.UUID,
+		))
+		for k, v := range router.ExternalIDs {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalRouterExternalIDs,
+				prometheus.GaugeValue,
+				1,
+				e.Client.System.ID,
+				router.UUID,
+				k,
+				v,
+			))
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalRouterTunnelKey,
+			prometheus.GaugeValue,
+			float64(router.TunnelKey),
+			e.Client.System.ID,
+			router.UUID,
+		))
+	}
+	return routers, metrics, nil
+}
+
+func (c *logicalRouterCollector) updatePorts(ctx context.Context, e *Exporter, routers []*LogicalRouter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalRouterPorts()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var ports []*LogicalRouterPort
+	err := e.runDatabaseStep(ctx, "GetLogicalRouterPorts()", func() error {
+		var err error
+		ports, err = src.GetLogicalRouterPorts()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_router_ports", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalRouterPorts() failed",
+			"northbound_db_name", e.Client.Database.Northbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("logical_router_ports", ports, nil)
+	portRouters := make(map[string]string)
+	for _, router := range routers {
+		for _, p := range router.Ports {
+			portRouters[p] = router.Name
+		}
+	}
+	for _, port := range ports {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalRouterPortInfo,
+			prometheus.GaugeValue,
+			1,
+			e.Client.System.ID,
+			port.UUID,
+			port.Name,
+			portRouters[port.UUID],
+			port.MacAddress,
+			strings.Join(port.Networks, ","),
+			port.Peer,
+			strings.Join(port.GatewayChassis, ","),
+		))
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalRouterPorts()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
+
+// updateStaticRoutes reports the static routes of each router. The routes
+// with the same route table, policy and prefix form an ECMP group, and
+// each route is counted with the size of its group.
+func (c *logicalRouterCollector) updateStaticRoutes(ctx context.Context, e *Exporter, routers []*LogicalRouter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalRouterStaticRoutes()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var routes []*LogicalRouterStaticRoute
+	err := e.runDatabaseStep(ctx, "GetLogicalRouterStaticRoutes()", func() error {
+		var err error
+		routes, err = src.GetLogicalRouterStaticRoutes()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_router_static_routes", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalRouterStaticRoutes() failed",
+			"northbound_db_name", e.Client.Database.Northbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("logical_router_static_routes", routes, nil)
+	byUUID := make(map[string]*LogicalRouterStaticRoute)
+	for _, route := range routes {
+		byUUID[route.UUID] = route
+	}
+	type ecmpGroup struct {
+		routeTable, policy, prefix string
+	}
+	type routeCount struct {
+		routeTable, policy string
+		size               int
+	}
+	for _, router := range routers {
+		groups := make(map[ecmpGroup]int)
+		bfdRoutes := 0
+		defaultRoute := false
+		for _, id := range router.StaticRoutes {
+			route, exists := byUUID[id]
+			if !exists {
+				continue
+			}
+			routeTable := route.RouteTable
+			if routeTable == "" {
+				routeTable = mainRouteTable
+				if isDefaultRoute(route.IPPrefix) {
+					defaultRoute = true
+				}
+			}
+			groups[ecmpGroup{routeTable, route.Policy, route.IPPrefix}]++
+			if route.BFD {
+				bfdRoutes++
+			}
+		}
+		counts := make(map[routeCount]int)
+		for group, size := range groups {
+			counts[routeCount{group.routeTable, group.policy, size}] += size
+		}
+		for count, n := range counts {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalRouterStaticRoutes,
+				prometheus.GaugeValue,
+				float64(n),
+				e.Client.System.ID,
+				router.UUID,
+				count.routeTable,
+				count.policy,
+				strconv.Itoa(count.size),
+			))
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalRouterDefaultRoute,
+			prometheus.GaugeValue,
+			boolToFloat(defaultRoute),
+			e.Client.System.ID,
+			router.UUID,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalRouterBFDRoutes,
+			prometheus.GaugeValue,
+			float64(bfdRoutes),
+			e.Client.System.ID,
+			router.UUID,
+		))
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalRouterStaticRoutes()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
+
+func (c *logicalRouterCollector) updatePolicies(ctx context.Context, e *Exporter, routers []*LogicalRouter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalRouterPolicies()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var policies []*LogicalRouterPolicy
+	err := e.runDatabaseStep(ctx, "GetLogicalRouterPolicies()", func() error {
+		var err error
+		policies, err = src.GetLogicalRouterPolicies()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_router_policies", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalRouterPolicies() failed",
+			"northbound_db_name", e.Client.Database.Northbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("logical_router_policies", policies, nil)
+	actions := make(map[string]string)
+	for _, policy := range policies {
+		actions[policy.UUID] = policy.Action
+	}
+	for _, router := range routers {
+		counts := make(map[string]int)
+		for _, action := range routerPolicyActions {
+			counts[action] = 0
+		}
+		for _, id := range router.Policies {
+			if action, exists := actions[id]; exists {
+				counts[action]++
+			}
+		}
+		for action, n := range counts {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalRouterPolicies,
+				prometheus.GaugeValue,
+				float64(n),
+				e.Client.System.ID,
+				router.UUID,
+				action,
+			))
+		}
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalRouterPolicies()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
+
+// updateNAT reports the NAT rules of each router. Every dnat_and_snat rule
+// is reported as a floating IP.
+func (c *logicalRouterCollector) updateNAT(ctx context.Context, e *Exporter, routers []*LogicalRouter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalRouterNATs()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var nats []*LogicalRouterNAT
+	err := e.runDatabaseStep(ctx, "GetLogicalRouterNATs()", func() error {
+		var err error
+		nats, err = src.GetLogicalRouterNATs()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_router_nat", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalRouterNATs() failed",
+			"northbound_db_name", e.Client.Database.Northbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("logical_router_nat", nats, nil)
+	byUUID := make(map[string]*LogicalRouterNAT)
+	for _, nat := range nats {
+		byUUID[nat.UUID] = nat
+	}
+	for _, router := range routers {
+		types := make(map[string]int)
+		for _, t := range natTypes {
+			types[t] = 0
+		}
+		modes := map[string]int{"distributed": 0, "centralized": 0}
+		for _, id := range router.NAT {
+			nat, exists := byUUID[id]
+			if !exists {
+				continue
+			}
+			types[nat.Type]++
+			if nat.IsDistributed() {
+				modes["distributed"]++
+			} else {
+				modes["centralized"]++
+			}
+			if nat.Type != "dnat_and_snat" {
+				continue
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalRouterFloatingIPInfo,
+				prometheus.GaugeValue,
+				1,
+				e.Client.System.ID,
+				router.UUID,
+				nat.ExternalIP,
+				nat.LogicalIP,
+				nat.LogicalPort,
+				nat.ExternalMac,
+			))
+		}
+		for t, n := range types {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalRouterNATRules,
+				prometheus.GaugeValue,
+				float64(n),
+				e.Client.System.ID,
+				router.UUID,
+				t,
+			))
+		}
+		for mode, n := range modes {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalRouterNATRulesByMode,
+				prometheus.GaugeValue,
+				float64(n),
+				e.Client.System.ID,
+				router.UUID,
+				mode,
+			))
+		}
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalRouterNATs()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type logicalSwitchCollector struct{}
+
+func newLogicalSwitchCollector() Collector {
+	return &logicalSwitchCollector{}
+}
+
+// Update implements Collector. It reports the inventory of OVN logical
+// switches.
+func (c *logicalSwitchCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalSwitches()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var lsws []*ovsdb.OvnLogicalSwitch
+	err := e.runDatabaseStep(ctx, "GetLogicalSwitches()", func() error {
+		var err error
+		lsws, err = src.GetLogicalSwitches()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_switches", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalSwitches() failed",
+			"southbound_db_name", e.Client.Database.Southbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("logical_switches", lsws, nil)
+	for _, lsw := range lsws {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalSwitchInfo,
+			prometheus.GaugeValue,
+			1,
+			e.Client.System.ID,
+			lsw.UUID,
+			lsw.Name,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalSwitchPorts,
+			prometheus.GaugeValue,
+			float64(len(lsw.Ports)),
+			e.Client.System.ID,
+			lsw.UUID,
+		))
+		for _, p := range lsw.Ports {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalSwitchPortBinding,
+				prometheus.GaugeValue,
+				1,
+				e.Client.System.ID,
+				lsw.UUID,
+				p,
+			))
+		}
+		for k, v := range lsw.ExternalIDs {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				logicalSwitchExternalIDs,
+				prometheus.GaugeValue,
+				1,
+				e.Client.System.ID,
+				lsw.UUID,
+				k,
+				v,
+			))
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalSwitchTunnelKey,
+			prometheus.GaugeValue,
+			float64(lsw.TunnelKey),
+			e.Client.System.ID,
+			lsw.UUID,
+		))
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalSwitches()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type logicalSwitchPortCollector struct{}
+
+func newLogicalSwitchPortCollector() Collector {
+	return &logicalSwitchPortCollector{}
+}
+
+// Update implements Collector. It reports the inventory of OVN logical
+// switch ports.
+func (c *logicalSwitchPortCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetLogicalSwitchPorts()",
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var lswps []*ovsdb.OvnLogicalSwitchPort
+	err := e.runDatabaseStep(ctx, "GetLogicalSwitchPorts()", func() error {
+		var err error
+		lswps, err = src.GetLogicalSwitchPorts()
+		return err
+	})
+	if err != nil {
+		e.rawData.record("logical_switch_ports", nil, err)
+		level.Error(e.logger).Log(
+			"msg", "GetLogicalSwitchPorts() failed",
+			"southbound_db_name", e.Client.Database.Southbound.Name,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	e.rawData.record("logical_switch_ports", lswps, nil)
+	for _, port := range lswps {
+		macAddr := "<nil>"
+		ipAddr := "<nil>"
+
+		// Find first MAC address
+		for _, a := range port.Addresses {
+			if a.MacAddress != nil {
+				macAddr = a.MacAddress.String()
+				break
+			}
+		}
+
+		// Find first IP address
+		for _, a := range port.Addresses {
+			if len(a.IPAddresses) > 0 {
+				ipAddr = a.IPAddresses[0].String()
+				break
+			}
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalSwitchPortInfo,
+			prometheus.GaugeValue,
+			float64(1),
+			e.Client.System.ID,
+			port.UUID,
+			port.Name,
+			port.ChassisUUID,
+			port.LogicalSwitchName,
+			port.DatapathUUID,
+			port.PortBindingUUID,
+			macAddr,
+			ipAddr,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logicalSwitchPortTunnelKey,
+			prometheus.GaugeValue,
+			float64(port.TunnelKey),
+			e.Client.System.ID,
+			port.UUID,
+		))
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetLogicalSwitchPorts()",
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type logsCollector struct{}
+
+func newLogsCollector() Collector {
+	return &logsCollector{}
+}
+
+// Update implements Collector. It reports the size of the log files of OVN
+// components and the number of events recorded in them.
+func (c *logsCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	components := []string{
+		"ovsdb-server",
+		"ovsdb-server-southbound",
+		"ovsdb-server-northbound",
+		"ovn-northd",
+		"ovs-vswitchd",
+	}
+	return forEachComponent(components, func(component string) ([]prometheus.Metric, error) {
+		metrics := []prometheus.Metric{}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() calls GetLogFileInfo()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		src := e.dataSource()
+		var file ovsdb.OvsDataFile
+		var eventStats map[string]map[string]uint64
+		err := e.runStep(ctx, "GetLogFileInfo()", component, func() error {
+			var err error
+			file, err = src.GetLogFileInfo(component)
+			return err
+		})
+		if err != nil {
+			level.Error(e.logger).Log(
+				"msg", "GetLogFileInfo() failed",
+				"component", component,
+				"system_id", e.Client.System.ID,
+				"error", err.Error(),
+			)
+			e.IncrementErrorCounter()
+			return metrics, err
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() completed GetLogFileInfo()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			logFileSize,
+			prometheus.GaugeValue,
+			float64(file.Info.Size()),
+			e.Client.System.ID,
+			file.Component,
+			file.Path,
+		))
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() calls GetLogFileEventStats()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		err = e.runStep(ctx, "GetLogFileEventStats()", component, func() error {
+			var err error
+			eventStats, err = src.GetLogFileEventStats(component)
+			return err
+		})
+		if err != nil {
+			level.Error(e.logger).Log(
+				"msg", "GetLogFileEventStats() failed",
+				"component", component,
+				"system_id", e.Client.System.ID,
+				"error", err.Error(),
+			)
+			e.IncrementErrorCounter()
+			return metrics, err
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() completed GetLogFileEventStats()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		for sev, sources := range eventStats {
+			for source, count := range sources {
+				metrics = append(metrics, prometheus.MustNewConstMetric(
+					logEventStat,
+					prometheus.GaugeValue,
+					float64(count),
+					e.Client.System.ID,
+					component,
+					sev,
+					source,
+				))
+			}
+		}
+		return metrics, nil
+	})
+}
+
+// getLogFile returns the log file of a component. The file keeps the offset
+// up to which the events have been counted.
+func getLogFile(cli *ovsdb.OvnClient, component string) *ovsdb.OvsDataFile {
+	switch component {
+	case "ovsdb-server":
+		return &cli.Database.Vswitch.File.Log
+	case "ovsdb-server-southbound":
+		return &cli.Database.Southbound.File.Log
+	case "ovsdb-server-northbound":
+		return &cli.Database.Northbound.File.Log
+	case "ovn-northd":
+		return &cli.Service.Northd.File.Log
+	case "ovs-vswitchd":
+		return &cli.Service.Vswitchd.File.Log
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type memoryCollector struct{}
+
+func newMemoryCollector() Collector {
+	return &memoryCollector{}
+}
+
+// Update implements Collector. It reports the memory usage of OVSDB
+// daemons by facility.
+func (c *memoryCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	return forEachComponent(appComponents, func(component string) ([]prometheus.Metric, error) {
+		metrics := []prometheus.Metric{}
+		cmds, err := e.getAppCommands(ctx, component)
+		if err != nil {
+			return metrics, err
+		}
+		if !cmds["memory/show"] {
+			return metrics, nil
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() calls GetAppMemoryMetrics()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		src := e.dataSource()
+		var facilities map[string]float64
+		err = e.runStep(ctx, "GetAppMemoryMetrics()", component, func() error {
+			var err error
+			facilities, err = src.GetAppMemoryMetrics(component)
+			return err
+		})
+		if err != nil {
+			e.rawData.recordComponent("memory", component, nil, err)
+			level.Error(e.logger).Log(
+				"msg", "GetAppMemoryMetrics() failed",
+				"component", component,
+				"system_id", e.Client.System.ID,
+				"error", err.Error(),
+			)
+			e.IncrementErrorCounter()
+			return metrics, err
+		}
+		e.rawData.recordComponent("memory", component, facilities, nil)
+		for facility, value := range facilities {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				memUsage,
+				prometheus.GaugeValue,
+				value,
+				e.Client.System.ID,
+				component,
+				facility,
+			))
+		}
+		level.Debug(e.logger).Log(
+			"msg", "GatherMetrics() completed GetAppMemoryMetrics()",
+			"component", component,
+			"system_id", e.Client.System.ID,
+		)
+		return metrics, nil
+	})
+}
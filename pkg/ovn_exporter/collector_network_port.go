@@ -0,0 +1,107 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type networkPortCollector struct{}
+
+func newNetworkPortCollector() Collector {
+	return &networkPortCollector{}
+}
+
+// Update implements Collector. It reports whether the TCP ports of the OVN
+// databases are listening. The raft port is only checked for the databases
+// the cluster collector found to be clustered.
+func (c *networkPortCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	components := []string{
+		"ovsdb-server-southbound",
+		"ovsdb-server-northbound",
+	}
+	return forEachComponent(components, func(component string) ([]prometheus.Metric, error) {
+		metrics := []prometheus.Metric{}
+		errs := []error{}
+		usages := []string{"default", "ssl"}
+		if e.clusterStates.isEnabled(ctx, component) {
+			usages = append(usages, "raft")
+		}
+		for _, usage := range usages {
+			m, err := c.collect(ctx, e, component, usage)
+			metrics = append(metrics, m)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return metrics, errors.Join(errs...)
+	})
+}
+
+func (c *networkPortCollector) collect(ctx context.Context, e *Exporter, component, usage string) (prometheus.Metric, error) {
+	var step string
+	var fn func(src DataSource) (int, error)
+	switch usage {
+	case "ssl":
+		step, fn = "IsSslPortUp()", func(src DataSource) (int, error) { return src.IsSslPortUp(component) }
+	case "raft":
+		step, fn = "IsRaftPortUp()", func(src DataSource) (int, error) { return src.IsRaftPortUp(component) }
+	default:
+		step, fn = "IsDefaultPortUp()", func(src DataSource) (int, error) { return src.IsDefaultPortUp(component) }
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls "+step,
+		"component", component,
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var port int
+	err := e.runStep(ctx, step, component, func() error {
+		var err error
+		port, err = fn(src)
+		return err
+	})
+	// A step abandoned on timeout may still write port, so port is only
+	// read when the step returned.
+	var up int
+	if err == nil {
+		up = port
+	} else {
+		level.Error(e.logger).Log(
+			"msg", step+" failed",
+			"component", component,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed "+step,
+		"component", component,
+		"system_id", e.Client.System.ID,
+	)
+	return prometheus.MustNewConstMetric(
+		networkPortUp,
+		prometheus.GaugeValue,
+		float64(up),
+		e.Client.System.ID,
+		component,
+		usage,
+	), err
+}
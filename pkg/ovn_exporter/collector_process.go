@@ -0,0 +1,124 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type processCollector struct{}
+
+func newProcessCollector() Collector {
+	return &processCollector{}
+}
+
+// processComponentGroups are the components whose process information is
+// collected concurrently. The monitoring processes are looked up by the
+// parent process ID of their daemons, so the components of a group are
+// collected in order.
+var processComponentGroups = [][]string{
+	{"ovsdb-server"},
+	{"ovsdb-server-southbound", "ovsdb-server-southbound-monitoring"},
+	{"ovsdb-server-northbound", "ovsdb-server-northbound-monitoring"},
+	{"ovn-northd", "ovn-northd-monitoring"},
+	{"ovs-vswitchd"},
+}
+
+// Update implements Collector. It reports the process IDs of OVN components.
+func (c *processCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	return runConcurrently(len(processComponentGroups), func(i int) ([]prometheus.Metric, error) {
+		metrics := []prometheus.Metric{}
+		errs := []error{}
+		for _, component := range processComponentGroups[i] {
+			m, err := c.collect(ctx, e, component)
+			metrics = append(metrics, m)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return metrics, errors.Join(errs...)
+	})
+}
+
+func (c *processCollector) collect(ctx context.Context, e *Exporter, component string) (prometheus.Metric, error) {
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetProcessInfo()",
+		"component", component,
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var p ovsdb.OvsProcess
+	err := e.runStep(ctx, "GetProcessInfo()", component, func() error {
+		var err error
+		p, err = src.GetProcessInfo(component)
+		return err
+	})
+	// A step abandoned on timeout may still write p, so p is only read
+	// when the step returned.
+	var process ovsdb.OvsProcess
+	if err == nil {
+		process = p
+	} else {
+		level.Error(e.logger).Log(
+			"msg", "GetProcessInfo() failed",
+			"component", component,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetProcessInfo()",
+		"component", component,
+		"system_id", e.Client.System.ID,
+	)
+	return prometheus.MustNewConstMetric(
+		pid,
+		prometheus.GaugeValue,
+		float64(process.ID),
+		e.Client.System.ID,
+		component,
+		process.User,
+		process.Group,
+	), err
+}
+
+// mergeProcessInfo copies the process information of a component
+// discovered by GetProcessInfo() from one client to another.
+func mergeProcessInfo(dst, src *ovsdb.OvnClient, component string) {
+	switch component {
+	case "ovsdb-server":
+		dst.Database.Vswitch.Process = src.Database.Vswitch.Process
+	case "ovsdb-server-southbound":
+		dst.Database.Southbound.Process = src.Database.Southbound.Process
+	case "ovsdb-server-southbound-monitoring":
+		dst.Database.Southbound.Process.Parent.ID = src.Database.Southbound.Process.Parent.ID
+	case "ovsdb-server-northbound":
+		dst.Database.Northbound.Process = src.Database.Northbound.Process
+	case "ovsdb-server-northbound-monitoring":
+		dst.Database.Northbound.Process.Parent.ID = src.Database.Northbound.Process.Parent.ID
+	case "ovn-northd":
+		dst.Service.Northd.Process = src.Service.Northd.Process
+	case "ovn-northd-monitoring":
+		dst.Service.Northd.Process.Parent.ID = src.Service.Northd.Process.Parent.ID
+	case "ovs-vswitchd":
+		dst.Service.Vswitchd.Process = src.Service.Vswitchd.Process
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// serverDatabaseComponents maps the components serving OVN databases to
+// their databases.
+var serverDatabaseComponents = []struct {
+	component string
+	database  func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase
+}{
+	{"ovsdb-server-northbound", func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase { return &cli.Database.Northbound }},
+	{"ovsdb-server-southbound", func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase { return &cli.Database.Southbound }},
+}
+
+// getServerDatabase returns the database served by a component, or nil
+// when the component does not serve one.
+func getServerDatabase(cli *ovsdb.OvnClient, component string) *ovsdb.OvsDatabase {
+	for _, s := range serverDatabaseComponents {
+		if s.component == component {
+			return s.database(cli)
+		}
+	}
+	return nil
+}
+
+type serverStatusCollector struct{}
+
+func newServerStatusCollector() Collector {
+	return &serverStatusCollector{}
+}
+
+// Update implements Collector. It reports the status of the OVN databases
+// as seen by their servers in the _Server database. It only needs the
+// database connection, so it also works for remote servers.
+func (c *serverStatusCollector) Update(ctx context.Context, e *Exporter) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	errs := []error{}
+	found := false
+	for _, s := range serverDatabaseComponents {
+		m, err := c.collect(ctx, e, s.component, s.database(e.clientView()).Name)
+		if errors.Is(err, ErrNotConnected) {
+			continue
+		}
+		found = true
+		metrics = append(metrics, m...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if !found {
+		return metrics, fmt.Errorf("no database connection")
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func (c *serverStatusCollector) collect(ctx context.Context, e *Exporter, component, dbName string) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() calls GetServerStatus()",
+		"component", component,
+		"system_id", e.Client.System.ID,
+	)
+	src := e.dataSource()
+	var databases []ServerDatabase
+	err := e.runDatabaseStep(ctx, "GetServerStatus()", func() error {
+		var err error
+		databases, err = src.GetServerDatabases(component)
+		return err
+	})
+	if errors.Is(err, ErrNotConnected) {
+		return metrics, err
+	}
+	if err != nil {
+		level.Error(e.logger).Log(
+			"msg", "GetServerStatus() failed",
+			"component", component,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		e.IncrementErrorCounter()
+		return metrics, err
+	}
+	for _, db := range databases {
+		if db.Name != dbName {
+			continue
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			serverDatabaseConnected,
+			prometheus.GaugeValue,
+			boolToFloat(db.Connected),
+			e.Client.System.ID,
+			component,
+			db.Name,
+			db.Model,
+			db.ClusterID,
+			db.ServerID,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			serverDatabaseLeader,
+			prometheus.GaugeValue,
+			boolToFloat(db.Leader),
+			e.Client.System.ID,
+			component,
+			db.Name,
+			db.ClusterID,
+			db.ServerID,
+		))
+	}
+	level.Debug(e.logger).Log(
+		"msg", "GatherMetrics() completed GetServerStatus()",
+		"component", component,
+		"system_id", e.Client.System.ID,
+	)
+	return metrics, nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewCollectors(t *testing.T) {
+	testcases := []struct {
+		name      string
+		states    map[string]bool
+		want      []string
+		shouldErr bool
+	}{
+		{
+			name:   "default collectors",
+			states: nil,
+			want:   []string{"process", "logs", "chassis", "logical_switch", "logical_switch_port", "logical_router", "load_balancer", "coverage", "memory", "cluster", "network_port"},
+		},
+		{
+			name:   "enable server status collector",
+			states: map[string]bool{"server_status": true},
+			want:   []string{"process", "logs", "chassis", "logical_switch", "logical_switch_port", "logical_router", "load_balancer", "coverage", "memory", "cluster", "network_port", "server_status"},
+		},
+		{
+			name: "disable per-port collectors",
+			states: map[string]bool{
+				"logical_switch_port": false,
+				"network_port":        false,
+			},
+			want: []string{"process", "logs", "chassis", "logical_switch", "logical_router", "load_balancer", "coverage", "memory", "cluster"},
+		},
+		{
+			name: "chassis node",
+			states: map[string]bool{
+				"chassis":             false,
+				"logical_switch":      false,
+				"logical_switch_port": false,
+				"logical_router":      false,
+				"load_balancer":       false,
+				"cluster":             true,
+			},
+			want: []string{"process", "logs", "coverage", "memory", "cluster", "network_port"},
+		},
+		{
+			name:      "unsupported collector",
+			states:    map[string]bool{"foo": true},
+			shouldErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			collectors, err := newCollectors(tc.states)
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err)
+			}
+			got := []string{}
+			for _, c := range collectors {
+				got = append(got, c.name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewRemoteCollectors(t *testing.T) {
+	testcases := []struct {
+		name      string
+		names     []string
+		shouldErr bool
+	}{
+		{name: "remote collectors", names: []string{"chassis", "server_status"}},
+		{name: "local collector", names: []string{"chassis", "process"}, shouldErr: true},
+		{name: "unsupported collector", names: []string{"foo"}, shouldErr: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			collectors, err := newRemoteCollectors(tc.names)
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err)
+			}
+			if len(collectors) != len(tc.names) {
+				t.Errorf("expected %d collectors, but got %d", len(tc.names), len(collectors))
+			}
+		})
+	}
+}
+
+func TestRunStepTimeout(t *testing.T) {
+	e := newTestExporter(t)
+	e.SetTimeout(1)
+	release := make(chan struct{})
+	defer close(release)
+
+	startedAt := time.Now()
+	err := e.runStep(context.Background(), "Hang()", "ovsdb-server", func() error {
+		<-release
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, but got %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 3*time.Second {
+		t.Errorf("expected step to be abandoned after 1s, but took %s", elapsed)
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	components := []string{"a", "b", "c"}
+	ready := make(chan struct{})
+	var started int32
+	metrics, err := forEachComponent(components, func(component string) ([]prometheus.Metric, error) {
+		// Every component waits for the others, so the test only
+		// completes when they run concurrently.
+		if atomic.AddInt32(&started, 1) == int32(len(components)) {
+			close(ready)
+		}
+		<-ready
+		if component == "b" {
+			return nil, errors.New("failed")
+		}
+		return []prometheus.Metric{
+			prometheus.MustNewConstMetric(up, prometheus.GaugeValue, 1),
+		}, nil
+	})
+	if len(metrics) != 2 {
+		t.Errorf("expected 2 metrics, but got %d", len(metrics))
+	}
+	if err == nil {
+		t.Errorf("expected error, but got none")
+	}
+}
+
+func TestRequiredComponents(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		collectors []string
+		// The required components, in the order of appComponents.
+		databases      []string
+		controlSockets []string
+	}{
+		{
+			name:       "chassis node",
+			collectors: []string{"process", "logs", "network_port"},
+			databases:  []string{"ovsdb-server"},
+		},
+		{
+			name:       "chassis collector",
+			collectors: []string{"chassis"},
+			databases:  []string{"ovsdb-server", "ovsdb-server-southbound"},
+		},
+		{
+			name:           "control sockets",
+			collectors:     []string{"coverage"},
+			databases:      []string{"ovsdb-server"},
+			controlSockets: []string{"ovsdb-server", "ovsdb-server-southbound", "ovsdb-server-northbound"},
+		},
+		{
+			name:           "default collectors",
+			databases:      []string{"ovsdb-server", "ovsdb-server-southbound", "ovsdb-server-northbound"},
+			controlSockets: []string{"ovsdb-server", "ovsdb-server-southbound", "ovsdb-server-northbound"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			names := tc.collectors
+			if names == nil {
+				collectors, err := newCollectors(nil)
+				if err != nil {
+					t.Fatalf("expected no error, but got %q", err)
+				}
+				names = collectorNames(collectors)
+			}
+			databases, controlSockets := requiredComponents(names)
+			for _, want := range []struct {
+				kind       string
+				got        map[string]bool
+				components []string
+			}{
+				{"database", databases, tc.databases},
+				{"control socket", controlSockets, tc.controlSockets},
+			} {
+				required := []string{}
+				for _, component := range appComponents {
+					if want.got[component] {
+						required = append(required, component)
+					}
+				}
+				if strings.Join(required, ",") != strings.Join(want.components, ",") {
+					t.Errorf("expected required %s %v, but got %v", want.kind, want.components, required)
+				}
+			}
+		})
+	}
+}
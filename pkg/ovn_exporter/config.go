@@ -0,0 +1,488 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/exporter-toolkit/web"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the configuration of the exporter. It is loaded from a YAML
+// file and covers the settings of the command-line flags.
+type Config struct {
+	Web        WebConfig                  `yaml:"web"`
+	Log        LogConfig                  `yaml:"log"`
+	OVN        OVNConfig                  `yaml:"ovn"`
+	System     SystemConfig               `yaml:"system"`
+	Database   DatabasesConfig            `yaml:"database"`
+	Service    ServicesConfig             `yaml:"service"`
+	Collectors map[string]CollectorConfig `yaml:"collectors"`
+	Probe      ProbeConfig                `yaml:"probe"`
+	Push       PushConfig                 `yaml:"push"`
+	OTLP       OTLPConfig                 `yaml:"otlp"`
+}
+
+// WebConfig holds the settings of the HTTP listener. The TLS and basic
+// authentication settings are in a separate file, in the format of the
+// Prometheus exporter-toolkit.
+type WebConfig struct {
+	ListenAddress string        `yaml:"listen_address"`
+	TelemetryPath string        `yaml:"telemetry_path"`
+	ProbePath     string        `yaml:"probe_path"`
+	ConfigFile    string        `yaml:"config_file,omitempty"`
+	ReadTimeout   time.Duration `yaml:"read_timeout"`
+	WriteTimeout  time.Duration `yaml:"write_timeout"`
+	PprofAddress  string        `yaml:"pprof_address,omitempty"`
+	// EnableDebugSnapshot serves the raw data of the last collection on
+	// /debug/snapshot.
+	EnableDebugSnapshot bool `yaml:"enable_debug_snapshot"`
+	// CollectionTimestamps stamps the samples with the time of the
+	// collection, rather than the time of the scrape.
+	CollectionTimestamps bool `yaml:"collection_timestamps"`
+	// DisableMetricsEndpoint stops serving the metrics on the telemetry
+	// path, when they are pushed or exported instead.
+	DisableMetricsEndpoint bool `yaml:"disable_metrics_endpoint"`
+}
+
+// LogConfig holds the logging settings.
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// OVNConfig holds the settings of the collections.
+type OVNConfig struct {
+	Timeout      int `yaml:"timeout"`
+	PollInterval int `yaml:"poll_interval"`
+	// Offline reads the Northbound and Southbound databases from their
+	// data files instead of querying the servers.
+	Offline bool `yaml:"offline"`
+}
+
+// SystemConfig holds the settings of the OVS system.
+type SystemConfig struct {
+	RunDir string `yaml:"run_dir"`
+}
+
+// DatabasesConfig holds the settings of the OVS and OVN databases.
+type DatabasesConfig struct {
+	Vswitch    DatabaseConfig `yaml:"vswitch"`
+	Northbound DatabaseConfig `yaml:"northbound"`
+	Southbound DatabaseConfig `yaml:"southbound"`
+}
+
+// DatabaseConfig holds the settings of a database.
+type DatabaseConfig struct {
+	Name   string `yaml:"name"`
+	Socket struct {
+		Remote  string `yaml:"remote"`
+		Control string `yaml:"control,omitempty"`
+	} `yaml:"socket"`
+	File struct {
+		Data     FileConfig `yaml:"data"`
+		Log      FileConfig `yaml:"log"`
+		Pid      FileConfig `yaml:"pid"`
+		SystemID FileConfig `yaml:"system_id,omitempty"`
+	} `yaml:"file"`
+	Port struct {
+		Default int `yaml:"default,omitempty"`
+		Ssl     int `yaml:"ssl,omitempty"`
+		Raft    int `yaml:"raft,omitempty"`
+	} `yaml:"port,omitempty"`
+}
+
+// ServicesConfig holds the settings of the OVS and OVN daemons.
+type ServicesConfig struct {
+	Vswitchd ServiceConfig `yaml:"vswitchd"`
+	Northd   ServiceConfig `yaml:"northd"`
+}
+
+// ServiceConfig holds the settings of a daemon.
+type ServiceConfig struct {
+	File struct {
+		Log FileConfig `yaml:"log"`
+		Pid FileConfig `yaml:"pid"`
+	} `yaml:"file"`
+}
+
+// FileConfig holds the path to a file.
+type FileConfig struct {
+	Path string `yaml:"path"`
+}
+
+// CollectorConfig holds the settings of a collector. A collector without
+// the enabled setting keeps its default state.
+type CollectorConfig struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Interval is the interval, in seconds, between the runs of the
+	// collector. It defaults to the poll interval.
+	Interval int `yaml:"interval,omitempty"`
+	// CacheTTL is how long, in seconds, the metrics of the last successful
+	// run are served while the later runs fail. By default, the metrics of
+	// the last run are served.
+	CacheTTL int `yaml:"cache_ttl,omitempty"`
+}
+
+// ProbeConfig holds the settings of the /probe endpoint.
+type ProbeConfig struct {
+	Modules map[string]ProbeModule `yaml:"modules"`
+}
+
+// DefaultConfig returns the configuration used when neither a file nor
+// flags override it.
+func DefaultConfig() *Config {
+	cfg := &Config{}
+	cfg.Web.ListenAddress = ":9476"
+	cfg.Web.TelemetryPath = "/metrics"
+	cfg.Web.ProbePath = "/probe"
+	cfg.Web.ReadTimeout = 10 * time.Second
+	cfg.Web.WriteTimeout = time.Minute
+	cfg.Log.Level = "info"
+	cfg.OVN.Timeout = 2
+	cfg.OVN.PollInterval = defaultPollInterval
+	cfg.System.RunDir = "/var/run/openvswitch"
+
+	cfg.Database.Vswitch.Name = "Open_vSwitch"
+	cfg.Database.Vswitch.Socket.Remote = "unix:/var/run/openvswitch/db.sock"
+	cfg.Database.Vswitch.File.Data.Path = "/etc/openvswitch/conf.db"
+	cfg.Database.Vswitch.File.Log.Path = "/var/log/openvswitch/ovsdb-server.log"
+	cfg.Database.Vswitch.File.Pid.Path = "/var/run/openvswitch/ovsdb-server.pid"
+	cfg.Database.Vswitch.File.SystemID.Path = "/etc/openvswitch/system-id.conf"
+
+	cfg.Database.Northbound.Name = "OVN_Northbound"
+	cfg.Database.Northbound.Socket.Remote = "unix:/run/openvswitch/ovnnb_db.sock"
+	cfg.Database.Northbound.Socket.Control = "unix:/run/openvswitch/ovnnb_db.ctl"
+	cfg.Database.Northbound.File.Data.Path = "/var/lib/openvswitch/ovnnb_db.db"
+	cfg.Database.Northbound.File.Log.Path = "/var/log/openvswitch/ovsdb-server-nb.log"
+	cfg.Database.Northbound.File.Pid.Path = "/run/openvswitch/ovnnb_db.pid"
+	cfg.Database.Northbound.Port.Default = 6641
+	cfg.Database.Northbound.Port.Ssl = 6631
+	cfg.Database.Northbound.Port.Raft = 6643
+
+	cfg.Database.Southbound.Name = "OVN_Southbound"
+	cfg.Database.Southbound.Socket.Remote = "unix:/run/openvswitch/ovnsb_db.sock"
+	cfg.Database.Southbound.Socket.Control = "unix:/run/openvswitch/ovnsb_db.ctl"
+	cfg.Database.Southbound.File.Data.Path = "/var/lib/openvswitch/ovnsb_db.db"
+	cfg.Database.Southbound.File.Log.Path = "/var/log/openvswitch/ovsdb-server-sb.log"
+	cfg.Database.Southbound.File.Pid.Path = "/run/openvswitch/ovnsb_db.pid"
+	cfg.Database.Southbound.Port.Default = 6642
+	cfg.Database.Southbound.Port.Ssl = 6632
+	cfg.Database.Southbound.Port.Raft = 6644
+
+	cfg.Service.Vswitchd.File.Log.Path = "/var/log/openvswitch/ovs-vswitchd.log"
+	cfg.Service.Vswitchd.File.Pid.Path = "/var/run/openvswitch/ovs-vswitchd.pid"
+	cfg.Service.Northd.File.Log.Path = "/var/log/openvswitch/ovn-northd.log"
+	cfg.Service.Northd.File.Pid.Path = "/run/openvswitch/ovn-northd.pid"
+
+	cfg.Collectors = make(map[string]CollectorConfig)
+	for _, name := range GetCollectorNames() {
+		enabled := IsCollectorEnabledByDefault(name)
+		cfg.Collectors[name] = CollectorConfig{Enabled: &enabled}
+	}
+	cfg.Probe.Modules = DefaultProbeModules()
+	cfg.Push.Interval = 30 * time.Second
+	cfg.Push.Timeout = 10 * time.Second
+	cfg.Push.Job = GetExporterName()
+	cfg.Push.BufferSize = 10
+	cfg.OTLP.Interval = 30 * time.Second
+	cfg.OTLP.Timeout = 10 * time.Second
+	return cfg
+}
+
+// LoadConfigFile reads the configuration from a YAML file. The settings
+// missing from the file keep their default values.
+func LoadConfigFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading config file: %s", err)
+	}
+	cfg, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig parses the configuration from YAML and validates it.
+func ParseConfig(b []byte) (*Config, error) {
+	cfg := DefaultConfig()
+	// The maps are filled in after decoding, because the decoder rejects
+	// the keys already set. The probe modules of a file replace the
+	// default modules.
+	cfg.Collectors = nil
+	cfg.Probe.Modules = nil
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Collectors == nil {
+		cfg.Collectors = make(map[string]CollectorConfig)
+	}
+	for _, name := range GetCollectorNames() {
+		if c := cfg.Collectors[name]; c.Enabled == nil {
+			enabled := IsCollectorEnabledByDefault(name)
+			c.Enabled = &enabled
+			cfg.Collectors[name] = c
+		}
+	}
+	if cfg.Probe.Modules == nil {
+		cfg.Probe.Modules = DefaultProbeModules()
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration. The error lists every invalid
+// setting.
+func (cfg *Config) Validate() error {
+	errs := []string{}
+	addErr := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	switch cfg.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		addErr("log.level", "unsupported level %q, expected debug, info, warn or error", cfg.Log.Level)
+	}
+	if cfg.Web.ListenAddress == "" {
+		addErr("web.listen_address", "must not be empty")
+	}
+	if cfg.Web.PprofAddress != "" && cfg.Web.PprofAddress == cfg.Web.ListenAddress {
+		addErr("web.pprof_address", "must differ from web.listen_address")
+	}
+	if err := web.Validate(cfg.Web.ConfigFile); err != nil {
+		addErr("web.config_file", "%s", err)
+	}
+	if cfg.Web.ReadTimeout < 0 {
+		addErr("web.read_timeout", "must not be negative, got %s", cfg.Web.ReadTimeout)
+	}
+	if cfg.Web.WriteTimeout < 0 {
+		addErr("web.write_timeout", "must not be negative, got %s", cfg.Web.WriteTimeout)
+	}
+	for key, path := range map[string]string{
+		"web.telemetry_path": cfg.Web.TelemetryPath,
+		"web.probe_path":     cfg.Web.ProbePath,
+	} {
+		if !strings.HasPrefix(path, "/") {
+			addErr(key, "must start with /, got %q", path)
+		}
+	}
+	if cfg.OVN.Timeout < 1 {
+		addErr("ovn.timeout", "must be at least 1 second, got %d", cfg.OVN.Timeout)
+	}
+	if cfg.OVN.PollInterval < 1 {
+		addErr("ovn.poll_interval", "must be at least 1 second, got %d", cfg.OVN.PollInterval)
+	}
+	if cfg.System.RunDir == "" {
+		addErr("system.run_dir", "must not be empty")
+	}
+	for key, db := range map[string]DatabaseConfig{
+		"database.vswitch":    cfg.Database.Vswitch,
+		"database.northbound": cfg.Database.Northbound,
+		"database.southbound": cfg.Database.Southbound,
+	} {
+		if db.Name == "" {
+			addErr(key+".name", "must not be empty")
+		}
+		if db.Socket.Remote == "" {
+			addErr(key+".socket.remote", "must not be empty")
+		}
+		if db.Socket.Control != "" && !strings.HasPrefix(db.Socket.Control, "unix:") {
+			addErr(key+".socket.control", "must be a unix socket, got %q", db.Socket.Control)
+		}
+		for name, port := range map[string]int{
+			"default": db.Port.Default,
+			"ssl":     db.Port.Ssl,
+			"raft":    db.Port.Raft,
+		} {
+			if port < 0 || port > 65535 {
+				addErr(key+".port."+name, "must be between 1 and 65535, or 0 for the default, got %d", port)
+			}
+		}
+	}
+	if cfg.OVN.Offline {
+		for key, path := range map[string]string{
+			"database.northbound.file.data.path": cfg.Database.Northbound.File.Data.Path,
+			"database.southbound.file.data.path": cfg.Database.Southbound.File.Data.Path,
+		} {
+			if path == "" {
+				addErr(key, "must not be empty in offline mode")
+			}
+		}
+	}
+	for name, c := range cfg.Collectors {
+		if !isCollectorSupported(name) {
+			addErr("collectors", "unsupported collector %q", name)
+			continue
+		}
+		if c.Interval < 0 {
+			addErr("collectors."+name+".interval", "must not be negative, got %d", c.Interval)
+		}
+		if c.CacheTTL < 0 {
+			addErr("collectors."+name+".cache_ttl", "must not be negative, got %d", c.CacheTTL)
+		}
+	}
+	for name, module := range cfg.Probe.Modules {
+		if err := module.Validate(); err != nil {
+			addErr("probe.modules."+name, "%s", err)
+		}
+	}
+	for _, err := range cfg.Push.Validate() {
+		errs = append(errs, "push."+err)
+	}
+	for _, err := range cfg.OTLP.Validate() {
+		errs = append(errs, "otlp."+err)
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// CollectorStates returns the enabled state of the configured collectors.
+func (cfg *Config) CollectorStates() map[string]bool {
+	states := make(map[string]bool)
+	for name, c := range cfg.Collectors {
+		if c.Enabled != nil {
+			states[name] = *c.Enabled
+		}
+	}
+	return states
+}
+
+// apply copies the settings of the database to the client.
+func (c DatabaseConfig) apply(db *ovsdb.OvsDatabase) {
+	db.Name = c.Name
+	db.Socket.Remote = c.Socket.Remote
+	if c.Socket.Control != "" {
+		db.Socket.Control = c.Socket.Control
+	}
+	db.File.Data.Path = c.File.Data.Path
+	db.File.Log.Path = c.File.Log.Path
+	db.File.Pid.Path = c.File.Pid.Path
+	if c.File.SystemID.Path != "" {
+		db.File.SystemID.Path = c.File.SystemID.Path
+	}
+	if c.Port.Default != 0 {
+		db.Port.Default = c.Port.Default
+	}
+	if c.Port.Ssl != 0 {
+		db.Port.Ssl = c.Port.Ssl
+	}
+	if c.Port.Raft != 0 {
+		db.Port.Raft = c.Port.Raft
+	}
+}
+
+// ApplyConfig applies the configuration to the exporter. A collection in
+// progress completes with the previous configuration. The settings of the
+// web listener and the logger are not applied. In offline mode, only the
+// collectors able to read the database files run.
+func (e *Exporter) ApplyConfig(cfg *Config) error {
+	apply, err := e.PrepareConfig(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// PrepareConfig checks that the configuration can be applied to the
+// exporter, and returns the function applying it, which cannot fail.
+func (e *Exporter) PrepareConfig(cfg *Config) (func(), error) {
+	collectors, err := newCollectors(cfg.CollectorStates())
+	if err != nil {
+		return nil, err
+	}
+	return func() { e.applyConfig(cfg, collectors) }, nil
+}
+
+func (e *Exporter) applyConfig(cfg *Config, collectors []namedCollector) {
+	e.Lock()
+	defer e.Unlock()
+	e.config.Store(cfg)
+	if cfg.OVN.Offline {
+		collectors = offlineCollectors(collectors)
+		if offline := e.offline.Load(); offline == nil || !offline.sameFiles(cfg.Database.Northbound, cfg.Database.Southbound) {
+			e.offline.Store(newOfflineDatabases(cfg.Database.Northbound, cfg.Database.Southbound))
+		}
+	} else {
+		e.offline.Store(nil)
+	}
+	for i, c := range collectors {
+		collectors[i].interval = time.Duration(cfg.Collectors[c.name].Interval) * time.Second
+		collectors[i].cacheTTL = time.Duration(cfg.Collectors[c.name].CacheTTL) * time.Second
+	}
+	// The sections of the disabled collectors are no longer served.
+	for name := range e.sections {
+		if name == "system_info" {
+			continue
+		}
+		enabled := false
+		for _, c := range collectors {
+			enabled = enabled || c.name == name
+		}
+		if !enabled {
+			delete(e.sections, name)
+		}
+	}
+	e.collectors = collectors
+	e.SetTimeout(int64(cfg.OVN.Timeout))
+	e.SetPollInterval(int64(cfg.OVN.PollInterval))
+	e.SetDebugSnapshot(cfg.Web.EnableDebugSnapshot)
+	e.SetCollectionTimestamps(cfg.Web.CollectionTimestamps)
+	previous := e.clientView()
+	e.updateClient(func(cli *ovsdb.OvnClient) {
+		cli.Timeout = cfg.OVN.Timeout
+		cli.System.RunDir = cfg.System.RunDir
+		cfg.Database.Vswitch.apply(&cli.Database.Vswitch)
+		cfg.Database.Northbound.apply(&cli.Database.Northbound)
+		cfg.Database.Southbound.apply(&cli.Database.Southbound)
+		cli.Service.Vswitchd.File.Log.Path = cfg.Service.Vswitchd.File.Log.Path
+		cli.Service.Vswitchd.File.Pid.Path = cfg.Service.Vswitchd.File.Pid.Path
+		cli.Service.Northd.File.Log.Path = cfg.Service.Northd.File.Log.Path
+		cli.Service.Northd.File.Pid.Path = cfg.Service.Northd.File.Pid.Path
+	})
+	// The connections to the databases with a new remote are replaced.
+	// When the database is unavailable, the supervisor retries.
+	cli := e.clientView()
+	for _, conn := range e.connections {
+		db := conn.database(cli)
+		if db.Client == nil || db.Socket.Remote == conn.database(previous).Socket.Remote {
+			continue
+		}
+		e.updateClient(func(cli *ovsdb.OvnClient) {
+			conn.database(cli).Client = nil
+		})
+		go db.Client.Close()
+		conn.setUp(false)
+		if err := e.reconnect(conn, *db); err != nil {
+			level.Warn(e.logger).Log(
+				"msg", "failed connecting to database with new remote",
+				"database", db.Name,
+				"error", err.Error(),
+			)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	testcases := []struct {
+		name      string
+		input     string
+		check     func(t *testing.T, cfg *Config)
+		wantErrs  []string
+		shouldErr bool
+	}{
+		{
+			name:  "empty file keeps defaults",
+			input: "",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Northbound.Socket.Remote != "unix:/run/openvswitch/ovnnb_db.sock" {
+					t.Errorf("unexpected northbound remote %q", cfg.Database.Northbound.Socket.Remote)
+				}
+				if len(cfg.Probe.Modules) != len(DefaultProbeModules()) {
+					t.Errorf("expected default probe modules, but got %v", cfg.Probe.Modules)
+				}
+			},
+		},
+		{
+			name: "override settings",
+			input: `
+ovn:
+  poll_interval: 30
+database:
+  southbound:
+    socket:
+      remote: tcp:10.0.0.5:6642
+collectors:
+  network_port:
+    enabled: false
+  server_status: {}
+  logical_switch_port:
+    interval: 120
+    cache_ttl: 600
+probe:
+  modules:
+    central:
+      database: northbound
+      peer: tcp:10.0.0.5:6642
+      collectors: [logical_switch]
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.OVN.PollInterval != 30 {
+					t.Errorf("expected poll interval 30, but got %d", cfg.OVN.PollInterval)
+				}
+				if cfg.OVN.Timeout != 2 {
+					t.Errorf("expected default timeout 2, but got %d", cfg.OVN.Timeout)
+				}
+				if cfg.Database.Southbound.Socket.Remote != "tcp:10.0.0.5:6642" {
+					t.Errorf("unexpected southbound remote %q", cfg.Database.Southbound.Socket.Remote)
+				}
+				if cfg.Database.Southbound.Name != "OVN_Southbound" {
+					t.Errorf("unexpected southbound name %q", cfg.Database.Southbound.Name)
+				}
+				states := cfg.CollectorStates()
+				if states["network_port"] || !states["chassis"] || states["server_status"] {
+					t.Errorf("unexpected collector states %v", states)
+				}
+				if c := cfg.Collectors["logical_switch_port"]; c.Interval != 120 || c.CacheTTL != 600 || !*c.Enabled {
+					t.Errorf("unexpected logical_switch_port settings %+v", c)
+				}
+				if _, exists := cfg.Probe.Modules["northbound"]; exists {
+					t.Errorf("expected the modules of the file to replace the default modules")
+				}
+			},
+		},
+		{
+			name:      "unknown setting",
+			input:     "ovn:\n  pollinterval: 30\n",
+			wantErrs:  []string{"pollinterval"},
+			shouldErr: true,
+		},
+		{
+			name: "offline mode without data file",
+			input: `
+ovn:
+  offline: true
+database:
+  southbound:
+    file:
+      data:
+        path: ""
+`,
+			wantErrs:  []string{"database.southbound.file.data.path"},
+			shouldErr: true,
+		},
+		{
+			name: "invalid settings",
+			input: `
+web:
+  config_file: /nonexistent/web-config.yml
+  read_timeout: -1s
+log:
+  level: verbose
+ovn:
+  timeout: 0
+database:
+  northbound:
+    port:
+      raft: 70000
+collectors:
+  foo:
+    enabled: true
+  coverage:
+    interval: -5
+probe:
+  modules:
+    local:
+      database: northbound
+      collectors: [process]
+`,
+			wantErrs: []string{
+				"web.config_file",
+				"web.read_timeout",
+				"log.level",
+				"ovn.timeout",
+				"database.northbound.port.raft",
+				`unsupported collector "foo"`,
+				"collectors.coverage.interval",
+				"probe.modules.local",
+			},
+			shouldErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := ParseConfig([]byte(tc.input))
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error, but got none")
+				}
+				for _, s := range tc.wantErrs {
+					if !strings.Contains(err.Error(), s) {
+						t.Errorf("expected error to contain %q, but got %q", s, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err)
+			}
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	e := newTestExporter(t)
+	cfg := DefaultConfig()
+	cfg.OVN.Timeout = 5
+	cfg.System.RunDir = "/run/ovn"
+	cfg.Database.Northbound.File.Pid.Path = "/run/ovn/ovnnb_db.pid"
+	enabled := true
+	cfg.Collectors["server_status"] = CollectorConfig{Enabled: &enabled}
+	if err := e.ApplyConfig(cfg); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if e.getTimeout().Seconds() != 5 {
+		t.Errorf("expected timeout 5s, but got %s", e.getTimeout())
+	}
+	if e.Client.System.RunDir != "/run/ovn" {
+		t.Errorf("unexpected run dir %q", e.Client.System.RunDir)
+	}
+	if e.Client.Database.Northbound.File.Pid.Path != "/run/ovn/ovnnb_db.pid" {
+		t.Errorf("unexpected pid path %q", e.Client.Database.Northbound.File.Pid.Path)
+	}
+	if e.Client.Database.Vswitch.Port.Default != 6640 {
+		t.Errorf("expected default vswitch port to be kept, but got %d", e.Client.Database.Vswitch.Port.Default)
+	}
+	if len(e.collectors) != 12 {
+		t.Errorf("expected 12 collectors, but got %d", len(e.collectors))
+	}
+}
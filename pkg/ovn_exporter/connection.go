@@ -0,0 +1,238 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// minReconnectBackoff is the delay before the first attempt to
+	// reconnect to a database after a failure.
+	minReconnectBackoff = time.Second
+	// maxReconnectBackoff is the upper bound of the delay between the
+	// attempts to reconnect to a database.
+	maxReconnectBackoff = time.Minute
+)
+
+// dbConnection holds the state of the connection to an OVSDB database.
+// The connection counts as re-established whenever it comes back up after
+// being down, whether the supervisor or the client itself reconnected.
+type dbConnection struct {
+	component   string
+	database    func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase
+	mu          sync.Mutex
+	up          bool
+	established bool
+	reconnects  int64
+}
+
+func newDBConnections() []*dbConnection {
+	return []*dbConnection{
+		{
+			component: "ovsdb-server",
+			database:  func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase { return &cli.Database.Vswitch },
+		},
+		{
+			component: "ovsdb-server-northbound",
+			database:  func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase { return &cli.Database.Northbound },
+		},
+		{
+			component: "ovsdb-server-southbound",
+			database:  func(cli *ovsdb.OvnClient) *ovsdb.OvsDatabase { return &cli.Database.Southbound },
+		},
+	}
+}
+
+func (c *dbConnection) setUp(up bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if up && !c.up && c.established {
+		c.reconnects++
+	}
+	if up {
+		c.established = true
+	}
+	c.up = up
+}
+
+func (c *dbConnection) state() (bool, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.up, c.reconnects
+}
+
+// SuperviseConnections checks the connections to the Open_vSwitch,
+// OVN_Northbound and OVN_Southbound databases every poll interval until
+// the context is cancelled. A connection failing the check is replaced
+// by a new one, with an exponential backoff between the attempts. Only the
+// databases used by the enabled collectors are checked, so a chassis
+// without the OVN databases does not retry them forever.
+func (e *Exporter) SuperviseConnections(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, conn := range e.connections {
+		wg.Add(1)
+		go func(conn *dbConnection) {
+			defer wg.Done()
+			e.superviseConnection(ctx, conn)
+		}(conn)
+	}
+	wg.Wait()
+}
+
+func (e *Exporter) superviseConnection(ctx context.Context, conn *dbConnection) {
+	backoff := minReconnectBackoff
+	for {
+		wait := e.getPollInterval()
+		if e.offline.Load() != nil || !e.usedDatabases()[conn.component] {
+			// The databases are read from their files, or the
+			// database is not used.
+			backoff = minReconnectBackoff
+		} else if err := e.checkConnection(ctx, conn); err != nil {
+			cli := e.clientView()
+			level.Warn(e.logger).Log(
+				"msg", "database connection is down",
+				"database", conn.database(cli).Name,
+				"system_id", cli.System.ID,
+				"retry_in", backoff,
+				"error", err.Error(),
+			)
+			wait = backoff
+			backoff = nextReconnectBackoff(backoff)
+		} else {
+			backoff = minReconnectBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// nextReconnectBackoff doubles the backoff up to maxReconnectBackoff.
+func nextReconnectBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxReconnectBackoff {
+		return maxReconnectBackoff
+	}
+	return backoff
+}
+
+// checkConnection probes the database with a list_dbs request and
+// reconnects when the probe fails.
+func (e *Exporter) checkConnection(ctx context.Context, conn *dbConnection) error {
+	cli := e.clientView()
+	db := conn.database(cli)
+	if db.Client != nil {
+		client := db.Client
+		err := e.runDatabaseStep(ctx, "Databases()", func() error {
+			_, err := client.Databases()
+			return err
+		})
+		if err == nil {
+			conn.setUp(true)
+			return nil
+		}
+		level.Debug(e.logger).Log(
+			"msg", "database probe failed",
+			"database", db.Name,
+			"system_id", cli.System.ID,
+			"error", err.Error(),
+		)
+	}
+	conn.setUp(false)
+	return e.reconnect(conn, *db)
+}
+
+// reconnect replaces the client of the database with a new connection.
+func (e *Exporter) reconnect(conn *dbConnection, db ovsdb.OvsDatabase) error {
+	client, err := ovsdb.NewClient(db.Socket.Remote, int(e.getTimeout()/time.Second))
+	if err != nil {
+		return fmt.Errorf("failed connecting to %s via %s: %s", db.Name, db.Socket.Remote, err)
+	}
+	var previous *ovsdb.Client
+	var systemID string
+	e.updateClient(func(cli *ovsdb.OvnClient) {
+		d := conn.database(cli)
+		previous = d.Client
+		d.Client = &client
+		systemID = cli.System.ID
+	})
+	if previous != nil {
+		// The previous client may still be serving an abandoned
+		// request, so it is closed in the background.
+		go previous.Close()
+	}
+	conn.setUp(true)
+	level.Info(e.logger).Log(
+		"msg", "reconnected to database",
+		"database", db.Name,
+		"system_id", systemID,
+	)
+	return nil
+}
+
+// usedDatabases returns the components whose database the enabled
+// collectors of the last published snapshot use.
+func (e *Exporter) usedDatabases() map[string]bool {
+	var names []string
+	if snapshot := e.snapshot.Load(); snapshot != nil {
+		names = snapshot.collectors
+	}
+	databases, _ := requiredComponents(names)
+	return databases
+}
+
+// newConnectionMetrics returns the current state of the supervised
+// connections.
+func (e *Exporter) newConnectionMetrics() []prometheus.Metric {
+	cli := e.clientView()
+	metrics := []prometheus.Metric{}
+	used := e.usedDatabases()
+	for _, conn := range e.connections {
+		if !used[conn.component] {
+			continue
+		}
+		name := conn.database(cli).Name
+		up, reconnects := conn.state()
+		upValue := 0
+		if up {
+			upValue = 1
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			dbConnectionUp,
+			prometheus.GaugeValue,
+			float64(upValue),
+			name,
+		))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			dbReconnects,
+			prometheus.CounterValue,
+			float64(reconnects),
+			name,
+		))
+	}
+	return metrics
+}
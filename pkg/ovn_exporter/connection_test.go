@@ -0,0 +1,180 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// listDbsServer answers list_dbs requests on a unix socket.
+type listDbsServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func startListDbsServer(t *testing.T, path string) *listDbsServer {
+	t.Helper()
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	s := &listDbsServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *listDbsServer) serve(conn net.Conn) {
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req struct {
+			Method string      `json:"method"`
+			ID     interface{} `json:"id"`
+		}
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		enc.Encode(map[string]interface{}{
+			"id":     req.ID,
+			"result": []string{"OVN_Northbound"},
+			"error":  nil,
+		})
+	}
+}
+
+func (s *listDbsServer) stop() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func TestNextReconnectBackoff(t *testing.T) {
+	testcases := []struct {
+		backoff time.Duration
+		want    time.Duration
+	}{
+		{backoff: time.Second, want: 2 * time.Second},
+		{backoff: 16 * time.Second, want: 32 * time.Second},
+		{backoff: 32 * time.Second, want: maxReconnectBackoff},
+		{backoff: maxReconnectBackoff, want: maxReconnectBackoff},
+	}
+	for _, tc := range testcases {
+		if got := nextReconnectBackoff(tc.backoff); got != tc.want {
+			t.Errorf("nextReconnectBackoff(%s): expected %s, but got %s", tc.backoff, tc.want, got)
+		}
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	dir, err := os.MkdirTemp("", "ovn")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nb.sock")
+
+	e := newTestExporter(t)
+	e.SetTimeout(1)
+	e.Client.Database.Northbound.Socket.Remote = "unix:" + path
+	conn := e.connections[1]
+	ctx := context.Background()
+
+	server := startListDbsServer(t, path)
+	if err := e.checkConnection(ctx, conn); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if up, reconnects := conn.state(); !up || reconnects != 0 {
+		t.Fatalf("expected connection up without reconnects, but got up=%t reconnects=%d", up, reconnects)
+	}
+
+	server.stop()
+	if err := e.checkConnection(ctx, conn); err == nil {
+		t.Fatalf("expected error, but got none")
+	}
+	if up, _ := conn.state(); up {
+		t.Fatalf("expected connection down, but got up")
+	}
+
+	os.Remove(path)
+	server = startListDbsServer(t, path)
+	defer server.stop()
+	if err := e.checkConnection(ctx, conn); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if up, reconnects := conn.state(); !up || reconnects != 1 {
+		t.Fatalf("expected connection up after a reconnect, but got up=%t reconnects=%d", up, reconnects)
+	}
+}
+
+func TestConnectionMetricsOfUsedDatabases(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		collectors []string
+		want       []string
+	}{
+		{
+			name:       "chassis node",
+			collectors: []string{"process", "logs"},
+			want:       []string{"Open_vSwitch"},
+		},
+		{
+			name:       "southbound collector",
+			collectors: []string{"chassis"},
+			want:       []string{"Open_vSwitch", "OVN_Southbound"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newFakeExporter(t, &FakeDataSource{}, tc.collectors...)
+			got := []string{}
+			for _, m := range e.newConnectionMetrics() {
+				var metric dto.Metric
+				if err := m.Write(&metric); err != nil {
+					t.Fatalf("expected no error, but got %q", err)
+				}
+				if metric.Gauge != nil {
+					got = append(got, metric.GetLabel()[0].GetValue())
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected connections %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
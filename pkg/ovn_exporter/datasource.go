@@ -0,0 +1,313 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+)
+
+// ErrNotConnected is returned by a DataSource for a database it has no
+// connection to.
+var ErrNotConnected = errors.New("not connected")
+
+// DataSource is the source of the data the collectors turn into metrics.
+// Its methods are named after the methods of ovsdb.OvnClient returning the
+// same data. The exporter calls them concurrently, and abandons a call not
+// returning before the deadline of its collection step.
+type DataSource interface {
+	// GetSystemInfo returns the information about the system, read from
+	// the Open_vSwitch database.
+	GetSystemInfo() (SystemInfo, error)
+	// GetProcessInfo returns the process of a component.
+	GetProcessInfo(component string) (ovsdb.OvsProcess, error)
+	// GetProcessStartTime returns when the process of a component started.
+	GetProcessStartTime(component string) (time.Time, error)
+	// GetLogFileInfo returns the log file of a component.
+	GetLogFileInfo(component string) (ovsdb.OvsDataFile, error)
+	// GetLogFileEventStats returns the number of events logged by a
+	// component since the previous call, by severity and source.
+	GetLogFileEventStats(component string) (map[string]map[string]uint64, error)
+	// GetChassis returns the chassis in the Southbound database.
+	GetChassis() ([]*ovsdb.OvnChassis, error)
+	// GetLogicalSwitches returns the logical switches in the Northbound
+	// database.
+	GetLogicalSwitches() ([]*ovsdb.OvnLogicalSwitch, error)
+	// GetLogicalSwitchPorts returns the logical switch ports in the
+	// Northbound database, with their Southbound port bindings.
+	GetLogicalSwitchPorts() ([]*ovsdb.OvnLogicalSwitchPort, error)
+	// GetLogicalRouters returns the logical routers in the Northbound
+	// database, with the tunnel keys of their Southbound datapaths.
+	GetLogicalRouters() ([]*LogicalRouter, error)
+	// GetLogicalRouterPorts returns the logical router ports in the
+	// Northbound database.
+	GetLogicalRouterPorts() ([]*LogicalRouterPort, error)
+	// GetLogicalRouterStaticRoutes returns the static routes of the
+	// logical routers in the Northbound database.
+	GetLogicalRouterStaticRoutes() ([]*LogicalRouterStaticRoute, error)
+	// GetLogicalRouterPolicies returns the routing policies of the
+	// logical routers in the Northbound database.
+	GetLogicalRouterPolicies() ([]*LogicalRouterPolicy, error)
+	// GetLogicalRouterNATs returns the NAT rules of the logical routers
+	// in the Northbound database.
+	GetLogicalRouterNATs() ([]*LogicalRouterNAT, error)
+	// GetLoadBalancers returns the load balancers in the Northbound
+	// database, with the rows they are attached to.
+	GetLoadBalancers() ([]*LoadBalancer, error)
+	// GetServiceMonitors returns the health checks of the load balancer
+	// backends in the Southbound database.
+	GetServiceMonitors() ([]*ServiceMonitor, error)
+	// AppListCommands returns the commands supported by the control
+	// socket of a component.
+	AppListCommands(component string) (map[string]bool, error)
+	// GetAppCoverageMetrics returns the coverage counters of a component,
+	// by event and period.
+	GetAppCoverageMetrics(component string) (map[string]map[string]float64, error)
+	// GetAppMemoryMetrics returns the memory usage of a component, by
+	// facility.
+	GetAppMemoryMetrics(component string) (map[string]float64, error)
+	// GetAppClusteringInfo returns the raft state of the database served
+	// by a component.
+	GetAppClusteringInfo(component string) (ovsdb.ClusterState, error)
+	// IsDefaultPortUp, IsSslPortUp and IsRaftPortUp return 1 when the TCP
+	// port of a component is listening, and 0 otherwise.
+	IsDefaultPortUp(component string) (int, error)
+	IsSslPortUp(component string) (int, error)
+	IsRaftPortUp(component string) (int, error)
+	// GetServerDatabases returns the databases of the _Server database of
+	// the server of a component.
+	GetServerDatabases(component string) ([]ServerDatabase, error)
+}
+
+// SystemInfo is the information about the OVS system.
+type SystemInfo struct {
+	ID            string
+	RunDir        string
+	Hostname      string
+	Type          string
+	Version       string
+	OvsVersion    string
+	SchemaVersion string
+}
+
+// ServerDatabase is a row of the Database table of the _Server database.
+type ServerDatabase struct {
+	Name      string
+	Model     string
+	Connected bool
+	Leader    bool
+	ClusterID string
+	ServerID  string
+}
+
+// dataSource returns the source of the data of the collectors. In offline
+// mode, the Northbound and Southbound data are read from the files.
+func (e *Exporter) dataSource() DataSource {
+	if offline := e.offline.Load(); offline != nil {
+		return &offlineSource{DataSource: e.source, db: offline}
+	}
+	return e.source
+}
+
+// clientSource is the DataSource querying OVN with the exporter's client.
+// Every call works on a view of the client, and the calls discovering
+// process IDs and log offsets merge them back into the client.
+type clientSource struct {
+	e *Exporter
+}
+
+func (s *clientSource) GetSystemInfo() (SystemInfo, error) {
+	cli := s.e.clientView()
+	if err := cli.GetSystemInfo(); err != nil {
+		return SystemInfo{}, err
+	}
+	return SystemInfo{
+		ID:            cli.System.ID,
+		RunDir:        cli.System.RunDir,
+		Hostname:      cli.System.Hostname,
+		Type:          cli.System.Type,
+		Version:       cli.System.Version,
+		OvsVersion:    cli.Database.Vswitch.Version,
+		SchemaVersion: cli.Database.Vswitch.Schema.Version,
+	}, nil
+}
+
+func (s *clientSource) GetProcessInfo(component string) (ovsdb.OvsProcess, error) {
+	cli := s.e.clientView()
+	p, err := cli.GetProcessInfo(component)
+	if err != nil {
+		return p, err
+	}
+	s.e.updateClient(func(dst *ovsdb.OvnClient) {
+		mergeProcessInfo(dst, cli, component)
+	})
+	return p, nil
+}
+
+func (s *clientSource) GetProcessStartTime(component string) (time.Time, error) {
+	cli := s.e.clientView()
+	p, err := cli.GetProcessInfo(component)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return getProcessStartTime(p.ID)
+}
+
+func (s *clientSource) GetLogFileInfo(component string) (ovsdb.OvsDataFile, error) {
+	cli := s.e.clientView()
+	file, err := cli.GetLogFileInfo(component)
+	if err != nil {
+		return file, err
+	}
+	s.mergeLogFile(cli, component)
+	return file, nil
+}
+
+func (s *clientSource) GetLogFileEventStats(component string) (map[string]map[string]uint64, error) {
+	cli := s.e.clientView()
+	stats, err := cli.GetLogFileEventStats(component)
+	if err != nil {
+		return stats, err
+	}
+	s.mergeLogFile(cli, component)
+	return stats, nil
+}
+
+// mergeLogFile copies the log file of a component, with the offset up to
+// which its events have been counted, from a view to the client.
+func (s *clientSource) mergeLogFile(cli *ovsdb.OvnClient, component string) {
+	s.e.updateClient(func(dst *ovsdb.OvnClient) {
+		if f := getLogFile(dst, component); f != nil {
+			*f = *getLogFile(cli, component)
+		}
+	})
+}
+
+func (s *clientSource) GetChassis() ([]*ovsdb.OvnChassis, error) {
+	return s.e.clientView().GetChassis()
+}
+
+func (s *clientSource) GetLogicalSwitches() ([]*ovsdb.OvnLogicalSwitch, error) {
+	return s.e.clientView().GetLogicalSwitches()
+}
+
+func (s *clientSource) GetLogicalSwitchPorts() ([]*ovsdb.OvnLogicalSwitchPort, error) {
+	return s.e.clientView().GetLogicalSwitchPorts()
+}
+
+func (s *clientSource) AppListCommands(component string) (map[string]bool, error) {
+	return s.e.clientView().AppListCommands(component)
+}
+
+func (s *clientSource) GetAppCoverageMetrics(component string) (map[string]map[string]float64, error) {
+	return s.e.clientView().GetAppCoverageMetrics(component)
+}
+
+func (s *clientSource) GetAppMemoryMetrics(component string) (map[string]float64, error) {
+	return s.e.clientView().GetAppMemoryMetrics(component)
+}
+
+func (s *clientSource) GetAppClusteringInfo(component string) (ovsdb.ClusterState, error) {
+	return s.e.clientView().GetAppClusteringInfo(component)
+}
+
+func (s *clientSource) IsDefaultPortUp(component string) (int, error) {
+	return s.e.clientView().IsDefaultPortUp(component)
+}
+
+func (s *clientSource) IsSslPortUp(component string) (int, error) {
+	return s.e.clientView().IsSslPortUp(component)
+}
+
+func (s *clientSource) IsRaftPortUp(component string) (int, error) {
+	return s.e.clientView().IsRaftPortUp(component)
+}
+
+func (s *clientSource) GetServerDatabases(component string) ([]ServerDatabase, error) {
+	db := getServerDatabase(s.e.clientView(), component)
+	if db == nil {
+		return nil, fmt.Errorf("the %s component does not serve a database", component)
+	}
+	if db.Client == nil {
+		return nil, ErrNotConnected
+	}
+	result, err := db.Client.Transact("_Server", "SELECT name, model, connected, leader, cid, sid FROM Database")
+	if err != nil {
+		return nil, err
+	}
+	databases := []ServerDatabase{}
+	for _, row := range result.Rows {
+		databases = append(databases, ServerDatabase{
+			Name:      getStringColumn(row, "name", result.Columns),
+			Model:     getStringColumn(row, "model", result.Columns),
+			Connected: getBoolColumn(row, "connected", result.Columns),
+			Leader:    getBoolColumn(row, "leader", result.Columns),
+			ClusterID: getStringColumn(row, "cid", result.Columns),
+			ServerID:  getStringColumn(row, "sid", result.Columns),
+		})
+	}
+	return databases, nil
+}
+
+// offlineSource is the DataSource of offline mode. The Northbound and
+// Southbound data are read from the database files, and the other calls
+// go to the underlying source.
+type offlineSource struct {
+	DataSource
+	db *offlineDatabases
+}
+
+func (s *offlineSource) GetChassis() ([]*ovsdb.OvnChassis, error) {
+	return s.db.GetChassis()
+}
+
+func (s *offlineSource) GetLogicalSwitches() ([]*ovsdb.OvnLogicalSwitch, error) {
+	return s.db.GetLogicalSwitches()
+}
+
+func (s *offlineSource) GetLogicalSwitchPorts() ([]*ovsdb.OvnLogicalSwitchPort, error) {
+	return s.db.GetLogicalSwitchPorts()
+}
+
+func (s *offlineSource) GetLogicalRouters() ([]*LogicalRouter, error) {
+	return s.db.GetLogicalRouters()
+}
+
+func (s *offlineSource) GetLogicalRouterPorts() ([]*LogicalRouterPort, error) {
+	return s.db.GetLogicalRouterPorts()
+}
+
+func (s *offlineSource) GetLogicalRouterStaticRoutes() ([]*LogicalRouterStaticRoute, error) {
+	return s.db.GetLogicalRouterStaticRoutes()
+}
+
+func (s *offlineSource) GetLogicalRouterPolicies() ([]*LogicalRouterPolicy, error) {
+	return s.db.GetLogicalRouterPolicies()
+}
+
+func (s *offlineSource) GetLogicalRouterNATs() ([]*LogicalRouterNAT, error) {
+	return s.db.GetLogicalRouterNATs()
+}
+
+func (s *offlineSource) GetLoadBalancers() ([]*LoadBalancer, error) {
+	return s.db.GetLoadBalancers()
+}
+
+func (s *offlineSource) GetServiceMonitors() ([]*ServiceMonitor, error) {
+	return s.db.GetServiceMonitors()
+}
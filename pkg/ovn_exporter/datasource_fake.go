@@ -0,0 +1,293 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+)
+
+// FakeDataSource is an in-memory DataSource returning the configured state
+// of OVN. It is meant for tests, which set its fields before passing it to
+// NewExporter in Options.DataSource, and must not change them while the
+// exporter collects.
+//
+// The per-component methods return an error for a component missing from
+// their map. Errors, keyed by method name, e.g. "GetChassis", or by method
+// name and component, e.g. "GetAppMemoryMetrics/ovn-northd", makes a
+// method fail.
+type FakeDataSource struct {
+	System             SystemInfo
+	Processes          map[string]ovsdb.OvsProcess
+	StartTimes         map[string]time.Time
+	LogFiles           map[string]FakeLogFile
+	Chassis            []*ovsdb.OvnChassis
+	LogicalSwitches    []*ovsdb.OvnLogicalSwitch
+	LogicalSwitchPorts []*ovsdb.OvnLogicalSwitchPort
+	LogicalRouters     []*LogicalRouter
+	LogicalRouterPorts []*LogicalRouterPort
+	StaticRoutes       []*LogicalRouterStaticRoute
+	RouterPolicies     []*LogicalRouterPolicy
+	NAT                []*LogicalRouterNAT
+	LoadBalancers      []*LoadBalancer
+	ServiceMonitors    []*ServiceMonitor
+	AppCommands        map[string]map[string]bool
+	Coverage           map[string]map[string]map[string]float64
+	Memory             map[string]map[string]float64
+	Clusters           map[string]ovsdb.ClusterState
+	// Ports holds the state of the TCP ports of the components, by usage:
+	// "default", "ssl" or "raft".
+	Ports           map[string]map[string]int
+	ServerDatabases map[string][]ServerDatabase
+	Errors          map[string]error
+}
+
+// FakeLogFile is the log file of a component in a FakeDataSource.
+type FakeLogFile struct {
+	Path   string
+	Size   int64
+	Events map[string]map[string]uint64
+}
+
+// err returns the error configured for a call.
+func (s *FakeDataSource) err(method, component string) error {
+	if err, exists := s.Errors[method]; exists {
+		return err
+	}
+	if err, exists := s.Errors[method+"/"+component]; exists {
+		return err
+	}
+	return nil
+}
+
+func (s *FakeDataSource) GetSystemInfo() (SystemInfo, error) {
+	if err := s.err("GetSystemInfo", ""); err != nil {
+		return SystemInfo{}, err
+	}
+	return s.System, nil
+}
+
+func (s *FakeDataSource) GetProcessInfo(component string) (ovsdb.OvsProcess, error) {
+	if err := s.err("GetProcessInfo", component); err != nil {
+		return ovsdb.OvsProcess{}, err
+	}
+	p, exists := s.Processes[component]
+	if !exists {
+		return p, fmt.Errorf("%s: no process", component)
+	}
+	return p, nil
+}
+
+func (s *FakeDataSource) GetProcessStartTime(component string) (time.Time, error) {
+	if err := s.err("GetProcessStartTime", component); err != nil {
+		return time.Time{}, err
+	}
+	started, exists := s.StartTimes[component]
+	if !exists {
+		return started, fmt.Errorf("%s: no start time", component)
+	}
+	return started, nil
+}
+
+func (s *FakeDataSource) GetLogFileInfo(component string) (ovsdb.OvsDataFile, error) {
+	if err := s.err("GetLogFileInfo", component); err != nil {
+		return ovsdb.OvsDataFile{}, err
+	}
+	f, exists := s.LogFiles[component]
+	if !exists {
+		return ovsdb.OvsDataFile{}, fmt.Errorf("%s: no log file", component)
+	}
+	return ovsdb.OvsDataFile{
+		Path:      f.Path,
+		Component: component,
+		Info:      fakeFileInfo{path: f.Path, size: f.Size},
+	}, nil
+}
+
+func (s *FakeDataSource) GetLogFileEventStats(component string) (map[string]map[string]uint64, error) {
+	if err := s.err("GetLogFileEventStats", component); err != nil {
+		return nil, err
+	}
+	f, exists := s.LogFiles[component]
+	if !exists {
+		return nil, fmt.Errorf("%s: no log file", component)
+	}
+	return f.Events, nil
+}
+
+func (s *FakeDataSource) GetChassis() ([]*ovsdb.OvnChassis, error) {
+	if err := s.err("GetChassis", ""); err != nil {
+		return nil, err
+	}
+	return s.Chassis, nil
+}
+
+func (s *FakeDataSource) GetLogicalSwitches() ([]*ovsdb.OvnLogicalSwitch, error) {
+	if err := s.err("GetLogicalSwitches", ""); err != nil {
+		return nil, err
+	}
+	return s.LogicalSwitches, nil
+}
+
+func (s *FakeDataSource) GetLogicalSwitchPorts() ([]*ovsdb.OvnLogicalSwitchPort, error) {
+	if err := s.err("GetLogicalSwitchPorts", ""); err != nil {
+		return nil, err
+	}
+	return s.LogicalSwitchPorts, nil
+}
+
+func (s *FakeDataSource) GetLogicalRouters() ([]*LogicalRouter, error) {
+	if err := s.err("GetLogicalRouters", ""); err != nil {
+		return nil, err
+	}
+	return s.LogicalRouters, nil
+}
+
+func (s *FakeDataSource) GetLogicalRouterPorts() ([]*LogicalRouterPort, error) {
+	if err := s.err("GetLogicalRouterPorts", ""); err != nil {
+		return nil, err
+	}
+	return s.LogicalRouterPorts, nil
+}
+
+func (s *FakeDataSource) GetLogicalRouterStaticRoutes() ([]*LogicalRouterStaticRoute, error) {
+	if err := s.err("GetLogicalRouterStaticRoutes", ""); err != nil {
+		return nil, err
+	}
+	return s.StaticRoutes, nil
+}
+
+func (s *FakeDataSource) GetLogicalRouterPolicies() ([]*LogicalRouterPolicy, error) {
+	if err := s.err("GetLogicalRouterPolicies", ""); err != nil {
+		return nil, err
+	}
+	return s.RouterPolicies, nil
+}
+
+func (s *FakeDataSource) GetLogicalRouterNATs() ([]*LogicalRouterNAT, error) {
+	if err := s.err("GetLogicalRouterNATs", ""); err != nil {
+		return nil, err
+	}
+	return s.NAT, nil
+}
+
+func (s *FakeDataSource) GetLoadBalancers() ([]*LoadBalancer, error) {
+	if err := s.err("GetLoadBalancers", ""); err != nil {
+		return nil, err
+	}
+	return s.LoadBalancers, nil
+}
+
+func (s *FakeDataSource) GetServiceMonitors() ([]*ServiceMonitor, error) {
+	if err := s.err("GetServiceMonitors", ""); err != nil {
+		return nil, err
+	}
+	return s.ServiceMonitors, nil
+}
+
+func (s *FakeDataSource) AppListCommands(component string) (map[string]bool, error) {
+	if err := s.err("AppListCommands", component); err != nil {
+		return nil, err
+	}
+	cmds, exists := s.AppCommands[component]
+	if !exists {
+		return nil, fmt.Errorf("%s: no control socket", component)
+	}
+	return cmds, nil
+}
+
+func (s *FakeDataSource) GetAppCoverageMetrics(component string) (map[string]map[string]float64, error) {
+	if err := s.err("GetAppCoverageMetrics", component); err != nil {
+		return nil, err
+	}
+	events, exists := s.Coverage[component]
+	if !exists {
+		return nil, fmt.Errorf("%s: no coverage counters", component)
+	}
+	return events, nil
+}
+
+func (s *FakeDataSource) GetAppMemoryMetrics(component string) (map[string]float64, error) {
+	if err := s.err("GetAppMemoryMetrics", component); err != nil {
+		return nil, err
+	}
+	memory, exists := s.Memory[component]
+	if !exists {
+		return nil, fmt.Errorf("%s: no memory usage", component)
+	}
+	return memory, nil
+}
+
+func (s *FakeDataSource) GetAppClusteringInfo(component string) (ovsdb.ClusterState, error) {
+	if err := s.err("GetAppClusteringInfo", component); err != nil {
+		return ovsdb.ClusterState{}, err
+	}
+	state, exists := s.Clusters[component]
+	if !exists {
+		return state, fmt.Errorf("%s: no cluster", component)
+	}
+	return state, nil
+}
+
+func (s *FakeDataSource) IsDefaultPortUp(component string) (int, error) {
+	return s.port("IsDefaultPortUp", component, "default")
+}
+
+func (s *FakeDataSource) IsSslPortUp(component string) (int, error) {
+	return s.port("IsSslPortUp", component, "ssl")
+}
+
+func (s *FakeDataSource) IsRaftPortUp(component string) (int, error) {
+	return s.port("IsRaftPortUp", component, "raft")
+}
+
+func (s *FakeDataSource) port(method, component, usage string) (int, error) {
+	if err := s.err(method, component); err != nil {
+		return 0, err
+	}
+	up, exists := s.Ports[component][usage]
+	if !exists {
+		return 0, fmt.Errorf("%s: no %s port", component, usage)
+	}
+	return up, nil
+}
+
+func (s *FakeDataSource) GetServerDatabases(component string) ([]ServerDatabase, error) {
+	if err := s.err("GetServerDatabases", component); err != nil {
+		return nil, err
+	}
+	databases, exists := s.ServerDatabases[component]
+	if !exists {
+		return nil, ErrNotConnected
+	}
+	return databases, nil
+}
+
+// fakeFileInfo is the os.FileInfo of a FakeLogFile.
+type fakeFileInfo struct {
+	path string
+	size int64
+}
+
+func (fi fakeFileInfo) Name() string       { return filepath.Base(fi.path) }
+func (fi fakeFileInfo) Size() int64        { return fi.size }
+func (fi fakeFileInfo) Mode() os.FileMode  { return 0o644 }
+func (fi fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fakeFileInfo) IsDir() bool        { return false }
+func (fi fakeFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,462 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newFakeExporter returns an exporter collecting from a fake data source
+// with the given collectors enabled.
+func newFakeExporter(t *testing.T, src *FakeDataSource, collectors ...string) *Exporter {
+	t.Helper()
+	states := make(map[string]bool)
+	for _, name := range GetCollectorNames() {
+		states[name] = false
+	}
+	for _, name := range collectors {
+		states[name] = true
+	}
+	e, err := NewExporter(Options{Collectors: states, DataSource: src})
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	return e
+}
+
+// gatherSeries runs a collection and returns the series of the given
+// metrics in the text format, sorted.
+func gatherSeries(t *testing.T, e *Exporter, names ...string) []string {
+	t.Helper()
+	e.GatherMetrics()
+	return collectSeries(t, e, names...)
+}
+
+// collectSeries returns the series of the given metrics in the last
+// collection, in the text format, sorted.
+func collectSeries(t *testing.T, e *Exporter, names ...string) []string {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	series := []string{}
+	for _, family := range families {
+		if !wanted[family.GetName()] {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := []string{}
+			for _, l := range m.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+			}
+			value := m.GetGauge().GetValue() + m.GetCounter().GetValue() + m.GetUntyped().GetValue()
+			series = append(series, fmt.Sprintf("%s{%s} %g", family.GetName(), strings.Join(labels, ","), value))
+		}
+	}
+	sort.Strings(series)
+	return series
+}
+
+func TestFakeDataSourceSeries(t *testing.T) {
+	system := SystemInfo{ID: "host-1", Hostname: "host-1", Type: "ovn"}
+	for _, tc := range []struct {
+		name       string
+		collectors []string
+		src        *FakeDataSource
+		metrics    []string
+		want       []string
+	}{
+		{
+			name:       "chassis",
+			collectors: []string{"chassis"},
+			src: &FakeDataSource{
+				System: system,
+				Chassis: []*ovsdb.OvnChassis{
+					{UUID: "c1", Name: "compute-1", IPAddress: net.ParseIP("192.0.2.1")},
+					{UUID: "c2", Name: "compute-2", IPAddress: net.ParseIP("192.0.2.2"), Up: 1},
+				},
+			},
+			metrics: []string{"ovn_chassis_info", "ovn_up"},
+			want: []string{
+				`ovn_chassis_info{ip="192.0.2.1",name="compute-1",system_id="host-1",uuid="c1"} 0`,
+				`ovn_chassis_info{ip="192.0.2.2",name="compute-2",system_id="host-1",uuid="c2"} 1`,
+				`ovn_up{} 1`,
+			},
+		},
+		{
+			name:       "logical switches",
+			collectors: []string{"logical_switch"},
+			src: &FakeDataSource{
+				System: system,
+				LogicalSwitches: []*ovsdb.OvnLogicalSwitch{
+					{
+						UUID:        "s1",
+						Name:        "net-1",
+						TunnelKey:   7,
+						ExternalIDs: map[string]string{"owner": "tenant-1"},
+						Ports:       []string{"p1", "p2"},
+					},
+				},
+			},
+			metrics: []string{
+				"ovn_logical_switch_info",
+				"ovn_logical_switch_ports",
+				"ovn_logical_switch_port_binding",
+				"ovn_logical_switch_external_id",
+				"ovn_logical_switch_tunnel_key",
+			},
+			want: []string{
+				`ovn_logical_switch_external_id{key="owner",system_id="host-1",uuid="s1",value="tenant-1"} 1`,
+				`ovn_logical_switch_info{name="net-1",system_id="host-1",uuid="s1"} 1`,
+				`ovn_logical_switch_port_binding{port="p1",system_id="host-1",uuid="s1"} 1`,
+				`ovn_logical_switch_port_binding{port="p2",system_id="host-1",uuid="s1"} 1`,
+				`ovn_logical_switch_ports{system_id="host-1",uuid="s1"} 2`,
+				`ovn_logical_switch_tunnel_key{system_id="host-1",uuid="s1"} 7`,
+			},
+		},
+		{
+			name:       "logical routers",
+			collectors: []string{"logical_router"},
+			src: &FakeDataSource{
+				System: system,
+				LogicalRouters: []*LogicalRouter{
+					{
+						UUID:        "r1",
+						Name:        "router-1",
+						TunnelKey:   9,
+						ExternalIDs: map[string]string{"owner": "tenant-1"},
+						Ports:       []string{"rp1", "rp2"},
+					},
+				},
+				LogicalRouterPorts: []*LogicalRouterPort{
+					{UUID: "rp1", Name: "lrp-1", MacAddress: "0a:00:00:00:00:01", Networks: []string{"10.0.0.1/24"}, Peer: "lrp-peer"},
+					{UUID: "rp2", Name: "lrp-2", MacAddress: "0a:00:00:00:00:02", Networks: []string{"172.24.4.1/24", "2001:db8::1/64"}, GatewayChassis: []string{"gw-2", "gw-1"}},
+				},
+			},
+			metrics: []string{
+				"ovn_logical_router_info",
+				"ovn_logical_router_ports",
+				"ovn_logical_router_external_id",
+				"ovn_logical_router_tunnel_key",
+				"ovn_logical_router_port_info",
+			},
+			want: []string{
+				`ovn_logical_router_external_id{key="owner",system_id="host-1",uuid="r1",value="tenant-1"} 1`,
+				`ovn_logical_router_info{name="router-1",system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_port_info{gateway_chassis="",logical_router="router-1",mac_address="0a:00:00:00:00:01",name="lrp-1",networks="10.0.0.1/24",peer="lrp-peer",system_id="host-1",uuid="rp1"} 1`,
+				`ovn_logical_router_port_info{gateway_chassis="gw-2,gw-1",logical_router="router-1",mac_address="0a:00:00:00:00:02",name="lrp-2",networks="172.24.4.1/24,2001:db8::1/64",peer="",system_id="host-1",uuid="rp2"} 1`,
+				`ovn_logical_router_ports{system_id="host-1",uuid="r1"} 2`,
+				`ovn_logical_router_tunnel_key{system_id="host-1",uuid="r1"} 9`,
+			},
+		},
+		{
+			name:       "logical router routes",
+			collectors: []string{"logical_router"},
+			src: &FakeDataSource{
+				System: system,
+				LogicalRouters: []*LogicalRouter{
+					{UUID: "r1", Name: "router-1", StaticRoutes: []string{"sr1", "sr2", "sr3", "sr4"}, Policies: []string{"lp1", "lp2"}},
+					{UUID: "r2", Name: "router-2", StaticRoutes: []string{"sr5"}},
+				},
+				StaticRoutes: []*LogicalRouterStaticRoute{
+					{UUID: "sr1", IPPrefix: "0.0.0.0/0", Nexthop: "172.24.4.1", Policy: "dst-ip", BFD: true},
+					{UUID: "sr2", IPPrefix: "10.1.0.0/16", Nexthop: "10.0.0.1", Policy: "dst-ip", BFD: true},
+					{UUID: "sr3", IPPrefix: "10.1.0.0/16", Nexthop: "10.0.0.2", Policy: "dst-ip"},
+					{UUID: "sr4", RouteTable: "rtb-1", IPPrefix: "10.0.0.0/24", Nexthop: "10.0.0.3", Policy: "src-ip"},
+					{UUID: "sr5", RouteTable: "rtb-1", IPPrefix: "::/0", Nexthop: "fd00::1", Policy: "dst-ip"},
+				},
+				RouterPolicies: []*LogicalRouterPolicy{
+					{UUID: "lp1", Priority: 100, Match: "ip4.src == 10.0.0.0/24", Action: "reroute"},
+					{UUID: "lp2", Priority: 90, Match: "ip4.dst == 198.51.100.0/24", Action: "reroute"},
+				},
+			},
+			metrics: []string{
+				"ovn_logical_router_static_routes",
+				"ovn_logical_router_default_route",
+				"ovn_logical_router_bfd_routes",
+				"ovn_logical_router_policies",
+			},
+			want: []string{
+				`ovn_logical_router_bfd_routes{system_id="host-1",uuid="r1"} 2`,
+				`ovn_logical_router_bfd_routes{system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_default_route{system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_default_route{system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_policies{action="allow",system_id="host-1",uuid="r1"} 0`,
+				`ovn_logical_router_policies{action="allow",system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_policies{action="drop",system_id="host-1",uuid="r1"} 0`,
+				`ovn_logical_router_policies{action="drop",system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_policies{action="reroute",system_id="host-1",uuid="r1"} 2`,
+				`ovn_logical_router_policies{action="reroute",system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_static_routes{ecmp_group_size="1",policy="dst-ip",route_table="<main>",system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_static_routes{ecmp_group_size="1",policy="dst-ip",route_table="rtb-1",system_id="host-1",uuid="r2"} 1`,
+				`ovn_logical_router_static_routes{ecmp_group_size="1",policy="src-ip",route_table="rtb-1",system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_static_routes{ecmp_group_size="2",policy="dst-ip",route_table="<main>",system_id="host-1",uuid="r1"} 2`,
+			},
+		},
+		{
+			name:       "logical router NAT",
+			collectors: []string{"logical_router"},
+			src: &FakeDataSource{
+				System: system,
+				LogicalRouters: []*LogicalRouter{
+					{UUID: "r1", Name: "router-1", NAT: []string{"n1", "n2", "n3"}},
+					{UUID: "r2"},
+				},
+				NAT: []*LogicalRouterNAT{
+					{UUID: "n1", Type: "snat", ExternalIP: "172.24.4.10", LogicalIP: "10.0.0.0/24"},
+					{UUID: "n2", Type: "dnat_and_snat", ExternalIP: "172.24.4.101", LogicalIP: "10.0.0.1", LogicalPort: "vm1", ExternalMac: "fa:16:3e:00:00:01"},
+					{UUID: "n3", Type: "dnat_and_snat", ExternalIP: "172.24.4.102", LogicalIP: "10.0.0.2"},
+				},
+			},
+			metrics: []string{
+				"ovn_logical_router_nat_rules",
+				"ovn_logical_router_nat_rules_by_mode",
+				"ovn_logical_router_floating_ip_info",
+			},
+			want: []string{
+				`ovn_logical_router_floating_ip_info{external_ip="172.24.4.101",external_mac="fa:16:3e:00:00:01",logical_ip="10.0.0.1",logical_port="vm1",system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_floating_ip_info{external_ip="172.24.4.102",external_mac="",logical_ip="10.0.0.2",logical_port="",system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_nat_rules_by_mode{mode="centralized",system_id="host-1",uuid="r1"} 2`,
+				`ovn_logical_router_nat_rules_by_mode{mode="centralized",system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_nat_rules_by_mode{mode="distributed",system_id="host-1",uuid="r1"} 1`,
+				`ovn_logical_router_nat_rules_by_mode{mode="distributed",system_id="host-1",uuid="r2"} 0`,
+				`ovn_logical_router_nat_rules{system_id="host-1",type="dnat",uuid="r1"} 0`,
+				`ovn_logical_router_nat_rules{system_id="host-1",type="dnat",uuid="r2"} 0`,
+				`ovn_logical_router_nat_rules{system_id="host-1",type="dnat_and_snat",uuid="r1"} 2`,
+				`ovn_logical_router_nat_rules{system_id="host-1",type="dnat_and_snat",uuid="r2"} 0`,
+				`ovn_logical_router_nat_rules{system_id="host-1",type="snat",uuid="r1"} 1`,
+				`ovn_logical_router_nat_rules{system_id="host-1",type="snat",uuid="r2"} 0`,
+			},
+		},
+		{
+			name:       "load balancers",
+			collectors: []string{"load_balancer"},
+			src: &FakeDataSource{
+				System: system,
+				LoadBalancers: []*LoadBalancer{
+					{
+						UUID:            "lb1",
+						Name:            "lb-dns",
+						Protocol:        "udp",
+						VIPs:            map[string][]string{"10.96.0.10:53": {"10.244.0.3:53", "10.244.0.4:53"}, "[fd00::a]:53": {}},
+						HealthChecks:    []string{"hc1"},
+						SelectionFields: []string{"ip_dst", "ip_src"},
+						Groups:          []string{"clusterLBGroup"},
+					},
+					{
+						UUID:            "lb2",
+						Name:            "lb-web",
+						Protocol:        "tcp",
+						VIPs:            map[string][]string{},
+						LogicalSwitches: []string{"sw0", "sw1"},
+						LogicalRouters:  []string{"lr0"},
+					},
+					{
+						UUID:            "lb3",
+						Name:            "lb-web",
+						Protocol:        "tcp",
+						VIPs:            map[string][]string{"10.0.0.100:80": {}},
+						LogicalSwitches: []string{"sw0"},
+					},
+				},
+			},
+			metrics: []string{
+				"ovn_lb_info",
+				"ovn_lb_vips",
+				"ovn_lb_vip_backends",
+				"ovn_lb_health_checks",
+				"ovn_lb_attachment",
+			},
+			want: []string{
+				`ovn_lb_attachment{lb="lb-dns",name="clusterLBGroup",system_id="host-1",type="load_balancer_group",uuid="lb1"} 1`,
+				`ovn_lb_attachment{lb="lb-web",name="lr0",system_id="host-1",type="logical_router",uuid="lb2"} 1`,
+				`ovn_lb_attachment{lb="lb-web",name="sw0",system_id="host-1",type="logical_switch",uuid="lb2"} 1`,
+				`ovn_lb_attachment{lb="lb-web",name="sw0",system_id="host-1",type="logical_switch",uuid="lb3"} 1`,
+				`ovn_lb_attachment{lb="lb-web",name="sw1",system_id="host-1",type="logical_switch",uuid="lb2"} 1`,
+				`ovn_lb_health_checks{lb="lb-dns",system_id="host-1",uuid="lb1"} 1`,
+				`ovn_lb_health_checks{lb="lb-web",system_id="host-1",uuid="lb2"} 0`,
+				`ovn_lb_health_checks{lb="lb-web",system_id="host-1",uuid="lb3"} 0`,
+				`ovn_lb_info{name="lb-dns",protocol="udp",selection_fields="ip_dst,ip_src",system_id="host-1",uuid="lb1"} 1`,
+				`ovn_lb_info{name="lb-web",protocol="tcp",selection_fields="",system_id="host-1",uuid="lb2"} 1`,
+				`ovn_lb_info{name="lb-web",protocol="tcp",selection_fields="",system_id="host-1",uuid="lb3"} 1`,
+				`ovn_lb_vip_backends{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1",vip="10.96.0.10:53"} 2`,
+				`ovn_lb_vip_backends{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1",vip="[fd00::a]:53"} 0`,
+				`ovn_lb_vip_backends{lb="lb-web",protocol="tcp",system_id="host-1",uuid="lb3",vip="10.0.0.100:80"} 0`,
+				`ovn_lb_vips{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1"} 2`,
+				`ovn_lb_vips{lb="lb-web",protocol="tcp",system_id="host-1",uuid="lb2"} 0`,
+				`ovn_lb_vips{lb="lb-web",protocol="tcp",system_id="host-1",uuid="lb3"} 1`,
+			},
+		},
+		{
+			name:       "load balancer backend status",
+			collectors: []string{"load_balancer"},
+			src: &FakeDataSource{
+				System: system,
+				LoadBalancers: []*LoadBalancer{
+					{
+						UUID:     "lb1",
+						Name:     "lb-dns",
+						Protocol: "udp",
+						VIPs: map[string][]string{
+							"10.96.0.10:53": {"10.244.0.3:53", "10.244.0.4:53", "10.244.0.5:53", "10.244.0.6:53"},
+							"[fd00::a]:53":  {"[fd00::3]:53"},
+							"10.96.0.11:53": {"10.244.0.3:53"},
+						},
+						HealthCheckVIPs: []string{"10.96.0.10:53", "[fd00::a]:53"},
+						IPPortMappings: map[string]string{
+							"10.244.0.3": "coredns-a:10.244.0.2",
+							"10.244.0.4": "coredns-b:10.244.0.2",
+							"10.244.0.5": "coredns-c:10.244.0.2",
+							"fd00::3":    "coredns-d:[fd00::2]",
+						},
+					},
+					{
+						UUID:            "lb2",
+						Name:            "tenant-b-dns",
+						Protocol:        "udp",
+						VIPs:            map[string][]string{"10.96.0.10:53": {"10.244.0.3:53"}},
+						HealthCheckVIPs: []string{"10.96.0.10:53"},
+						IPPortMappings:  map[string]string{"10.244.0.3": "tenant-b-dns:10.244.0.2"},
+					},
+				},
+				ServiceMonitors: []*ServiceMonitor{
+					{UUID: "m1", IP: "10.244.0.3", Port: 53, Protocol: "udp", LogicalPort: "coredns-a", Status: "online"},
+					{UUID: "m2", IP: "10.244.0.4", Port: 53, Protocol: "udp", LogicalPort: "coredns-b", Status: "offline"},
+					{UUID: "m3", IP: "10.244.0.5", Port: 53, Protocol: "tcp", LogicalPort: "coredns-c", Status: "online"},
+					{UUID: "m4", IP: "fd00::3", Port: 53, Protocol: "udp", LogicalPort: "coredns-d"},
+					{UUID: "m5", IP: "10.244.0.3", Port: 53, Protocol: "udp", LogicalPort: "tenant-b-dns", Status: "offline"},
+				},
+			},
+			metrics: []string{
+				"ovn_lb_backend_status",
+				"ovn_lb_vip_backends_healthy",
+				"ovn_lb_vip_backends_unhealthy",
+			},
+			want: []string{
+				`ovn_lb_backend_status{backend_ip="10.244.0.3",lb="lb-dns",logical_port="coredns-a",port="53",protocol="udp",system_id="host-1",uuid="lb1",vip="10.96.0.10:53"} 1`,
+				`ovn_lb_backend_status{backend_ip="10.244.0.3",lb="tenant-b-dns",logical_port="tenant-b-dns",port="53",protocol="udp",system_id="host-1",uuid="lb2",vip="10.96.0.10:53"} 0`,
+				`ovn_lb_backend_status{backend_ip="10.244.0.4",lb="lb-dns",logical_port="coredns-b",port="53",protocol="udp",system_id="host-1",uuid="lb1",vip="10.96.0.10:53"} 0`,
+				`ovn_lb_backend_status{backend_ip="fd00::3",lb="lb-dns",logical_port="coredns-d",port="53",protocol="udp",system_id="host-1",uuid="lb1",vip="[fd00::a]:53"} 1`,
+				`ovn_lb_vip_backends_healthy{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1",vip="10.96.0.10:53"} 1`,
+				`ovn_lb_vip_backends_healthy{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1",vip="[fd00::a]:53"} 1`,
+				`ovn_lb_vip_backends_healthy{lb="tenant-b-dns",protocol="udp",system_id="host-1",uuid="lb2",vip="10.96.0.10:53"} 0`,
+				`ovn_lb_vip_backends_unhealthy{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1",vip="10.96.0.10:53"} 3`,
+				`ovn_lb_vip_backends_unhealthy{lb="lb-dns",protocol="udp",system_id="host-1",uuid="lb1",vip="[fd00::a]:53"} 0`,
+				`ovn_lb_vip_backends_unhealthy{lb="tenant-b-dns",protocol="udp",system_id="host-1",uuid="lb2",vip="10.96.0.10:53"} 1`,
+			},
+		},
+		{
+			name:       "failing critical collector",
+			collectors: []string{"logical_switch"},
+			src: &FakeDataSource{
+				System: system,
+				Errors: map[string]error{"GetLogicalSwitches": errors.New("transaction failed")},
+			},
+			metrics: []string{"ovn_logical_switch_info", "ovn_scrape_collector_success", "ovn_up"},
+			want: []string{
+				`ovn_scrape_collector_success{collector="logical_switch"} 0`,
+				`ovn_scrape_collector_success{collector="system_info"} 1`,
+				`ovn_up{} 0`,
+			},
+		},
+		{
+			name:       "memory of the components supporting it",
+			collectors: []string{"memory"},
+			src: &FakeDataSource{
+				System: system,
+				AppCommands: map[string]map[string]bool{
+					"ovsdb-server":            {"memory/show": true},
+					"ovsdb-server-southbound": {"memory/show": true},
+					"ovsdb-server-northbound": {"memory/show": true},
+				},
+				Memory: map[string]map[string]float64{
+					"ovsdb-server":            {"cells": 10},
+					"ovsdb-server-southbound": {"cells": 20, "monitors": 3},
+				},
+				Errors: map[string]error{"GetAppMemoryMetrics/ovsdb-server-northbound": errors.New("timeout")},
+			},
+			metrics: []string{"ovn_memory_usage", "ovn_scrape_collector_success"},
+			want: []string{
+				`ovn_memory_usage{component="ovsdb-server",facility="cells",system_id="host-1"} 10`,
+				`ovn_memory_usage{component="ovsdb-server-southbound",facility="cells",system_id="host-1"} 20`,
+				`ovn_memory_usage{component="ovsdb-server-southbound",facility="monitors",system_id="host-1"} 3`,
+				`ovn_scrape_collector_success{collector="memory"} 0`,
+				`ovn_scrape_collector_success{collector="system_info"} 1`,
+			},
+		},
+		{
+			name:       "network ports of standalone databases",
+			collectors: []string{"network_port"},
+			src: &FakeDataSource{
+				System: system,
+				Ports: map[string]map[string]int{
+					"ovsdb-server-southbound": {"default": 1, "ssl": 0},
+					"ovsdb-server-northbound": {"default": 1, "ssl": 1},
+				},
+			},
+			metrics: []string{"ovn_network_port"},
+			want: []string{
+				`ovn_network_port{component="ovsdb-server-northbound",system_id="host-1",usage="default"} 1`,
+				`ovn_network_port{component="ovsdb-server-northbound",system_id="host-1",usage="ssl"} 1`,
+				`ovn_network_port{component="ovsdb-server-southbound",system_id="host-1",usage="default"} 1`,
+				`ovn_network_port{component="ovsdb-server-southbound",system_id="host-1",usage="ssl"} 0`,
+			},
+		},
+		{
+			name:       "server status of the connected database",
+			collectors: []string{"server_status"},
+			src: &FakeDataSource{
+				System: system,
+				ServerDatabases: map[string][]ServerDatabase{
+					"ovsdb-server-southbound": {
+						{Name: "_Server", Model: "standalone", Connected: true},
+						{Name: "OVN_Southbound", Model: "clustered", Connected: true, Leader: true, ClusterID: "cid", ServerID: "sid"},
+					},
+				},
+			},
+			metrics: []string{"ovn_server_database_connected", "ovn_server_database_leader", "ovn_scrape_collector_success"},
+			want: []string{
+				`ovn_scrape_collector_success{collector="server_status"} 1`,
+				`ovn_scrape_collector_success{collector="system_info"} 1`,
+				`ovn_server_database_connected{cluster_uuid="cid",component="ovsdb-server-southbound",database="OVN_Southbound",model="clustered",server_uuid="sid",system_id="host-1"} 1`,
+				`ovn_server_database_leader{cluster_uuid="cid",component="ovsdb-server-southbound",database="OVN_Southbound",server_uuid="sid",system_id="host-1"} 1`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newFakeExporter(t, tc.src, tc.collectors...)
+			got := gatherSeries(t, e, tc.metrics...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected series\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
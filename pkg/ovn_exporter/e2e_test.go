@@ -0,0 +1,272 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/greenpau/ovn_exporter/pkg/ovntest"
+)
+
+// fakeOVN is an OVN stack served by in-process OVSDB and unixctl servers.
+// The pid files name the test process, as the process information is read
+// from /proc.
+type fakeOVN struct {
+	cfg      *Config
+	systemID string
+	closers  []func() error
+}
+
+func startFakeOVN(tb testing.TB, topology ovntest.Topology) *fakeOVN {
+	tb.Helper()
+	if runtime.GOOS != "linux" {
+		tb.Skip("the process information is only available on Linux")
+	}
+	dir := tb.TempDir()
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	f := &fakeOVN{cfg: DefaultConfig(), systemID: "5f5a0a8b-6f3c-4d8e-9b1a-2c3d4e5f6a7b"}
+	tb.Cleanup(f.close)
+	pid := os.Getpid()
+	// The library skips the events of a log file until it has recorded a
+	// non-zero offset, so the logs must not be empty.
+	logStart := "2026-10-16T07:59:59.000Z|00000|vlog|INFO|opened log file\n"
+	files := map[string]string{
+		"system-id.conf":      f.systemID + "\n",
+		"ovsdb-server.pid":    fmt.Sprintf("%d\n", pid),
+		"ovnnb_db.pid":        fmt.Sprintf("%d\n", pid),
+		"ovnsb_db.pid":        fmt.Sprintf("%d\n", pid),
+		"ovn-northd.pid":      fmt.Sprintf("%d\n", pid),
+		"ovs-vswitchd.pid":    fmt.Sprintf("%d\n", pid),
+		"ovsdb-server.log":    logStart,
+		"ovsdb-server-nb.log": logStart,
+		"ovsdb-server-sb.log": logStart,
+		"ovn-northd.log":      logStart,
+		"ovs-vswitchd.log":    logStart,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path(name), []byte(content), 0644); err != nil {
+			tb.Fatalf("expected no error, but got %q", err)
+		}
+	}
+
+	vswitch, err := ovntest.NewVswitchDatabase(f.systemID, "node1", dir)
+	if err != nil {
+		tb.Fatalf("expected no error, but got %q", err)
+	}
+	northbound, southbound, err := ovntest.NewTopology(topology)
+	if err != nil {
+		tb.Fatalf("expected no error, but got %q", err)
+	}
+	statuses := []ovntest.ClusterStatus{}
+	for i, db := range []*ovntest.Database{northbound, southbound} {
+		db.Model = "clustered"
+		db.Leader = true
+		db.ClusterID = fmt.Sprintf("c%d000000-0000-4000-8000-000000000000", i+1)
+		db.ServerID = fmt.Sprintf("a%d000000-0000-4000-8000-000000000000", i+1)
+		statuses = append(statuses, ovntest.ClusterStatus{
+			Database:  db.Name(),
+			ClusterID: db.ClusterID,
+			ServerID:  db.ServerID,
+			Address:   fmt.Sprintf("tcp:192.0.2.1:%d", 6643+i),
+			Role:      "leader",
+			Term:      3,
+			Leader:    "self",
+			Vote:      "self",
+			LogLow:    10,
+			LogHigh:   20,
+			Servers: []ovntest.ClusterServer{
+				{ID: db.ServerID, Address: fmt.Sprintf("tcp:192.0.2.1:%d", 6643+i), NextIndex: 20, MatchIndex: 19},
+			},
+		})
+	}
+	for name, db := range map[string]*ovntest.Database{"db.sock": vswitch, "nb.sock": northbound, "sb.sock": southbound} {
+		s, err := ovntest.NewServer(path(name), db)
+		if err != nil {
+			tb.Fatalf("expected no error, but got %q", err)
+		}
+		f.closers = append(f.closers, s.Close)
+	}
+	for _, name := range []string{fmt.Sprintf("ovsdb-server.%d.ctl", pid), "nb.ctl", "sb.ctl"} {
+		s, err := ovntest.NewUnixctlServer(path(name))
+		if err != nil {
+			tb.Fatalf("expected no error, but got %q", err)
+		}
+		f.closers = append(f.closers, s.Close)
+		s.HandleOutput("coverage/show", ovntest.CoverageOutput([]ovntest.CoverageCounter{
+			{Name: "txn_unchanged", Rates: [3]float64{0.2, 0.1, 0.05}, Total: 42},
+		}))
+		s.HandleOutput("memory/show", ovntest.MemoryOutput(map[string]int{"cells": 100, "monitors": 2}))
+		if name != fmt.Sprintf("ovsdb-server.%d.ctl", pid) {
+			s.Handle("cluster/status DB", ovntest.ClusterStatusHandler(statuses...))
+		}
+	}
+
+	cfg := f.cfg
+	cfg.System.RunDir = dir
+	cfg.Database.Vswitch.Socket.Remote = "unix:" + path("db.sock")
+	cfg.Database.Vswitch.File.Log.Path = path("ovsdb-server.log")
+	cfg.Database.Vswitch.File.Pid.Path = path("ovsdb-server.pid")
+	cfg.Database.Vswitch.File.SystemID.Path = path("system-id.conf")
+	cfg.Database.Northbound.Socket.Remote = "unix:" + path("nb.sock")
+	cfg.Database.Northbound.Socket.Control = "unix:" + path("nb.ctl")
+	cfg.Database.Northbound.File.Log.Path = path("ovsdb-server-nb.log")
+	cfg.Database.Northbound.File.Pid.Path = path("ovnnb_db.pid")
+	cfg.Database.Southbound.Socket.Remote = "unix:" + path("sb.sock")
+	cfg.Database.Southbound.Socket.Control = "unix:" + path("sb.ctl")
+	cfg.Database.Southbound.File.Log.Path = path("ovsdb-server-sb.log")
+	cfg.Database.Southbound.File.Pid.Path = path("ovnsb_db.pid")
+	cfg.Service.Northd.File.Log.Path = path("ovn-northd.log")
+	cfg.Service.Northd.File.Pid.Path = path("ovn-northd.pid")
+	cfg.Service.Vswitchd.File.Log.Path = path("ovs-vswitchd.log")
+	cfg.Service.Vswitchd.File.Pid.Path = path("ovs-vswitchd.pid")
+	// The network ports are those of the test process.
+	disabled, enabled := false, true
+	cfg.Collectors["network_port"] = CollectorConfig{Enabled: &disabled}
+	cfg.Collectors["server_status"] = CollectorConfig{Enabled: &enabled}
+	return f
+}
+
+func (f *fakeOVN) close() {
+	for _, close := range f.closers {
+		close()
+	}
+}
+
+// newExporter returns an exporter connected to the fake OVN stack.
+func (f *fakeOVN) newExporter(tb testing.TB) *Exporter {
+	tb.Helper()
+	e, err := NewExporter(Options{})
+	if err != nil {
+		tb.Fatalf("expected no error, but got %q", err)
+	}
+	if err := e.ApplyConfig(f.cfg); err != nil {
+		tb.Fatalf("expected no error, but got %q", err)
+	}
+	if _, err := ExporterPerformClientCalls(e); err != nil {
+		tb.Fatalf("expected no error, but got %q", err)
+	}
+	tb.Cleanup(e.Client.Close)
+	return e
+}
+
+func TestExporterEndToEnd(t *testing.T) {
+	f := startFakeOVN(t, ovntest.Topology{Chassis: 2, Switches: 2, PortsPerSwitch: 3, Routers: 1, LoadBalancers: 3})
+	e := f.newExporter(t)
+	if e.Client.System.ID != f.systemID || e.Client.System.Hostname != "node1" {
+		t.Fatalf("unexpected system information %+v", e.Client.System)
+	}
+
+	// The first collection only records the size of the log files.
+	counts := make(map[string]int)
+	for _, s := range gatherSeries(t, e, "ovn_up", "ovn_scrape_collector_success", "ovn_chassis_info",
+		"ovn_logical_switch_info", "ovn_logical_switch_port_info", "ovn_logical_router_info",
+		"ovn_logical_router_port_info", "ovn_logical_router_default_route",
+		"ovn_logical_router_floating_ip_info", "ovn_lb_vip_backends", "ovn_lb_attachment",
+		"ovn_lb_backend_status", "ovn_lb_vip_backends_healthy", "ovn_pid", "ovn_coverage_total",
+		"ovn_memory_usage", "ovn_cluster_role", "ovn_server_database_connected", "ovn_log_file_size") {
+		if strings.HasPrefix(s, "ovn_up{") || strings.HasPrefix(s, "ovn_scrape_collector_success{") ||
+			strings.HasPrefix(s, "ovn_logical_router_default_route{") {
+			if !strings.HasSuffix(s, " 1") {
+				t.Errorf("expected %s to be 1", s)
+			}
+		}
+		if strings.HasPrefix(s, "ovn_lb_vip_backends{") && !strings.HasSuffix(s, " 3") {
+			t.Errorf("expected %s to be 3", s)
+		}
+		if strings.HasPrefix(s, "ovn_lb_vip_backends_healthy{") && !strings.HasSuffix(s, " 2") {
+			t.Errorf("expected %s to be 2", s)
+		}
+		counts[s[:strings.Index(s, "{")]]++
+	}
+	for name, want := range map[string]int{
+		"ovn_up":                              1,
+		"ovn_scrape_collector_success":        12,
+		"ovn_chassis_info":                    2,
+		"ovn_logical_switch_info":             2,
+		"ovn_logical_switch_port_info":        6,
+		"ovn_logical_router_info":             1,
+		"ovn_logical_router_port_info":        3,
+		"ovn_logical_router_default_route":    1,
+		"ovn_logical_router_floating_ip_info": 1,
+		"ovn_lb_vip_backends":                 3,
+		"ovn_lb_attachment":                   6,
+		"ovn_lb_backend_status":               9,
+		"ovn_lb_vip_backends_healthy":         3,
+		"ovn_pid":                             8,
+		"ovn_coverage_total":                  3,
+		"ovn_memory_usage":                    6,
+		"ovn_cluster_role":                    2,
+		"ovn_server_database_connected":       2,
+		"ovn_log_file_size":                   5,
+	} {
+		if counts[name] != want {
+			t.Errorf("expected %d %s series, but got %d", want, name, counts[name])
+		}
+	}
+
+	log, err := os.OpenFile(f.cfg.Service.Northd.File.Log.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	for _, line := range []string{
+		"2026-10-16T08:00:00.000Z|00001|northd|INFO|ovn-northd lock acquired",
+		"2026-10-16T08:00:01.000Z|00002|ovsdb_idl|WARN|connection dropped",
+		"2026-10-16T08:00:02.000Z|00003|ovsdb_idl|WARN|connection dropped",
+	} {
+		fmt.Fprintln(log, line)
+	}
+	log.Close()
+	got := gatherSeries(t, e, "ovn_log_event_count")
+	want := []string{
+		`ovn_log_event_count{component="ovn-northd",severity="info",source="northd",system_id="` + f.systemID + `"} 1`,
+		`ovn_log_event_count{component="ovn-northd",severity="warn",source="ovsdb_idl",system_id="` + f.systemID + `"} 2`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, but got %v", want, got)
+	}
+}
+
+func BenchmarkGatherMetrics(b *testing.B) {
+	for _, topology := range []ovntest.Topology{
+		{Chassis: 10, Switches: 10, PortsPerSwitch: 10, Routers: 2},
+		{Chassis: 100, Switches: 100, PortsPerSwitch: 20, Routers: 20},
+		{Chassis: 500, Switches: 500, PortsPerSwitch: 40, Routers: 100},
+	} {
+		name := fmt.Sprintf("chassis=%d/switches=%d/ports=%d", topology.Chassis, topology.Switches, topology.Switches*topology.PortsPerSwitch)
+		b.Run(name, func(b *testing.B) {
+			f := startFakeOVN(b, topology)
+			// The queries of the largest topologies take longer than the
+			// default timeout.
+			f.cfg.OVN.Timeout = 60
+			e := f.newExporter(b)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.GatherMetrics()
+			}
+			b.StopTimer()
+			if !e.IsUp() {
+				b.Fatalf("expected OVN to be up")
+			}
+		})
+	}
+}
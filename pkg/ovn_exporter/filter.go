@@ -0,0 +1,103 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsHandler serves the metrics of the gatherer, like the package's
+// MetricsHandler. When the request names collectors in the collect[]
+// parameter, as in /metrics?collect[]=cluster&collect[]=chassis, it serves
+// only the metrics of those collectors from the last collection instead,
+// along with the status of the exporter. The parameter filters the last
+// published snapshot: it neither runs the collectors nor waits for them, so
+// a collector that has not completed a run yet has no metrics.
+func (e *Exporter) MetricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	all := MetricsHandler(gatherer, e.logger)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names := r.URL.Query()["collect[]"]
+		if len(names) == 0 {
+			all.ServeHTTP(w, r)
+			return
+		}
+		selected, err := e.selectCollectors(names)
+		if err != nil {
+			level.Warn(e.logger).Log(
+				"msg", "rejected collector selection",
+				"collectors", fmt.Sprint(names),
+				"error", err.Error(),
+			)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(&selectedCollector{e: e, selected: selected})
+		MetricsHandler(registry, e.logger).ServeHTTP(w, r)
+	})
+}
+
+// selectCollectors returns the set of the named collectors. The collectors
+// must be enabled in the last published snapshot.
+func (e *Exporter) selectCollectors(names []string) (map[string]bool, error) {
+	enabled := make(map[string]bool)
+	if snapshot := e.snapshot.Load(); snapshot != nil {
+		for _, name := range snapshot.collectors {
+			enabled[name] = true
+		}
+	}
+	selected := make(map[string]bool)
+	for _, name := range names {
+		if !isCollectorSupported(name) {
+			return nil, fmt.Errorf("unsupported collector: %s", name)
+		}
+		selected[name] = true
+	}
+	for _, name := range names {
+		if !enabled[name] {
+			return nil, fmt.Errorf("collector %s is not enabled", name)
+		}
+	}
+	return selected, nil
+}
+
+// selectedCollector exposes the metrics of the selected collectors from
+// the last published snapshot of an exporter.
+type selectedCollector struct {
+	e        *Exporter
+	selected map[string]bool
+}
+
+// Describe implements prometheus.Collector. The collector is unchecked,
+// since it serves a subset of the metrics of the exporter.
+func (c *selectedCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *selectedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.e.collect(ch, c.selected)
+}
+
+// collectorNames returns the names of the collectors.
+func collectorNames(collectors []namedCollector) []string {
+	names := []string{}
+	for _, c := range collectors {
+		names = append(names, c.name)
+	}
+	return names
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsHandlerCollectorSelection(t *testing.T) {
+	src := &FakeDataSource{
+		System: SystemInfo{ID: "host-1"},
+		Chassis: []*ovsdb.OvnChassis{
+			{UUID: "c1", Name: "compute-1", IPAddress: net.ParseIP("192.0.2.1"), Up: 1},
+		},
+		LogicalSwitches: []*ovsdb.OvnLogicalSwitch{
+			{UUID: "s1", Name: "net-1", TunnelKey: 7},
+		},
+	}
+	e := newFakeExporter(t, src, "chassis", "logical_switch")
+	e.GatherMetrics()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	// The chassis added after the collection is not served until the next
+	// collection, whether collectors are selected or not.
+	src.Chassis = append(src.Chassis, &ovsdb.OvnChassis{UUID: "c2", Name: "compute-2", IPAddress: net.ParseIP("192.0.2.2")})
+
+	for _, tc := range []struct {
+		name     string
+		query    string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "all collectors of the last collection",
+			status:   http.StatusOK,
+			contains: []string{`ovn_chassis_info{ip="192.0.2.1"`, `ovn_logical_switch_info{`},
+			excludes: []string{`ovn_chassis_info{ip="192.0.2.2"`},
+		},
+		{
+			name:   "selected collector",
+			query:  "collect[]=chassis",
+			status: http.StatusOK,
+			contains: []string{
+				`ovn_chassis_info{ip="192.0.2.1"`,
+				`ovn_scrape_collector_success{collector="chassis"} 1`,
+				`ovn_scrape_collector_cache_age_seconds{collector="chassis"}`,
+				`ovn_up 1`,
+			},
+			excludes: []string{
+				`ovn_chassis_info{ip="192.0.2.2"`,
+				`ovn_logical_switch_info{`,
+				`collector="logical_switch"`,
+				`collector="system_info"`,
+			},
+		},
+		{
+			name:     "repeated collectors",
+			query:    "collect[]=logical_switch&collect[]=chassis&collect[]=chassis",
+			status:   http.StatusOK,
+			contains: []string{`ovn_chassis_info{ip="192.0.2.1"`, `ovn_logical_switch_info{`},
+		},
+		{
+			name:     "unknown collector",
+			query:    "collect[]=chassis&collect[]=routers",
+			status:   http.StatusBadRequest,
+			contains: []string{"unsupported collector: routers"},
+		},
+		{
+			name:     "disabled collector",
+			query:    "collect[]=cluster",
+			status:   http.StatusBadRequest,
+			contains: []string{"collector cluster is not enabled"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			e.MetricsHandler(registry).ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, but got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			body, _ := io.ReadAll(w.Body)
+			for _, s := range tc.contains {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("expected %q in the response, but got:\n%s", s, body)
+				}
+			}
+			for _, s := range tc.excludes {
+				if strings.Contains(string(body), s) {
+					t.Errorf("expected no %q in the response, but got:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsHandlerCollectorSelectionDuringCollection(t *testing.T) {
+	src := &FakeDataSource{
+		System: SystemInfo{ID: "host-1"},
+		Chassis: []*ovsdb.OvnChassis{
+			{UUID: "c1", Name: "compute-1", IPAddress: net.ParseIP("192.0.2.1"), Up: 1},
+		},
+	}
+	e := newFakeExporter(t, src, "chassis")
+	e.GatherMetrics()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	// A collection in progress holds the exporter's lock.
+	e.Lock()
+	defer e.Unlock()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		e.MetricsHandler(registry).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?collect[]=chassis", nil))
+		done <- w
+	}()
+	select {
+	case w := <-done:
+		if !strings.Contains(w.Body.String(), `ovn_chassis_info{ip="192.0.2.1"`) {
+			t.Errorf("expected the chassis of the last collection, but got:\n%s", w.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the scrape not to wait for the collection")
+	}
+}
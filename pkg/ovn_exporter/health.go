@@ -0,0 +1,159 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+)
+
+// ComponentStatus is the reachability of a database or a control socket
+// in the last collection.
+type ComponentStatus struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness is the outcome of the reachability checks of the last
+// collection.
+type Readiness struct {
+	Ready      bool              `json:"ready"`
+	CheckedAt  time.Time         `json:"checked_at"`
+	Failed     []string          `json:"failed"`
+	Components []ComponentStatus `json:"components"`
+}
+
+// checkReadiness checks whether the databases and the control sockets
+// required by the exporter are reachable. The control socket checks reuse
+// the command lists fetched by the collectors. In offline mode, it checks
+// whether the database files are readable instead.
+func (e *Exporter) checkReadiness(ctx context.Context) *Readiness {
+	if offline := e.offline.Load(); offline != nil {
+		return newReadiness(offline.readiness())
+	}
+	components := []ComponentStatus{}
+	cli := e.clientView()
+	databases, controlSockets := requiredComponents(collectorNames(e.collectors))
+	for _, conn := range e.connections {
+		if !databases[conn.component] {
+			continue
+		}
+		db := conn.database(cli)
+		status := ComponentStatus{Name: db.Name, Kind: "database"}
+		err := e.probeDatabase(ctx, db)
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Ready = err == nil
+		components = append(components, status)
+	}
+	sockets := []string{}
+	for _, component := range appComponents {
+		if controlSockets[component] {
+			sockets = append(sockets, component)
+		}
+	}
+	statuses := make([]ComponentStatus, len(sockets))
+	var wg sync.WaitGroup
+	for i, component := range sockets {
+		wg.Add(1)
+		go func(i int, component string) {
+			defer wg.Done()
+			status := ComponentStatus{Name: component, Kind: "control_socket"}
+			_, err := e.getAppCommands(ctx, component)
+			if err != nil {
+				status.Error = err.Error()
+			}
+			status.Ready = err == nil
+			statuses[i] = status
+		}(i, component)
+	}
+	wg.Wait()
+	components = append(components, statuses...)
+	return newReadiness(components)
+}
+
+// newReadiness returns the readiness of the components, which is ready
+// when all of them are.
+func newReadiness(components []ComponentStatus) *Readiness {
+	r := &Readiness{
+		Ready:      true,
+		CheckedAt:  time.Now(),
+		Failed:     []string{},
+		Components: components,
+	}
+	for _, c := range components {
+		if !c.Ready {
+			r.Ready = false
+			r.Failed = append(r.Failed, c.Name)
+		}
+	}
+	return r
+}
+
+// probeDatabase sends a list_dbs request to the database.
+func (e *Exporter) probeDatabase(ctx context.Context, db *ovsdb.OvsDatabase) error {
+	if db.Client == nil {
+		return fmt.Errorf("not connected")
+	}
+	client := db.Client
+	return e.runDatabaseStep(ctx, "Databases()", func() error {
+		_, err := client.Databases()
+		return err
+	})
+}
+
+// GetReadiness returns the readiness of the last collection, or nil when
+// no collection has completed.
+func (e *Exporter) GetReadiness() *Readiness {
+	return e.readiness.Load()
+}
+
+// HealthyHandler responds to liveness probes. It succeeds while the
+// process serves HTTP requests.
+func HealthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "OVN Exporter is Healthy.")
+	})
+}
+
+// ReadyHandler responds to readiness probes. It succeeds when the last
+// collection reached the required databases and control sockets, and
+// otherwise lists the failed components.
+func (e *Exporter) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readiness := e.GetReadiness()
+		if readiness == nil {
+			readiness = &Readiness{
+				Failed:     []string{"collection"},
+				Components: []ComponentStatus{},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !readiness.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(readiness)
+	})
+}
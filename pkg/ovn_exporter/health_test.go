@@ -0,0 +1,63 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthyHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HealthyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/healthy", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, but got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	e := newTestExporter(t)
+	get := func() (int, Readiness) {
+		rec := httptest.NewRecorder()
+		e.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+		var r Readiness
+		if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+			t.Fatalf("expected JSON body, but got %q", rec.Body.String())
+		}
+		return rec.Code, r
+	}
+
+	if code, _ := get(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d before a collection, but got %d", http.StatusServiceUnavailable, code)
+	}
+
+	// None of the databases exist in tests. With all the collectors
+	// disabled, only the Open_vSwitch database is required.
+	e.GatherMetrics()
+	code, r := get()
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, but got %d", http.StatusServiceUnavailable, code)
+	}
+	if len(r.Failed) != 1 || r.Failed[0] != "Open_vSwitch" {
+		t.Errorf("expected only Open_vSwitch to be reported as failed, but got %v", r.Failed)
+	}
+
+	e.readiness.Store(&Readiness{Ready: true, Failed: []string{}})
+	if code, _ := get(); code != http.StatusOK {
+		t.Errorf("expected status %d, but got %d", http.StatusOK, code)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoadBalancer is a load balancer in the Northbound database. The VIPs
+// map the virtual addresses, with their port, to their backends. The
+// switches, routers and groups are the names of the rows holding the load
+// balancer in their load_balancer column. The health check VIPs are the
+// VIPs whose backends are checked, and the IP port mappings map the IPs of
+// the backends to their logical port and the source IP of their checks.
+type LoadBalancer struct {
+	UUID            string              `json:"uuid"`
+	Name            string              `json:"name"`
+	Protocol        string              `json:"protocol"`
+	VIPs            map[string][]string `json:"vips"`
+	HealthChecks    []string            `json:"health_check"`
+	HealthCheckVIPs []string            `json:"health_check_vips"`
+	SelectionFields []string            `json:"selection_fields"`
+	IPPortMappings  map[string]string   `json:"ip_port_mappings"`
+	LogicalSwitches []string            `json:"logical_switches"`
+	LogicalRouters  []string            `json:"logical_routers"`
+	Groups          []string            `json:"load_balancer_groups"`
+}
+
+func (s *clientSource) GetLoadBalancers() ([]*LoadBalancer, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Northbound, "Load_Balancer", "SELECT _uuid, name, protocol, vips, health_check, selection_fields, ip_port_mappings FROM Load_Balancer")
+	if err != nil {
+		return nil, err
+	}
+	lbs := []*LoadBalancer{}
+	for _, row := range result.Rows {
+		lbs = append(lbs, &LoadBalancer{
+			UUID:            getStringColumn(row, "_uuid", result.Columns),
+			Name:            getStringColumn(row, "name", result.Columns),
+			Protocol:        lbProtocol(getStringColumn(row, "protocol", result.Columns)),
+			VIPs:            parseLoadBalancerVIPs(getStringMapColumn(row, "vips", result.Columns)),
+			HealthChecks:    getStringsColumn(row, "health_check", result.Columns),
+			SelectionFields: getStringsColumn(row, "selection_fields", result.Columns),
+			IPPortMappings:  getStringMapColumn(row, "ip_port_mappings", result.Columns),
+			LogicalSwitches: []string{},
+			LogicalRouters:  []string{},
+			Groups:          []string{},
+		})
+	}
+	result, err = transact(&cli.Database.Northbound, "Load_Balancer_Health_Check", "SELECT _uuid, vip FROM Load_Balancer_Health_Check")
+	if err != nil {
+		return nil, err
+	}
+	healthChecks := make(map[string]string)
+	for _, row := range result.Rows {
+		healthChecks[getStringColumn(row, "_uuid", result.Columns)] = getStringColumn(row, "vip", result.Columns)
+	}
+	bindHealthChecks(lbs, healthChecks)
+	index := indexLoadBalancers(lbs)
+	for _, t := range []struct {
+		table string
+		query string
+		add   func(lb *LoadBalancer, name string)
+	}{
+		{
+			table: "Logical_Switch",
+			query: "SELECT name, load_balancer FROM Logical_Switch",
+			add:   func(lb *LoadBalancer, name string) { lb.LogicalSwitches = append(lb.LogicalSwitches, name) },
+		},
+		{
+			table: "Logical_Router",
+			query: "SELECT name, load_balancer FROM Logical_Router",
+			add:   func(lb *LoadBalancer, name string) { lb.LogicalRouters = append(lb.LogicalRouters, name) },
+		},
+		{
+			table: "Load_Balancer_Group",
+			query: "SELECT name, load_balancer FROM Load_Balancer_Group",
+			add:   func(lb *LoadBalancer, name string) { lb.Groups = append(lb.Groups, name) },
+		},
+	} {
+		result, err := transact(&cli.Database.Northbound, t.table, t.query)
+		if err != nil {
+			if t.table == "Load_Balancer_Group" && isUnknownTable(err) {
+				// The groups appeared in OVN 21.12.
+				continue
+			}
+			return nil, err
+		}
+		for _, row := range result.Rows {
+			name := getStringColumn(row, "name", result.Columns)
+			for _, uuid := range getStringsColumn(row, "load_balancer", result.Columns) {
+				if lb, exists := index[uuid]; exists {
+					t.add(lb, name)
+				}
+			}
+		}
+	}
+	sortLoadBalancerAttachments(lbs)
+	return lbs, nil
+}
+
+// ServiceMonitor is the health check of a load balancer backend in the
+// Southbound database. The status is "online", "offline" or "error", and
+// it is unset until ovn-controller checks the backend.
+type ServiceMonitor struct {
+	UUID        string `json:"uuid"`
+	IP          string `json:"ip"`
+	Port        int64  `json:"port"`
+	Protocol    string `json:"protocol"`
+	LogicalPort string `json:"logical_port"`
+	Status      string `json:"status"`
+}
+
+// IsOnline returns whether the backend receives traffic. As in
+// ovn-northd, a backend that has not been checked yet is online.
+func (m *ServiceMonitor) IsOnline() bool {
+	return m.Status == "" || m.Status == "online"
+}
+
+func (s *clientSource) GetServiceMonitors() ([]*ServiceMonitor, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Southbound, "Service_Monitor", "SELECT _uuid, ip, port, protocol, logical_port, status FROM Service_Monitor")
+	if err != nil {
+		return nil, err
+	}
+	monitors := []*ServiceMonitor{}
+	for _, row := range result.Rows {
+		monitor := &ServiceMonitor{
+			UUID:        getStringColumn(row, "_uuid", result.Columns),
+			IP:          getStringColumn(row, "ip", result.Columns),
+			Protocol:    lbProtocol(getStringColumn(row, "protocol", result.Columns)),
+			LogicalPort: getStringColumn(row, "logical_port", result.Columns),
+			Status:      getStringColumn(row, "status", result.Columns),
+		}
+		monitor.Port, _ = getIntegerColumn(row, "port", result.Columns)
+		monitors = append(monitors, monitor)
+	}
+	return monitors, nil
+}
+
+// serviceMonitorKey returns the key of the service monitor of a backend.
+// As in ovn-northd, the monitors are keyed by logical port too, since the
+// backends of different tenants may share their IP.
+func serviceMonitorKey(ip string, port int64, protocol, logicalPort string) string {
+	return logicalPort + "/" + net.JoinHostPort(ip, strconv.FormatInt(port, 10)) + "/" + protocol
+}
+
+// indexServiceMonitors returns the service monitors by logical port,
+// backend and protocol.
+func indexServiceMonitors(monitors []*ServiceMonitor) map[string]*ServiceMonitor {
+	index := make(map[string]*ServiceMonitor)
+	for _, m := range monitors {
+		index[serviceMonitorKey(m.IP, m.Port, m.Protocol, m.LogicalPort)] = m
+	}
+	return index
+}
+
+// backendLogicalPort returns the logical port of a backend from the IP
+// port mappings of its load balancer, whose values are the logical port
+// followed by the source IP of the checks, e.g. "pod-1:10.244.0.2".
+func (lb *LoadBalancer) backendLogicalPort(ip string) (string, bool) {
+	mapping, exists := lb.IPPortMappings[ip]
+	if !exists {
+		return "", false
+	}
+	logicalPort, _, _ := strings.Cut(mapping, ":")
+	return logicalPort, logicalPort != ""
+}
+
+// splitLoadBalancerBackend returns the IP and the port of a backend, e.g.
+// "10.0.0.3:80" or "[fd00::3]:80". A backend without port is not valid.
+func splitLoadBalancerBackend(backend string) (string, int64, bool) {
+	host, port, err := net.SplitHostPort(backend)
+	if err != nil {
+		return "", 0, false
+	}
+	n, err := strconv.ParseInt(port, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return host, n, true
+}
+
+// bindHealthChecks sets the health check VIPs of the load balancers from
+// the VIPs of their health checks, by UUID. A VIP checked by several health
+// checks is kept once.
+func bindHealthChecks(lbs []*LoadBalancer, healthChecks map[string]string) {
+	for _, lb := range lbs {
+		vips := []string{}
+		for _, uuid := range lb.HealthChecks {
+			if vip, exists := healthChecks[uuid]; exists {
+				vips = append(vips, vip)
+			}
+		}
+		lb.HealthCheckVIPs = sortUniqueStrings(vips)
+	}
+}
+
+// lbProtocol returns the protocol of a load balancer, which is "tcp" when
+// unset.
+func lbProtocol(protocol string) string {
+	if protocol == "" {
+		return "tcp"
+	}
+	return protocol
+}
+
+// parseLoadBalancerVIPs returns the backends of each VIP of a load
+// balancer. The backends are a comma-separated list of addresses, which is
+// empty for a VIP without backends. A backend listed several times is kept
+// once, in the order of the list.
+func parseLoadBalancerVIPs(vips map[string]string) map[string][]string {
+	backends := make(map[string][]string)
+	for vip, list := range vips {
+		backends[vip] = []string{}
+		seen := make(map[string]bool)
+		for _, backend := range strings.Split(list, ",") {
+			if backend = strings.TrimSpace(backend); backend != "" && !seen[backend] {
+				seen[backend] = true
+				backends[vip] = append(backends[vip], backend)
+			}
+		}
+	}
+	return backends
+}
+
+// isUnknownTable returns whether a query failed because the table is not
+// in the schema of the database.
+func isUnknownTable(err error) bool {
+	return strings.Contains(err.Error(), "unknown table")
+}
+
+// indexLoadBalancers returns the load balancers by UUID.
+func indexLoadBalancers(lbs []*LoadBalancer) map[string]*LoadBalancer {
+	index := make(map[string]*LoadBalancer)
+	for _, lb := range lbs {
+		index[lb.UUID] = lb
+	}
+	return index
+}
+
+// sortLoadBalancerAttachments sorts the names of the rows the load
+// balancers are attached to. The names are not unique, so a name shared
+// by several rows is kept once.
+func sortLoadBalancerAttachments(lbs []*LoadBalancer) {
+	for _, lb := range lbs {
+		lb.LogicalSwitches = sortUniqueStrings(lb.LogicalSwitches)
+		lb.LogicalRouters = sortUniqueStrings(lb.LogicalRouters)
+		lb.Groups = sortUniqueStrings(lb.Groups)
+	}
+}
+
+// sortUniqueStrings sorts the strings and removes the duplicates.
+func sortUniqueStrings(s []string) []string {
+	sort.Strings(s)
+	unique := []string{}
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
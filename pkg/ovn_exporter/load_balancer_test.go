@@ -0,0 +1,86 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindHealthChecks(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		healthChecks []string
+		want         []string
+	}{
+		{
+			name:         "health checks",
+			healthChecks: []string{"hc2", "hc1"},
+			want:         []string{"10.96.0.10:53", "10.96.0.11:53"},
+		},
+		{
+			name:         "health checks of the same vip",
+			healthChecks: []string{"hc1", "hc3"},
+			want:         []string{"10.96.0.10:53"},
+		},
+		{
+			name:         "unknown health check",
+			healthChecks: []string{"hc4"},
+			want:         []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lb := &LoadBalancer{HealthChecks: tc.healthChecks}
+			bindHealthChecks([]*LoadBalancer{lb}, map[string]string{
+				"hc1": "10.96.0.10:53",
+				"hc2": "10.96.0.11:53",
+				"hc3": "10.96.0.10:53",
+			})
+			if !reflect.DeepEqual(lb.HealthCheckVIPs, tc.want) {
+				t.Errorf("expected %q, but got %q", tc.want, lb.HealthCheckVIPs)
+			}
+		})
+	}
+}
+
+func TestParseLoadBalancerVIPs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		vips map[string]string
+		want map[string][]string
+	}{
+		{
+			name: "backends",
+			vips: map[string]string{"10.96.0.10:53": "10.244.0.4:53, 10.244.0.3:53"},
+			want: map[string][]string{"10.96.0.10:53": {"10.244.0.4:53", "10.244.0.3:53"}},
+		},
+		{
+			name: "no backends",
+			vips: map[string]string{"10.96.0.10:53": ""},
+			want: map[string][]string{"10.96.0.10:53": {}},
+		},
+		{
+			name: "duplicate backends",
+			vips: map[string]string{"10.0.0.100:80": "10.0.0.3:80,10.0.0.4:80,10.0.0.3:80"},
+			want: map[string][]string{"10.0.0.100:80": {"10.0.0.3:80", "10.0.0.4:80"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseLoadBalancerVIPs(tc.vips); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
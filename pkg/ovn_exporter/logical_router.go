@@ -0,0 +1,294 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/greenpau/ovsdb"
+)
+
+// LogicalRouter is a logical router in the Northbound database, with the
+// tunnel key of its Southbound datapath.
+type LogicalRouter struct {
+	UUID         string            `json:"uuid"`
+	Name         string            `json:"name"`
+	Ports        []string          `json:"ports"`
+	StaticRoutes []string          `json:"static_routes"`
+	Policies     []string          `json:"policies"`
+	NAT          []string          `json:"nat"`
+	ExternalIDs  map[string]string `json:"external_ids"`
+	TunnelKey    uint64            `json:"tunnel_key"`
+	DatapathID   string            `json:"datapath_id"`
+}
+
+// LogicalRouterPort is a logical router port in the Northbound database.
+// The gateway chassis are the names of the chassis able to host a
+// distributed gateway port, by decreasing priority.
+type LogicalRouterPort struct {
+	UUID           string   `json:"uuid"`
+	Name           string   `json:"name"`
+	MacAddress     string   `json:"mac"`
+	Networks       []string `json:"networks"`
+	Peer           string   `json:"peer"`
+	GatewayChassis []string `json:"gateway_chassis"`
+}
+
+// LogicalRouterStaticRoute is a static route of a logical router in the
+// Northbound database. The routes of the main routing table have an empty
+// route table, and the policy is "dst-ip" when unset.
+type LogicalRouterStaticRoute struct {
+	UUID       string `json:"uuid"`
+	RouteTable string `json:"route_table"`
+	IPPrefix   string `json:"ip_prefix"`
+	Nexthop    string `json:"nexthop"`
+	OutputPort string `json:"output_port"`
+	Policy     string `json:"policy"`
+	BFD        bool   `json:"bfd"`
+}
+
+// LogicalRouterPolicy is a routing policy of a logical router in the
+// Northbound database.
+type LogicalRouterPolicy struct {
+	UUID     string `json:"uuid"`
+	Priority int64  `json:"priority"`
+	Match    string `json:"match"`
+	Action   string `json:"action"`
+}
+
+// LogicalRouterNAT is a NAT rule of a logical router in the Northbound
+// database. Its type is "snat", "dnat" or "dnat_and_snat".
+type LogicalRouterNAT struct {
+	UUID        string `json:"uuid"`
+	Type        string `json:"type"`
+	ExternalIP  string `json:"external_ip"`
+	ExternalMac string `json:"external_mac"`
+	LogicalIP   string `json:"logical_ip"`
+	LogicalPort string `json:"logical_port"`
+}
+
+// IsDistributed returns true when the rule is handled on the chassis of
+// its logical port rather than on the gateway chassis. OVN distributes
+// the dnat_and_snat rules with both a logical port and an external MAC.
+func (n *LogicalRouterNAT) IsDistributed() bool {
+	return n.Type == "dnat_and_snat" && n.LogicalPort != "" && n.ExternalMac != ""
+}
+
+// gatewayChassis is a row of the Gateway_Chassis table.
+type gatewayChassis struct {
+	chassisName string
+	priority    int64
+}
+
+// transact runs a query against a database and wraps its errors the way
+// the queries of the ovsdb package do.
+func transact(db *ovsdb.OvsDatabase, table, query string) (ovsdb.Result, error) {
+	result, err := db.Client.Transact(db.Name, query)
+	if err != nil {
+		return result, fmt.Errorf("%s: '%s' table error: %s", db.Name, table, err)
+	}
+	return result, nil
+}
+
+func (s *clientSource) GetLogicalRouters() ([]*LogicalRouter, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Northbound, "Logical_Router", "SELECT _uuid, name, ports, static_routes, policies, nat, external_ids FROM Logical_Router")
+	if err != nil {
+		return nil, err
+	}
+	routers := []*LogicalRouter{}
+	for _, row := range result.Rows {
+		routers = append(routers, &LogicalRouter{
+			UUID:         getStringColumn(row, "_uuid", result.Columns),
+			Name:         getStringColumn(row, "name", result.Columns),
+			Ports:        getStringsColumn(row, "ports", result.Columns),
+			StaticRoutes: getStringsColumn(row, "static_routes", result.Columns),
+			Policies:     getStringsColumn(row, "policies", result.Columns),
+			NAT:          getStringsColumn(row, "nat", result.Columns),
+			ExternalIDs:  getStringMapColumn(row, "external_ids", result.Columns),
+		})
+	}
+	result, err = transact(&cli.Database.Southbound, "Datapath_Binding", "SELECT _uuid, external_ids, tunnel_key FROM Datapath_Binding")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range result.Rows {
+		tunnelKey, ok := getIntegerColumn(row, "tunnel_key", result.Columns)
+		if !ok {
+			continue
+		}
+		bindRouterDatapath(
+			routers,
+			getStringMapColumn(row, "external_ids", result.Columns)["logical-router"],
+			getStringColumn(row, "_uuid", result.Columns),
+			tunnelKey,
+		)
+	}
+	return routers, nil
+}
+
+func (s *clientSource) GetLogicalRouterPorts() ([]*LogicalRouterPort, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Northbound, "Gateway_Chassis", "SELECT _uuid, chassis_name, priority FROM Gateway_Chassis")
+	if err != nil {
+		return nil, err
+	}
+	gateways := make(map[string]gatewayChassis)
+	for _, row := range result.Rows {
+		priority, _ := getIntegerColumn(row, "priority", result.Columns)
+		gateways[getStringColumn(row, "_uuid", result.Columns)] = gatewayChassis{
+			chassisName: getStringColumn(row, "chassis_name", result.Columns),
+			priority:    priority,
+		}
+	}
+	result, err = transact(&cli.Database.Northbound, "Logical_Router_Port", "SELECT _uuid, name, mac, networks, peer, gateway_chassis FROM Logical_Router_Port")
+	if err != nil {
+		return nil, err
+	}
+	ports := []*LogicalRouterPort{}
+	for _, row := range result.Rows {
+		ports = append(ports, &LogicalRouterPort{
+			UUID:           getStringColumn(row, "_uuid", result.Columns),
+			Name:           getStringColumn(row, "name", result.Columns),
+			MacAddress:     getStringColumn(row, "mac", result.Columns),
+			Networks:       getStringsColumn(row, "networks", result.Columns),
+			Peer:           getStringColumn(row, "peer", result.Columns),
+			GatewayChassis: sortGatewayChassis(gateways, getStringsColumn(row, "gateway_chassis", result.Columns)),
+		})
+	}
+	return ports, nil
+}
+
+// GetLogicalRouterStaticRoutes returns the static routes in the Northbound
+// database. The route_table and bfd columns are recent additions to the
+// schema, so all the columns are selected and the missing ones are unset.
+func (s *clientSource) GetLogicalRouterStaticRoutes() ([]*LogicalRouterStaticRoute, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Northbound, "Logical_Router_Static_Route", "SELECT * FROM Logical_Router_Static_Route")
+	if err != nil {
+		return nil, err
+	}
+	routes := []*LogicalRouterStaticRoute{}
+	for _, row := range result.Rows {
+		routes = append(routes, &LogicalRouterStaticRoute{
+			UUID:       getStringColumn(row, "_uuid", result.Columns),
+			RouteTable: getStringColumn(row, "route_table", result.Columns),
+			IPPrefix:   getStringColumn(row, "ip_prefix", result.Columns),
+			Nexthop:    getStringColumn(row, "nexthop", result.Columns),
+			OutputPort: getStringColumn(row, "output_port", result.Columns),
+			Policy:     routePolicy(getStringColumn(row, "policy", result.Columns)),
+			BFD:        getStringColumn(row, "bfd", result.Columns) != "",
+		})
+	}
+	return routes, nil
+}
+
+func (s *clientSource) GetLogicalRouterPolicies() ([]*LogicalRouterPolicy, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Northbound, "Logical_Router_Policy", "SELECT _uuid, priority, match, action FROM Logical_Router_Policy")
+	if err != nil {
+		return nil, err
+	}
+	policies := []*LogicalRouterPolicy{}
+	for _, row := range result.Rows {
+		priority, _ := getIntegerColumn(row, "priority", result.Columns)
+		policies = append(policies, &LogicalRouterPolicy{
+			UUID:     getStringColumn(row, "_uuid", result.Columns),
+			Priority: priority,
+			Match:    getStringColumn(row, "match", result.Columns),
+			Action:   getStringColumn(row, "action", result.Columns),
+		})
+	}
+	return policies, nil
+}
+
+func (s *clientSource) GetLogicalRouterNATs() ([]*LogicalRouterNAT, error) {
+	cli := s.e.clientView()
+	result, err := transact(&cli.Database.Northbound, "NAT", "SELECT _uuid, type, external_ip, external_mac, logical_ip, logical_port FROM NAT")
+	if err != nil {
+		return nil, err
+	}
+	nats := []*LogicalRouterNAT{}
+	for _, row := range result.Rows {
+		nats = append(nats, &LogicalRouterNAT{
+			UUID:        getStringColumn(row, "_uuid", result.Columns),
+			Type:        getStringColumn(row, "type", result.Columns),
+			ExternalIP:  getStringColumn(row, "external_ip", result.Columns),
+			ExternalMac: getStringColumn(row, "external_mac", result.Columns),
+			LogicalIP:   getStringColumn(row, "logical_ip", result.Columns),
+			LogicalPort: getStringColumn(row, "logical_port", result.Columns),
+		})
+	}
+	return nats, nil
+}
+
+// routePolicy returns the policy of a static route, which is "dst-ip" when
+// unset.
+func routePolicy(policy string) string {
+	if policy == "" {
+		return "dst-ip"
+	}
+	return policy
+}
+
+// isDefaultRoute returns true when the prefix of a route matches every
+// IPv4 or IPv6 destination.
+func isDefaultRoute(prefix string) bool {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return false
+	}
+	ones, _ := network.Mask.Size()
+	return ones == 0
+}
+
+// bindRouterDatapath sets the datapath and tunnel key of the router a
+// Southbound datapath binding references.
+func bindRouterDatapath(routers []*LogicalRouter, routerUUID, datapathUUID string, tunnelKey int64) {
+	if routerUUID == "" {
+		return
+	}
+	for _, router := range routers {
+		if router.UUID == routerUUID {
+			router.TunnelKey = uint64(tunnelKey)
+			router.DatapathID = datapathUUID
+			return
+		}
+	}
+}
+
+// sortGatewayChassis returns the names of the chassis of the gateway
+// chassis rows, by decreasing priority.
+func sortGatewayChassis(gateways map[string]gatewayChassis, uuids []string) []string {
+	rows := []gatewayChassis{}
+	for _, uuid := range uuids {
+		if gw, exists := gateways[uuid]; exists {
+			rows = append(rows, gw)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].priority != rows[j].priority {
+			return rows[i].priority > rows[j].priority
+		}
+		return rows[i].chassisName < rows[j].chassisName
+	})
+	names := []string{}
+	for _, gw := range rows {
+		names = append(names, gw.chassisName)
+	}
+	return names
+}
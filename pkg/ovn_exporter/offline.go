@@ -0,0 +1,557 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+)
+
+// offlineDatabases reads the OVN_Northbound and OVN_Southbound databases
+// from their files, rather than querying the servers. It returns the same
+// data as the queries of the ovsdb package, so that the collectors report
+// the same metrics when the servers are down.
+type offlineDatabases struct {
+	northbound *dbFileCache
+	southbound *dbFileCache
+}
+
+// dbFileCache holds a database file, which is read again when its size or
+// modification time change.
+type dbFileCache struct {
+	name    string
+	path    string
+	mu      sync.Mutex
+	size    int64
+	modTime time.Time
+	db      *dbFile
+}
+
+func newOfflineDatabases(northbound, southbound DatabaseConfig) *offlineDatabases {
+	return &offlineDatabases{
+		northbound: &dbFileCache{name: northbound.Name, path: northbound.File.Data.Path},
+		southbound: &dbFileCache{name: southbound.Name, path: southbound.File.Data.Path},
+	}
+}
+
+// sameFiles returns true when the databases are read from the files of the
+// configuration.
+func (d *offlineDatabases) sameFiles(northbound, southbound DatabaseConfig) bool {
+	return d.northbound.name == northbound.Name && d.northbound.path == northbound.File.Data.Path &&
+		d.southbound.name == southbound.Name && d.southbound.path == southbound.File.Data.Path
+}
+
+// load returns the database, reading the file when it changed since it was
+// last read.
+func (c *dbFileCache) load() (*dbFile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", c.name, err)
+	}
+	if c.db != nil && fi.Size() == c.size && fi.ModTime().Equal(c.modTime) {
+		return c.db, nil
+	}
+	db, err := readDBFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", c.name, err)
+	}
+	if db.schema.name != c.name {
+		return nil, fmt.Errorf("%s: file %s holds the %s database", c.name, c.path, db.schema.name)
+	}
+	c.db = db
+	c.size = fi.Size()
+	c.modTime = fi.ModTime()
+	return db, nil
+}
+
+// readiness returns the state of the database files. The files are
+// loaded from the cache of the last collection when unchanged.
+func (d *offlineDatabases) readiness() []ComponentStatus {
+	components := []ComponentStatus{}
+	for _, c := range []*dbFileCache{d.northbound, d.southbound} {
+		status := ComponentStatus{Name: c.name, Kind: "database_file"}
+		if _, err := c.load(); err != nil {
+			status.Error = err.Error()
+		}
+		status.Ready = status.Error == ""
+		components = append(components, status)
+	}
+	return components
+}
+
+// GetChassis returns the chassis in the Southbound database, as
+// ovsdb.OvnClient.GetChassis does.
+func (d *offlineDatabases) GetChassis() ([]*ovsdb.OvnChassis, error) {
+	sb, err := d.southbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sb.rows("Chassis")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no chassis found", sb.schema.name)
+	}
+	chassis := []*ovsdb.OvnChassis{}
+	for _, r := range rows {
+		name, ok := r.row.str("name")
+		if !ok {
+			continue
+		}
+		encaps, ok := r.row.uuid("encaps")
+		if !ok {
+			continue
+		}
+		c := &ovsdb.OvnChassis{
+			UUID:     r.uuid,
+			Name:     name,
+			Ports:    []string{},
+			Switches: []string{},
+		}
+		c.Encaps.UUID = encaps
+		chassis = append(chassis, c)
+	}
+	rows, err = sb.rows("Encap")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no chassis found", sb.schema.name)
+	}
+	for _, r := range rows {
+		proto, _ := r.row.str("type")
+		chassisName, _ := r.row.str("chassis_name")
+		ip, _ := r.row.str("ip")
+		for _, c := range chassis {
+			if c.Encaps.UUID != r.uuid || c.Name != chassisName {
+				continue
+			}
+			c.IPAddress = net.ParseIP(ip)
+			c.Encaps.Proto = proto
+			break
+		}
+	}
+	return chassis, nil
+}
+
+// GetLogicalSwitches returns the logical switches in the Northbound
+// database, with the tunnel keys of their Southbound datapaths, as
+// ovsdb.OvnClient.GetLogicalSwitches does.
+func (d *offlineDatabases) GetLogicalSwitches() ([]*ovsdb.OvnLogicalSwitch, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	sb, err := d.southbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Logical_Switch")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no switch found", nb.schema.name)
+	}
+	switches := []*ovsdb.OvnLogicalSwitch{}
+	for _, r := range rows {
+		name, ok := r.row.str("name")
+		if !ok {
+			continue
+		}
+		switches = append(switches, &ovsdb.OvnLogicalSwitch{
+			UUID:        r.uuid,
+			Name:        name,
+			Ports:       r.row.uuids("ports"),
+			ExternalIDs: r.row.stringMap("external_ids"),
+		})
+	}
+	rows, err = sb.rows("Datapath_Binding")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no datapath binding found", sb.schema.name)
+	}
+	for _, r := range rows {
+		tunnelKey, ok := r.row.integer("tunnel_key")
+		if !ok {
+			continue
+		}
+		switchUUID, exists := r.row.stringMap("external_ids")["logical-switch"]
+		if !exists {
+			continue
+		}
+		for _, sw := range switches {
+			if sw.UUID == switchUUID {
+				sw.TunnelKey = uint64(tunnelKey)
+				sw.DatapathID = r.uuid
+				break
+			}
+		}
+	}
+	return switches, nil
+}
+
+// GetLogicalSwitchPorts returns the logical switch ports in the Northbound
+// database, with their Southbound port bindings, as
+// ovsdb.OvnClient.GetLogicalSwitchPorts does.
+func (d *offlineDatabases) GetLogicalSwitchPorts() ([]*ovsdb.OvnLogicalSwitchPort, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	sb, err := d.southbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Logical_Switch_Port")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no logical switch port found", nb.schema.name)
+	}
+	ports := []*ovsdb.OvnLogicalSwitchPort{}
+	for _, r := range rows {
+		name, ok := r.row.str("name")
+		if !ok {
+			continue
+		}
+		port := &ovsdb.OvnLogicalSwitchPort{
+			UUID:        r.uuid,
+			Name:        name,
+			ExternalIDs: r.row.stringMap("external_ids"),
+		}
+		port.Up, _ = r.row.boolean("up")
+		for _, s := range r.row.strs("addresses") {
+			port.Addresses = append(port.Addresses, parseLogicalPortAddress(s))
+		}
+		ports = append(ports, port)
+	}
+	rows, err = sb.rows("Port_Binding")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no port binding found", sb.schema.name)
+	}
+	for _, r := range rows {
+		chassisUUID, ok := r.row.uuid("chassis")
+		if !ok {
+			continue
+		}
+		datapathUUID, ok := r.row.uuid("datapath")
+		if !ok {
+			continue
+		}
+		logicalPort, ok := r.row.str("logical_port")
+		if !ok {
+			continue
+		}
+		tunnelKey, ok := r.row.integer("tunnel_key")
+		if !ok {
+			continue
+		}
+		for _, port := range ports {
+			if port.Name == logicalPort {
+				port.PortBindingUUID = r.uuid
+				port.ChassisUUID = chassisUUID
+				port.DatapathUUID = datapathUUID
+				port.TunnelKey = uint64(tunnelKey)
+				break
+			}
+		}
+	}
+	return ports, nil
+}
+
+// GetLogicalRouters returns the logical routers in the Northbound
+// database, with the tunnel keys of their Southbound datapaths.
+func (d *offlineDatabases) GetLogicalRouters() ([]*LogicalRouter, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	sb, err := d.southbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Logical_Router")
+	if err != nil {
+		return nil, err
+	}
+	routers := []*LogicalRouter{}
+	for _, r := range rows {
+		name, _ := r.row.str("name")
+		routers = append(routers, &LogicalRouter{
+			UUID:         r.uuid,
+			Name:         name,
+			Ports:        r.row.uuids("ports"),
+			StaticRoutes: r.row.uuids("static_routes"),
+			Policies:     r.row.uuids("policies"),
+			NAT:          r.row.uuids("nat"),
+			ExternalIDs:  r.row.stringMap("external_ids"),
+		})
+	}
+	rows, err = sb.rows("Datapath_Binding")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		tunnelKey, ok := r.row.integer("tunnel_key")
+		if !ok {
+			continue
+		}
+		bindRouterDatapath(routers, r.row.stringMap("external_ids")["logical-router"], r.uuid, tunnelKey)
+	}
+	return routers, nil
+}
+
+// GetLogicalRouterPorts returns the logical router ports in the Northbound
+// database.
+func (d *offlineDatabases) GetLogicalRouterPorts() ([]*LogicalRouterPort, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Gateway_Chassis")
+	if err != nil {
+		return nil, err
+	}
+	gateways := make(map[string]gatewayChassis)
+	for _, r := range rows {
+		chassisName, _ := r.row.str("chassis_name")
+		priority, _ := r.row.integer("priority")
+		gateways[r.uuid] = gatewayChassis{chassisName: chassisName, priority: priority}
+	}
+	rows, err = nb.rows("Logical_Router_Port")
+	if err != nil {
+		return nil, err
+	}
+	ports := []*LogicalRouterPort{}
+	for _, r := range rows {
+		port := &LogicalRouterPort{
+			UUID:           r.uuid,
+			Networks:       r.row.strs("networks"),
+			GatewayChassis: sortGatewayChassis(gateways, r.row.uuids("gateway_chassis")),
+		}
+		port.Name, _ = r.row.str("name")
+		port.MacAddress, _ = r.row.str("mac")
+		port.Peer, _ = r.row.str("peer")
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// GetLogicalRouterStaticRoutes returns the static routes in the
+// Northbound database.
+func (d *offlineDatabases) GetLogicalRouterStaticRoutes() ([]*LogicalRouterStaticRoute, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Logical_Router_Static_Route")
+	if err != nil {
+		return nil, err
+	}
+	routes := []*LogicalRouterStaticRoute{}
+	for _, r := range rows {
+		route := &LogicalRouterStaticRoute{UUID: r.uuid}
+		route.RouteTable, _ = r.row.str("route_table")
+		route.IPPrefix, _ = r.row.str("ip_prefix")
+		route.Nexthop, _ = r.row.str("nexthop")
+		route.OutputPort, _ = r.row.str("output_port")
+		policy, _ := r.row.str("policy")
+		route.Policy = routePolicy(policy)
+		_, route.BFD = r.row.uuid("bfd")
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// GetLogicalRouterPolicies returns the routing policies in the Northbound
+// database.
+func (d *offlineDatabases) GetLogicalRouterPolicies() ([]*LogicalRouterPolicy, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Logical_Router_Policy")
+	if err != nil {
+		return nil, err
+	}
+	policies := []*LogicalRouterPolicy{}
+	for _, r := range rows {
+		policy := &LogicalRouterPolicy{UUID: r.uuid}
+		policy.Priority, _ = r.row.integer("priority")
+		policy.Match, _ = r.row.str("match")
+		policy.Action, _ = r.row.str("action")
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetLogicalRouterNATs returns the NAT rules in the Northbound database.
+func (d *offlineDatabases) GetLogicalRouterNATs() ([]*LogicalRouterNAT, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("NAT")
+	if err != nil {
+		return nil, err
+	}
+	nats := []*LogicalRouterNAT{}
+	for _, r := range rows {
+		nat := &LogicalRouterNAT{UUID: r.uuid}
+		nat.Type, _ = r.row.str("type")
+		nat.ExternalIP, _ = r.row.str("external_ip")
+		nat.ExternalMac, _ = r.row.str("external_mac")
+		nat.LogicalIP, _ = r.row.str("logical_ip")
+		nat.LogicalPort, _ = r.row.str("logical_port")
+		nats = append(nats, nat)
+	}
+	return nats, nil
+}
+
+// GetLoadBalancers returns the load balancers in the Northbound database.
+func (d *offlineDatabases) GetLoadBalancers() ([]*LoadBalancer, error) {
+	nb, err := d.northbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := nb.rows("Load_Balancer")
+	if err != nil {
+		return nil, err
+	}
+	lbs := []*LoadBalancer{}
+	for _, r := range rows {
+		lb := &LoadBalancer{
+			UUID:            r.uuid,
+			VIPs:            parseLoadBalancerVIPs(r.row.stringMap("vips")),
+			HealthChecks:    r.row.uuids("health_check"),
+			SelectionFields: r.row.strs("selection_fields"),
+			IPPortMappings:  r.row.stringMap("ip_port_mappings"),
+			LogicalSwitches: []string{},
+			LogicalRouters:  []string{},
+			Groups:          []string{},
+		}
+		lb.Name, _ = r.row.str("name")
+		protocol, _ := r.row.str("protocol")
+		lb.Protocol = lbProtocol(protocol)
+		lbs = append(lbs, lb)
+	}
+	rows, err = nb.rows("Load_Balancer_Health_Check")
+	if err != nil {
+		return nil, err
+	}
+	healthChecks := make(map[string]string)
+	for _, r := range rows {
+		healthChecks[r.uuid], _ = r.row.str("vip")
+	}
+	bindHealthChecks(lbs, healthChecks)
+	index := indexLoadBalancers(lbs)
+	for _, t := range []struct {
+		table string
+		add   func(lb *LoadBalancer, name string)
+	}{
+		{"Logical_Switch", func(lb *LoadBalancer, name string) { lb.LogicalSwitches = append(lb.LogicalSwitches, name) }},
+		{"Logical_Router", func(lb *LoadBalancer, name string) { lb.LogicalRouters = append(lb.LogicalRouters, name) }},
+		{"Load_Balancer_Group", func(lb *LoadBalancer, name string) { lb.Groups = append(lb.Groups, name) }},
+	} {
+		if _, exists := nb.schema.tables[t.table]; !exists {
+			continue
+		}
+		rows, err := nb.rows(t.table)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			name, _ := r.row.str("name")
+			for _, uuid := range r.row.uuids("load_balancer") {
+				if lb, exists := index[uuid]; exists {
+					t.add(lb, name)
+				}
+			}
+		}
+	}
+	sortLoadBalancerAttachments(lbs)
+	return lbs, nil
+}
+
+// GetServiceMonitors returns the service monitors in the Southbound
+// database.
+func (d *offlineDatabases) GetServiceMonitors() ([]*ServiceMonitor, error) {
+	sb, err := d.southbound.load()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sb.rows("Service_Monitor")
+	if err != nil {
+		return nil, err
+	}
+	monitors := []*ServiceMonitor{}
+	for _, r := range rows {
+		monitor := &ServiceMonitor{UUID: r.uuid}
+		monitor.IP, _ = r.row.str("ip")
+		monitor.Port, _ = r.row.integer("port")
+		protocol, _ := r.row.str("protocol")
+		monitor.Protocol = lbProtocol(protocol)
+		monitor.LogicalPort, _ = r.row.str("logical_port")
+		monitor.Status, _ = r.row.str("status")
+		monitors = append(monitors, monitor)
+	}
+	return monitors, nil
+}
+
+// parseLogicalPortAddress parses an entry of the addresses column of a
+// logical switch port the way the ovsdb package does.
+func parseLogicalPortAddress(s string) ovsdb.OvnLogicalSwitchPortAddress {
+	addrs := strings.Split(s, " ")
+	switch addrs[0] {
+	case "router":
+		return ovsdb.OvnLogicalSwitchPortAddress{Router: true}
+	case "unknown":
+		return ovsdb.OvnLogicalSwitchPortAddress{Unknown: true}
+	case "dynamic":
+		address := ovsdb.OvnLogicalSwitchPortAddress{Unknown: true}
+		for _, v := range addrs[1:] {
+			address.IPAddresses = append(address.IPAddresses, net.ParseIP(v))
+		}
+		return address
+	}
+	macAddr, _ := net.ParseMAC(addrs[0])
+	address := ovsdb.OvnLogicalSwitchPortAddress{MacAddress: macAddr}
+	if len(addrs) > 1 {
+		if addrs[1] == "dynamic" {
+			address.Dynamic = true
+		} else {
+			for _, v := range addrs[1:] {
+				address.IPAddresses = append(address.IPAddresses, net.ParseIP(v))
+			}
+		}
+	}
+	return address
+}
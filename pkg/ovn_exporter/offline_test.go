@@ -0,0 +1,377 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func newTestOfflineDatabases(northbound, southbound string) *offlineDatabases {
+	cfg := DefaultConfig()
+	cfg.Database.Northbound.File.Data.Path = northbound
+	cfg.Database.Southbound.File.Data.Path = southbound
+	return newOfflineDatabases(cfg.Database.Northbound, cfg.Database.Southbound)
+}
+
+func TestOfflineGetChassis(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	chassis, err := d.GetChassis()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got := []string{}
+	for _, c := range chassis {
+		got = append(got, strings.Join([]string{c.Name, c.Encaps.Proto, c.IPAddress.String()}, " "))
+	}
+	want := []string{"node1 geneve 192.168.0.1", "node2 geneve 192.168.0.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, but got %v", want, got)
+	}
+}
+
+func TestOfflineGetLogicalSwitches(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	switches, err := d.GetLogicalSwitches()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if len(switches) != 2 {
+		t.Fatalf("expected 2 switches, but got %d", len(switches))
+	}
+	sw0, sw1 := switches[0], switches[1]
+	if sw0.Name != "sw0" || sw0.TunnelKey != 1 || sw0.DatapathID != "d0d0d0d0-0000-4000-8000-0000000000d0" {
+		t.Errorf("unexpected switch %+v", sw0)
+	}
+	if len(sw0.Ports) != 2 {
+		t.Errorf("expected 2 ports on sw0, but got %v", sw0.Ports)
+	}
+	wantExternalIDs := map[string]string{"neutron:network_name": "net-0", "tier": "web"}
+	if !reflect.DeepEqual(sw0.ExternalIDs, wantExternalIDs) {
+		t.Errorf("expected external ids %v, but got %v", wantExternalIDs, sw0.ExternalIDs)
+	}
+	if sw1.Name != "sw1" || sw1.TunnelKey != 2 || !reflect.DeepEqual(sw1.Ports, []string{"a4e6c8d0-2f4b-46d8-9a1c-3e5b7d9f1a13"}) {
+		t.Errorf("unexpected switch %+v", sw1)
+	}
+}
+
+func TestOfflineGetLogicalSwitchPorts(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	ports, err := d.GetLogicalSwitchPorts()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got := make(map[string]string)
+	for _, p := range ports {
+		got[p.Name] = strings.Join([]string{p.ChassisUUID, p.DatapathUUID, p.PortBindingUUID}, " ")
+	}
+	want := map[string]string{
+		"vm1": "22222222-bbbb-4bbb-8bbb-000000000002 d0d0d0d0-0000-4000-8000-0000000000d0 b1b1b1b1-0000-4000-8000-0000000000b1",
+		"vm2": "11111111-aaaa-4aaa-8aaa-000000000001 d0d0d0d0-0000-4000-8000-0000000000d0 b2b2b2b2-0000-4000-8000-0000000000b2",
+		"vm3": "  ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, but got %v", want, got)
+	}
+	for _, p := range ports {
+		if p.Name != "vm2" {
+			continue
+		}
+		if p.Up || p.TunnelKey != 2 || len(p.Addresses) != 1 || len(p.Addresses[0].IPAddresses) != 2 {
+			t.Errorf("unexpected port %+v", p)
+		}
+	}
+}
+
+func TestOfflineGetLogicalRouters(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	routers, err := d.GetLogicalRouters()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if len(routers) != 1 {
+		t.Fatalf("expected 1 router, but got %d", len(routers))
+	}
+	want := &LogicalRouter{
+		UUID:  "6f000000-0000-4000-8000-0000000000f1",
+		Name:  "lr0",
+		Ports: []string{"7e000000-0000-4000-8000-0000000000e1", "7e000000-0000-4000-8000-0000000000e2"},
+		StaticRoutes: []string{
+			"5a000000-0000-4000-8000-0000000000a1",
+			"5a000000-0000-4000-8000-0000000000a2",
+			"5a000000-0000-4000-8000-0000000000a3",
+			"5a000000-0000-4000-8000-0000000000a4",
+		},
+		Policies: []string{"5b000000-0000-4000-8000-0000000000b1", "5b000000-0000-4000-8000-0000000000b2"},
+		NAT: []string{
+			"4a000000-0000-4000-8000-0000000000c1",
+			"4a000000-0000-4000-8000-0000000000c2",
+			"4a000000-0000-4000-8000-0000000000c3",
+		},
+		ExternalIDs: map[string]string{"neutron:router_name": "router-0"},
+		TunnelKey:   3,
+		DatapathID:  "d2d2d2d2-0000-4000-8000-0000000000d2",
+	}
+	if !reflect.DeepEqual(routers[0], want) {
+		t.Errorf("expected %+v, but got %+v", want, routers[0])
+	}
+
+	ports, err := d.GetLogicalRouterPorts()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got := []string{}
+	for _, p := range ports {
+		got = append(got, strings.Join([]string{p.Name, p.MacAddress, strings.Join(p.Networks, ","), p.Peer, strings.Join(p.GatewayChassis, ",")}, " "))
+	}
+	wantPorts := []string{
+		"lrp-sw0 00:00:00:00:ff:01 10.0.0.254/24  ",
+		"lrp-ext 00:00:00:00:ff:02 172.24.4.10/24,2001:db8::10/64  node2,node1",
+	}
+	if !reflect.DeepEqual(got, wantPorts) {
+		t.Errorf("expected %q, but got %q", wantPorts, got)
+	}
+}
+
+func TestOfflineGetLogicalRouterRoutes(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	routes, err := d.GetLogicalRouterStaticRoutes()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got := []string{}
+	for _, r := range routes {
+		got = append(got, fmt.Sprintf("%s %s %s %s %t", r.RouteTable, r.Policy, r.IPPrefix, r.Nexthop, r.BFD))
+	}
+	want := []string{
+		" dst-ip 0.0.0.0/0 172.24.4.1 true",
+		" dst-ip 192.168.100.0/24 10.0.0.1 false",
+		" dst-ip 192.168.100.0/24 10.0.0.2 false",
+		"rtb-1 src-ip 10.0.0.0/24 10.0.0.3 false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, but got %q", want, got)
+	}
+
+	policies, err := d.GetLogicalRouterPolicies()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got = []string{}
+	for _, p := range policies {
+		got = append(got, fmt.Sprintf("%d %s %s", p.Priority, p.Action, p.Match))
+	}
+	want = []string{"100 reroute ip4.src == 10.0.0.0/24", "90 drop ip4.dst == 198.51.100.0/24"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, but got %q", want, got)
+	}
+}
+
+func TestOfflineGetLogicalRouterNATs(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	nats, err := d.GetLogicalRouterNATs()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got := []string{}
+	for _, n := range nats {
+		got = append(got, fmt.Sprintf("%s %s %s %s %s %t", n.Type, n.ExternalIP, n.LogicalIP, n.LogicalPort, n.ExternalMac, n.IsDistributed()))
+	}
+	want := []string{
+		"snat 172.24.4.10 10.0.0.0/24   false",
+		"dnat_and_snat 172.24.4.101 10.0.0.1 vm1 fa:16:3e:00:00:01 true",
+		"dnat_and_snat 172.24.4.102 10.0.0.2   false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, but got %q", want, got)
+	}
+}
+
+func TestOfflineGetLoadBalancers(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	lbs, err := d.GetLoadBalancers()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	want := []*LoadBalancer{
+		{
+			UUID:            "3b000000-0000-4000-8000-0000000000b1",
+			Name:            "Service_default/kubernetes_TCP_cluster",
+			Protocol:        "tcp",
+			VIPs:            map[string][]string{"10.96.0.1:443": {"172.18.0.2:6443", "172.18.0.3:6443"}},
+			HealthChecks:    []string{},
+			HealthCheckVIPs: []string{},
+			SelectionFields: []string{"ip_dst", "ip_src"},
+			IPPortMappings:  map[string]string{},
+			LogicalSwitches: []string{},
+			LogicalRouters:  []string{},
+			Groups:          []string{"clusterLBGroup"},
+		},
+		{
+			UUID:            "3b000000-0000-4000-8000-0000000000b2",
+			Name:            "Service_kube-system/kube-dns_UDP_cluster",
+			Protocol:        "udp",
+			VIPs:            map[string][]string{"10.96.0.10:53": {"10.244.0.3:53", "10.244.0.4:53"}, "[fd00:10:96::a]:53": {}},
+			HealthChecks:    []string{"3c000000-0000-4000-8000-0000000000d1"},
+			HealthCheckVIPs: []string{"10.96.0.10:53"},
+			SelectionFields: []string{},
+			IPPortMappings:  map[string]string{"10.244.0.3": "coredns-a:10.244.0.2", "10.244.0.4": "coredns-b:10.244.0.2"},
+			LogicalSwitches: []string{},
+			LogicalRouters:  []string{},
+			Groups:          []string{"clusterLBGroup"},
+		},
+		{
+			UUID:            "3b000000-0000-4000-8000-0000000000b3",
+			Name:            "lb-web",
+			Protocol:        "tcp",
+			VIPs:            map[string][]string{"10.0.0.100:80": {}},
+			HealthChecks:    []string{},
+			HealthCheckVIPs: []string{},
+			SelectionFields: []string{},
+			IPPortMappings:  map[string]string{},
+			LogicalSwitches: []string{"sw0"},
+			LogicalRouters:  []string{"lr0"},
+			Groups:          []string{},
+		},
+	}
+	if !reflect.DeepEqual(lbs, want) {
+		t.Errorf("expected %+v, but got %+v", want, lbs)
+	}
+}
+
+func TestOfflineGetServiceMonitors(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnnb_db.db", "testdata/ovnsb_db.db")
+	monitors, err := d.GetServiceMonitors()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	got := []string{}
+	for _, m := range monitors {
+		got = append(got, fmt.Sprintf("%s %d %s %s %s %t", m.IP, m.Port, m.Protocol, m.LogicalPort, m.Status, m.IsOnline()))
+	}
+	want := []string{
+		"10.244.0.3 53 udp coredns-a online true",
+		"10.244.0.4 53 udp coredns-b offline false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, but got %q", want, got)
+	}
+}
+
+func TestOfflineDatabaseMismatch(t *testing.T) {
+	d := newTestOfflineDatabases("testdata/ovnsb_db.db", "testdata/ovnnb_db.db")
+	if _, err := d.GetLogicalSwitches(); err == nil {
+		t.Fatalf("expected error, but got none")
+	}
+}
+
+func TestOfflineDatabaseReload(t *testing.T) {
+	dir := t.TempDir()
+	northbound := filepath.Join(dir, "ovnnb_db.db")
+	b, err := os.ReadFile("testdata/ovnnb_db.db")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if err := os.WriteFile(northbound, b, 0644); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	d := newTestOfflineDatabases(northbound, "testdata/ovnsb_db.db")
+	if _, err := d.GetLogicalSwitches(); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+
+	b = append(b, dbRecord(t, dbFileMagicStandalone, map[string]interface{}{
+		"Logical_Switch": map[string]interface{}{
+			"8a2c4e6f-0b1d-4f3a-8c5e-7d9f1b3a5c02": nil,
+		},
+		"_is_diff": true,
+	})...)
+	if err := os.WriteFile(northbound, b, 0644); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	switches, err := d.GetLogicalSwitches()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if len(switches) != 1 {
+		t.Errorf("expected 1 switch after the file changed, but got %d", len(switches))
+	}
+}
+
+func TestOfflineGatherMetrics(t *testing.T) {
+	e := newTestExporter(t)
+	cfg := DefaultConfig()
+	cfg.OVN.Offline = true
+	cfg.Database.Northbound.File.Data.Path = "testdata/ovnnb_db.db"
+	cfg.Database.Southbound.File.Data.Path = "testdata/ovnsb_db.db"
+	if err := e.ApplyConfig(cfg); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	names := []string{}
+	for _, c := range e.collectors {
+		names = append(names, c.name)
+	}
+	if want := []string{"chassis", "logical_switch", "logical_switch_port", "logical_router", "load_balancer"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected collectors %v, but got %v", want, names)
+	}
+	e.GatherMetrics()
+	if !e.IsUp() {
+		t.Errorf("expected OVN to be up")
+	}
+	if r := e.GetReadiness(); r == nil || !r.Ready {
+		t.Errorf("expected the database files to be ready, but got %+v", r)
+	}
+
+	ch := make(chan prometheus.Metric, 256)
+	e.Collect(ch)
+	close(ch)
+	counts := make(map[*prometheus.Desc]int)
+	for m := range ch {
+		counts[m.Desc()]++
+		if m.Desc() == dbConnectionUp {
+			t.Errorf("expected no connection metrics in offline mode")
+		}
+		if m.Desc() != logicalSwitchTunnelKey {
+			continue
+		}
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatalf("expected no error, but got %q", err)
+		}
+		if v := pb.GetGauge().GetValue(); v != 1 && v != 2 {
+			t.Errorf("unexpected tunnel key %v", v)
+		}
+	}
+	for desc, want := range map[*prometheus.Desc]int{
+		chassisInfo:           2,
+		logicalSwitchInfo:     2,
+		logicalSwitchPortInfo: 3,
+		logicalRouterInfo:     1,
+		logicalRouterPortInfo: 2,
+		lbInfo:                3,
+		lbBackendStatus:       2,
+	} {
+		if counts[desc] != want {
+			t.Errorf("expected %d %s metrics, but got %d", want, desc, counts[desc])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/procfs"
+)
+
+// newCounter returns a counter with its creation time, for the _created
+// series of OpenMetrics. The counter has no creation time when the time is
+// zero.
+func newCounter(desc *prometheus.Desc, value float64, created time.Time, labelValues ...string) prometheus.Metric {
+	if created.IsZero() {
+		return prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labelValues...)
+	}
+	return prometheus.MustNewConstMetricWithCreatedTimestamp(desc, prometheus.CounterValue, value, created, labelValues...)
+}
+
+// processStartTime returns when the process of a component started, which
+// is when its in-memory counters were created. It is zero when unknown, e.g.
+// when the process runs in another PID namespace, or when the lookup does
+// not complete before the deadline of its step.
+func (e *Exporter) processStartTime(ctx context.Context, src DataSource, component string) time.Time {
+	var started time.Time
+	err := e.runStep(ctx, "GetProcessStartTime()", component, func() error {
+		var err error
+		started, err = src.GetProcessStartTime(component)
+		return err
+	})
+	// A step abandoned on timeout may still write started, so it is only
+	// read when the step returned.
+	if err != nil {
+		level.Debug(e.logger).Log(
+			"msg", "GetProcessStartTime() failed",
+			"component", component,
+			"system_id", e.Client.System.ID,
+			"error", err.Error(),
+		)
+		return time.Time{}
+	}
+	return started
+}
+
+// getProcessStartTime returns when the process with the ID started, from
+// its stat file in /proc.
+func getProcessStartTime(pid int) (time.Time, error) {
+	if pid <= 0 {
+		return time.Time{}, fmt.Errorf("invalid process ID %d", pid)
+	}
+	proc, err := procfs.NewProc(pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	stat, err := proc.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	started, err := stat.StartTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec, frac := math.Modf(started)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
+
+// SetCollectionTimestamps enables stamping the samples with the time of
+// the collection, rather than the time of the scrape.
+func (e *Exporter) SetCollectionTimestamps(enabled bool) {
+	e.collectionTimestamps.Store(enabled)
+}
+
+// MetricsHandler serves the metrics of the gatherer. It negotiates the
+// OpenMetrics format, which includes the _created series of counters.
+func MetricsHandler(gatherer prometheus.Gatherer, logger log.Logger) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		ErrorLog:                            promhttpLogger{logger},
+		ErrorHandling:                       promhttp.ContinueOnError,
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: true,
+	})
+}
+
+// promhttpLogger logs the errors of the metrics handler.
+type promhttpLogger struct {
+	logger log.Logger
+}
+
+func (l promhttpLogger) Println(v ...interface{}) {
+	level.Error(l.logger).Log("msg", fmt.Sprint(v...))
+}
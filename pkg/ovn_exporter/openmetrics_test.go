@@ -0,0 +1,152 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCoverageCreatedTimestamps(t *testing.T) {
+	started := time.Unix(1000, 0)
+	src := &FakeDataSource{
+		System: SystemInfo{ID: "host-1"},
+		AppCommands: map[string]map[string]bool{
+			"ovsdb-server":            {"coverage/show": true},
+			"ovsdb-server-northbound": {"coverage/show": true},
+		},
+		Coverage: map[string]map[string]map[string]float64{
+			"ovsdb-server":            {"poll_create_node": {"total": 5}},
+			"ovsdb-server-northbound": {"poll_create_node": {"total": 7}},
+		},
+		StartTimes: map[string]time.Time{
+			"ovsdb-server-northbound": started,
+		},
+	}
+	e := newFakeExporter(t, src, "coverage")
+	e.GatherMetrics()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	want := map[string]*time.Time{
+		"ovsdb-server":            nil,
+		"ovsdb-server-northbound": &started,
+	}
+	for _, family := range families {
+		if family.GetName() != "ovn_coverage_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var component string
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "component" {
+					component = l.GetValue()
+				}
+			}
+			expected, exists := want[component]
+			if !exists {
+				t.Errorf("unexpected component %s", component)
+				continue
+			}
+			delete(want, component)
+			created := m.GetCounter().GetCreatedTimestamp()
+			switch {
+			case expected == nil && created != nil:
+				t.Errorf("expected no creation time for %s, but got %s", component, created.AsTime())
+			case expected != nil && (created == nil || !created.AsTime().Equal(*expected)):
+				t.Errorf("expected creation time %s for %s, but got %v", expected, component, created)
+			}
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("expected coverage of %v", want)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	e := newTestExporter(t)
+	e.GatherMetrics()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	testcases := []struct {
+		name        string
+		accept      string
+		contentType string
+		created     bool
+	}{
+		{
+			name:        "text format",
+			contentType: "text/plain",
+		},
+		{
+			name:        "openmetrics format",
+			accept:      "application/openmetrics-text;version=1.0.0",
+			contentType: "application/openmetrics-text",
+			created:     true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			MetricsHandler(registry, e.logger).ServeHTTP(rec, req)
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.contentType) {
+				t.Errorf("expected content type %s, but got %s", tc.contentType, got)
+			}
+			body := rec.Body.String()
+			if got := strings.Contains(body, "ovn_failed_req_count_created"); got != tc.created {
+				t.Errorf("expected _created series %t, but got %t in:\n%s", tc.created, got, body)
+			}
+		})
+	}
+}
+
+func TestCollectionTimestamps(t *testing.T) {
+	e := newTestExporter(t)
+	e.GatherMetrics()
+	collect := func() []prometheus.Metric {
+		ch := make(chan prometheus.Metric, 100)
+		e.Collect(ch)
+		close(ch)
+		metrics := []prometheus.Metric{}
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		return metrics
+	}
+	for _, enabled := range []bool{false, true} {
+		e.SetCollectionTimestamps(enabled)
+		var m dto.Metric
+		if err := collect()[0].Write(&m); err != nil {
+			t.Fatalf("expected no error, but got %q", err)
+		}
+		if got := m.TimestampMs != nil; got != enabled {
+			t.Errorf("expected timestamp %t, but got %t", enabled, got)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// OTLPProtocolHTTP sends the metrics over OTLP/HTTP, as protobuf.
+	OTLPProtocolHTTP = "http/protobuf"
+	// OTLPProtocolGRPC sends the metrics over OTLP/gRPC.
+	OTLPProtocolGRPC = "grpc"
+	// otlpScopeName is the instrumentation scope of the exported metrics.
+	otlpScopeName = "github.com/greenpau/ovn_exporter"
+)
+
+var otlpExports = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "otlp", "exports_total"),
+	"The number of OTLP export requests, by result.",
+	[]string{"protocol", "result"}, nil,
+)
+
+// OTLPConfig holds the settings of the OTLP export. The export is disabled
+// when the protocol is empty.
+type OTLPConfig struct {
+	Protocol string `yaml:"protocol"`
+	// Endpoint is the URL of the metrics endpoint for OTLP/HTTP, and the
+	// host and port of the collector for OTLP/gRPC.
+	Endpoint string        `yaml:"endpoint"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Insecure bool          `yaml:"insecure"`
+	// Headers are sent with every request. Their values hold credentials,
+	// so they are not shown by the status page.
+	Headers            map[string]config.Secret `yaml:"headers,omitempty"`
+	ResourceAttributes map[string]string        `yaml:"resource_attributes,omitempty"`
+	TLSConfig          config.TLSConfig         `yaml:"tls_config,omitempty"`
+}
+
+// Validate checks the OTLP settings.
+func (cfg OTLPConfig) Validate() []string {
+	errs := []string{}
+	switch cfg.Protocol {
+	case "":
+		return errs
+	case OTLPProtocolHTTP:
+		if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("endpoint: must be an http or https URL, got %q", cfg.Endpoint))
+		}
+	case OTLPProtocolGRPC:
+		if cfg.Endpoint == "" {
+			errs = append(errs, "endpoint: must not be empty")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("protocol: unsupported protocol %q, expected %s or %s", cfg.Protocol, OTLPProtocolHTTP, OTLPProtocolGRPC))
+	}
+	if cfg.Interval <= 0 {
+		errs = append(errs, fmt.Sprintf("interval: must be positive, got %s", cfg.Interval))
+	}
+	if cfg.Timeout <= 0 {
+		errs = append(errs, fmt.Sprintf("timeout: must be positive, got %s", cfg.Timeout))
+	}
+	if err := cfg.TLSConfig.Validate(); err != nil {
+		errs = append(errs, fmt.Sprintf("tls_config: %s", err))
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// OTLPExporter sends the metrics of an exporter to an OpenTelemetry
+// collector every interval. The gauges become OTLP gauges and the counters
+// become cumulative monotonic sums. The labels become attributes of the
+// data points, and the host and the exporter are described by the resource
+// attributes.
+type OTLPExporter struct {
+	exporter *Exporter
+	gatherer prometheus.Gatherer
+	logger   log.Logger
+
+	mu      sync.Mutex
+	cfg     OTLPConfig
+	client  *http.Client
+	conn    *grpc.ClientConn
+	success float64
+	failure float64
+}
+
+// NewOTLPExporter returns an OTLP exporter of the metrics of the exporter.
+func NewOTLPExporter(cfg OTLPConfig, e *Exporter, logger log.Logger) (*OTLPExporter, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(e); err != nil {
+		return nil, err
+	}
+	o := &OTLPExporter{exporter: e, gatherer: registry, logger: logger}
+	if err := o.ApplyConfig(cfg); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ApplyConfig applies the OTLP settings. The connection to the collector is
+// replaced.
+func (o *OTLPExporter) ApplyConfig(cfg OTLPConfig) error {
+	apply, err := o.PrepareConfig(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// PrepareConfig builds the client of the OTLP settings, and returns the
+// function applying them, which cannot fail. The gRPC connection dials the
+// collector lazily, so nothing is sent before the settings are applied.
+func (o *OTLPExporter) PrepareConfig(cfg OTLPConfig) (func(), error) {
+	var client *http.Client
+	var conn *grpc.ClientConn
+	switch cfg.Protocol {
+	case OTLPProtocolHTTP:
+		var err error
+		client, err = config.NewClientFromConfig(config.HTTPClientConfig{
+			TLSConfig:       cfg.TLSConfig,
+			FollowRedirects: true,
+			EnableHTTP2:     true,
+		}, GetExporterName())
+		if err != nil {
+			return nil, err
+		}
+	case OTLPProtocolGRPC:
+		creds := insecure.NewCredentials()
+		if !cfg.Insecure {
+			tlsConfig, err := config.NewTLSConfig(&cfg.TLSConfig)
+			if err != nil {
+				return nil, err
+			}
+			creds = credentials.NewTLS(tlsConfig)
+		}
+		var err error
+		conn, err = grpc.Dial(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.conn != nil {
+			o.conn.Close()
+		}
+		o.cfg, o.client, o.conn = cfg, client, conn
+	}, nil
+}
+
+// Run exports the metrics every interval until the context is cancelled.
+func (o *OTLPExporter) Run(ctx context.Context) {
+	for {
+		o.mu.Lock()
+		interval := o.cfg.Interval
+		o.mu.Unlock()
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		o.Export(ctx)
+	}
+}
+
+// Export sends the metrics once. It does nothing when the export is
+// disabled.
+func (o *OTLPExporter) Export(ctx context.Context) error {
+	o.mu.Lock()
+	cfg, client, conn := o.cfg, o.client, o.conn
+	o.mu.Unlock()
+	if cfg.Protocol == "" {
+		return nil
+	}
+	err := o.export(ctx, cfg, client, conn)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		o.failure++
+		level.Error(o.logger).Log(
+			"msg", "failed exporting metrics over OTLP",
+			"protocol", cfg.Protocol,
+			"endpoint", cfg.Endpoint,
+			"error", err.Error(),
+		)
+		return err
+	}
+	o.success++
+	return nil
+}
+
+func (o *OTLPExporter) export(ctx context.Context, cfg OTLPConfig, client *http.Client, conn *grpc.ClientConn) error {
+	mfs, err := o.gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		return err
+	}
+	req := &colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{Attributes: o.resourceAttributes(cfg)},
+				ScopeMetrics: []*metricpb.ScopeMetrics{
+					{
+						Scope: &commonpb.InstrumentationScope{
+							Name:    otlpScopeName,
+							Version: GetVersion(),
+						},
+						Metrics: toOTLPMetrics(mfs, o.exporter.createdAt, time.Now()),
+					},
+				},
+			},
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	if cfg.Protocol == OTLPProtocolGRPC {
+		md := metadata.MD{}
+		for name, value := range cfg.Headers {
+			md.Set(name, string(value))
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+		_, err := colmetricpb.NewMetricsServiceClient(conn).Export(ctx, req)
+		return err
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range cfg.Headers {
+		httpReq.Header.Set(name, string(value))
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// resourceAttributes describes the exporter and the host it monitors. The
+// configured attributes override the defaults.
+func (o *OTLPExporter) resourceAttributes(cfg OTLPConfig) []*commonpb.KeyValue {
+	cli := o.exporter.clientView()
+	attrs := map[string]string{
+		"service.name":        GetExporterName(),
+		"service.version":     GetVersion(),
+		"service.instance.id": cli.System.ID,
+		"host.name":           cli.System.Hostname,
+		"host.id":             cli.System.ID,
+	}
+	for name, value := range cfg.ResourceAttributes {
+		attrs[name] = value
+	}
+	return toOTLPAttributes(attrs)
+}
+
+// Describe implements prometheus.Collector.
+func (o *OTLPExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- otlpExports
+}
+
+// Collect implements prometheus.Collector. Nothing is reported while the
+// export is disabled.
+func (o *OTLPExporter) Collect(ch chan<- prometheus.Metric) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cfg.Protocol == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(otlpExports, prometheus.CounterValue, o.success, o.cfg.Protocol, "success")
+	ch <- prometheus.MustNewConstMetric(otlpExports, prometheus.CounterValue, o.failure, o.cfg.Protocol, "failure")
+}
+
+// toOTLPMetrics converts the gauges and the counters of the metric
+// families. The counters without a creation time start at the start time.
+// The samples without a timestamp get the time of the export.
+func toOTLPMetrics(mfs []*dto.MetricFamily, startTime, now time.Time) []*metricpb.Metric {
+	metrics := []*metricpb.Metric{}
+	for _, mf := range mfs {
+		points := []*metricpb.NumberDataPoint{}
+		for _, m := range mf.Metric {
+			labels := make(map[string]string, len(m.Label))
+			for _, l := range m.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			point := &metricpb.NumberDataPoint{
+				Attributes:   toOTLPAttributes(labels),
+				TimeUnixNano: uint64(now.UnixNano()),
+			}
+			if m.TimestampMs != nil {
+				point.TimeUnixNano = uint64(time.UnixMilli(m.GetTimestampMs()).UnixNano())
+			}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				point.Value = &metricpb.NumberDataPoint_AsDouble{AsDouble: m.GetGauge().GetValue()}
+			case dto.MetricType_UNTYPED:
+				point.Value = &metricpb.NumberDataPoint_AsDouble{AsDouble: m.GetUntyped().GetValue()}
+			case dto.MetricType_COUNTER:
+				point.Value = &metricpb.NumberDataPoint_AsDouble{AsDouble: m.GetCounter().GetValue()}
+				point.StartTimeUnixNano = uint64(startTime.UnixNano())
+				if ct := m.GetCounter().GetCreatedTimestamp(); ct != nil {
+					point.StartTimeUnixNano = uint64(ct.AsTime().UnixNano())
+				}
+			default:
+				continue
+			}
+			points = append(points, point)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		metric := &metricpb.Metric{
+			Name:        mf.GetName(),
+			Description: mf.GetHelp(),
+		}
+		if mf.GetType() == dto.MetricType_COUNTER {
+			metric.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+				DataPoints:             points,
+				AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		} else {
+			metric.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: points}}
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func toOTLPAttributes(attrs map[string]string) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, name := range sortedKeys(attrs) {
+		kvs = append(kvs, &commonpb.KeyValue{
+			Key:   name,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: attrs[name]}},
+		})
+	}
+	return kvs
+}
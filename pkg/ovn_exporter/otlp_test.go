@@ -0,0 +1,206 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/config"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestToOTLPMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ovn_up", Help: "Up."}, []string{"system_id"})
+	gauge.WithLabelValues("abc").Set(1)
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "ovn_coverage_total", Help: "Coverage."}, []string{"component"})
+	counter.WithLabelValues("ovsdb-server").Add(5)
+	registry.MustRegister(gauge, counter)
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+
+	now := time.Now()
+	metrics := toOTLPMetrics(mfs, time.Unix(1000, 0), now)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 metrics, but got %d", len(metrics))
+	}
+	byName := map[string]*metricpb.Metric{}
+	for _, m := range metrics {
+		byName[m.Name] = m
+	}
+	sum := byName["ovn_coverage_total"].GetSum()
+	if sum == nil || !sum.IsMonotonic || sum.AggregationTemporality != metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("expected cumulative monotonic sum, but got %v", byName["ovn_coverage_total"])
+	}
+	point := sum.DataPoints[0]
+	if point.GetAsDouble() != 5 {
+		t.Errorf("expected value 5, but got %v", point.GetAsDouble())
+	}
+	// The counter of client_golang has a creation time.
+	if point.StartTimeUnixNano <= uint64(time.Unix(1000, 0).UnixNano()) || point.StartTimeUnixNano > point.TimeUnixNano {
+		t.Errorf("unexpected start time %d", point.StartTimeUnixNano)
+	}
+	if point.Attributes[0].Key != "component" || point.Attributes[0].Value.GetStringValue() != "ovsdb-server" {
+		t.Errorf("unexpected attributes %v", point.Attributes)
+	}
+	gaugePoints := byName["ovn_up"].GetGauge().GetDataPoints()
+	if len(gaugePoints) != 1 || gaugePoints[0].TimeUnixNano != uint64(now.UnixNano()) {
+		t.Errorf("unexpected gauge %v", byName["ovn_up"])
+	}
+}
+
+// checkOTLPRequest checks that the request has the metrics of the test
+// exporter and the resource attributes.
+func checkOTLPRequest(t *testing.T, req *colmetricpb.ExportMetricsServiceRequest) {
+	t.Helper()
+	if len(req.ResourceMetrics) != 1 {
+		t.Fatalf("expected 1 resource, but got %d", len(req.ResourceMetrics))
+	}
+	attrs := map[string]string{}
+	for _, kv := range req.ResourceMetrics[0].Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if attrs["service.name"] != GetExporterName() || attrs["deployment.environment"] != "test" {
+		t.Errorf("unexpected resource attributes %v", attrs)
+	}
+	found := false
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name == "ovn_up" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected ovn_up metric, but got %v", req.ResourceMetrics[0].ScopeMetrics[0].Metrics)
+	}
+}
+
+func newTestOTLPConfig(protocol, endpoint string) OTLPConfig {
+	cfg := DefaultConfig().OTLP
+	cfg.Protocol = protocol
+	cfg.Endpoint = endpoint
+	cfg.Insecure = true
+	cfg.Headers = map[string]config.Secret{"x-tenant": "ovn"}
+	cfg.ResourceAttributes = map[string]string{"deployment.environment": "test"}
+	return cfg
+}
+
+func TestOTLPExporterHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var received *colmetricpb.ExportMetricsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Tenant") != "ovn" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		b, _ := io.ReadAll(r.Body)
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			t.Errorf("expected protobuf body, but got %q", err)
+		}
+		mu.Lock()
+		received = req
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	o, err := NewOTLPExporter(newTestOTLPConfig(OTLPProtocolHTTP, server.URL+"/v1/metrics"), newTestExporter(t), nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if err := o.Export(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	checkOTLPRequest(t, received)
+}
+
+type testMetricsService struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	mu       sync.Mutex
+	received *colmetricpb.ExportMetricsServiceRequest
+	tenant   []string
+}
+
+func (s *testMetricsService) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = req
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.tenant = md.Get("x-tenant")
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPExporterGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	server := grpc.NewServer()
+	service := &testMetricsService{}
+	colmetricpb.RegisterMetricsServiceServer(server, service)
+	go server.Serve(l)
+	defer server.Stop()
+
+	o, err := NewOTLPExporter(newTestOTLPConfig(OTLPProtocolGRPC, l.Addr().String()), newTestExporter(t), nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	defer o.ApplyConfig(OTLPConfig{})
+	if err := o.Export(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	checkOTLPRequest(t, service.received)
+	if len(service.tenant) != 1 || service.tenant[0] != "ovn" {
+		t.Errorf("expected x-tenant header, but got %v", service.tenant)
+	}
+}
+
+func TestOTLPConfigValidate(t *testing.T) {
+	testcases := []struct {
+		name   string
+		cfg    OTLPConfig
+		errors int
+	}{
+		{name: "disabled", cfg: OTLPConfig{}},
+		{name: "http", cfg: newTestOTLPConfig(OTLPProtocolHTTP, "http://localhost:4318/v1/metrics")},
+		{name: "http without url", cfg: newTestOTLPConfig(OTLPProtocolHTTP, "localhost:4318"), errors: 1},
+		{name: "grpc", cfg: newTestOTLPConfig(OTLPProtocolGRPC, "localhost:4317")},
+		{name: "grpc without endpoint", cfg: newTestOTLPConfig(OTLPProtocolGRPC, ""), errors: 1},
+		{name: "unsupported protocol", cfg: newTestOTLPConfig("http/json", "http://localhost:4318"), errors: 1},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if errs := tc.cfg.Validate(); len(errs) != tc.errors {
+				t.Errorf("expected %d errors, but got %v", tc.errors, errs)
+			}
+		})
+	}
+}
@@ -15,7 +15,9 @@
 package ovn_exporter
 
 import (
-	_ "net/http/pprof"
+	"context"
+	"fmt"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,12 +27,16 @@ import (
 	"github.com/greenpau/ovsdb"
 	"github.com/greenpau/versioned"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/promlog"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/common/version"
 )
 
 const (
 	namespace = "ovn"
+
+	// defaultPollInterval is the interval, in seconds, between
+	// collections when the exporter has no poll interval set.
+	defaultPollInterval = 15
 )
 
 var (
@@ -82,6 +88,46 @@ var (
 		"The timestamp of the next potential poll of OVN stack.",
 		[]string{"system_id"}, nil,
 	)
+	scrapeCollectorDuration = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_duration_seconds"),
+		"The duration of the last run of a collection step.",
+		[]string{"collector"}, nil,
+	)
+	scrapeCollectorSuccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_success"),
+		"Whether the last run of a collection step succeeded (1) or failed (0).",
+		[]string{"collector"}, nil,
+	)
+	scrapeCollectorLastSuccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_last_success_timestamp_seconds"),
+		"The timestamp of the last successful run of a collection step.",
+		[]string{"collector"}, nil,
+	)
+	scrapeCollectorCacheAge = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_cache_age_seconds"),
+		"The time since the metrics of a collection step were collected.",
+		[]string{"collector"}, nil,
+	)
+	dbConnectionUp = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db", "connection_up"),
+		"Whether the connection to an OVSDB database is up (1) or down (0).",
+		[]string{"database"}, nil,
+	)
+	dbReconnects = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db", "reconnects_total"),
+		"The number of times the exporter reconnected to an OVSDB database.",
+		[]string{"database"}, nil,
+	)
+	serverDatabaseConnected = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "server", "database_connected"),
+		"Whether the server of an OVN database is connected to the database (1) or not (0), according to the _Server database.",
+		[]string{"system_id", "component", "database", "model", "cluster_uuid", "server_uuid"}, nil,
+	)
+	serverDatabaseLeader = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "server", "database_leader"),
+		"Whether the server of a clustered OVN database is the leader (1) or not (0), according to the _Server database.",
+		[]string{"system_id", "component", "database", "cluster_uuid", "server_uuid"}, nil,
+	)
 	pid = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "", "pid"),
 		"The process ID of a running OVN component. If the component is not running, then the ID is 0.",
@@ -152,6 +198,115 @@ var (
 		"The value of the tunnel key associated with the logical switch port.",
 		[]string{"system_id", "uuid"}, nil,
 	)
+	logicalRouterInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_info"),
+		"The information about OVN logical router. This metric is always up (1).",
+		[]string{"system_id", "uuid", "name"}, nil,
+	)
+	logicalRouterExternalIDs = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_external_id"),
+		"Provides the external IDs and values associated with OVN logical routers. This metric is always up (1).",
+		[]string{"system_id", "uuid", "key", "value"}, nil,
+	)
+	logicalRouterTunnelKey = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_tunnel_key"),
+		"The value of the tunnel key associated with the logical router.",
+		[]string{"system_id", "uuid"}, nil,
+	)
+	logicalRouterPorts = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_ports"),
+		"The number of logical router ports of the OVN logical router.",
+		[]string{"system_id", "uuid"}, nil,
+	)
+	logicalRouterPortInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_port_info"),
+		"The information about OVN logical router port. This metric is always up (1).",
+		[]string{
+			"system_id",
+			"uuid",
+			"name",
+			"logical_router",
+			"mac_address",
+			"networks",
+			"peer",
+			"gateway_chassis",
+		}, nil,
+	)
+	logicalRouterStaticRoutes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_static_routes"),
+		"The number of static routes of the OVN logical router, by route table, policy and size of their ECMP group.",
+		[]string{"system_id", "uuid", "route_table", "policy", "ecmp_group_size"}, nil,
+	)
+	logicalRouterDefaultRoute = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_default_route"),
+		"Whether the main routing table of the OVN logical router has a default route (1) or not (0).",
+		[]string{"system_id", "uuid"}, nil,
+	)
+	logicalRouterBFDRoutes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_bfd_routes"),
+		"The number of static routes of the OVN logical router with BFD enabled.",
+		[]string{"system_id", "uuid"}, nil,
+	)
+	logicalRouterPolicies = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_policies"),
+		"The number of routing policies of the OVN logical router, by action.",
+		[]string{"system_id", "uuid", "action"}, nil,
+	)
+	logicalRouterNATRules = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_nat_rules"),
+		"The number of NAT rules of the OVN logical router, by type.",
+		[]string{"system_id", "uuid", "type"}, nil,
+	)
+	logicalRouterNATRulesByMode = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_nat_rules_by_mode"),
+		"The number of NAT rules of the OVN logical router handled on the chassis of their logical port (distributed) or on the gateway chassis (centralized).",
+		[]string{"system_id", "uuid", "mode"}, nil,
+	)
+	logicalRouterFloatingIPInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "logical_router_floating_ip_info"),
+		"The information about a dnat_and_snat rule of OVN logical router. This metric is always up (1).",
+		[]string{"system_id", "uuid", "external_ip", "logical_ip", "logical_port", "external_mac"}, nil,
+	)
+	lbInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_info"),
+		"The information about OVN load balancer. This metric is always up (1).",
+		[]string{"system_id", "uuid", "name", "protocol", "selection_fields"}, nil,
+	)
+	lbVIPs = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_vips"),
+		"The number of VIPs of OVN load balancer.",
+		[]string{"system_id", "uuid", "lb", "protocol"}, nil,
+	)
+	lbVIPBackends = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_vip_backends"),
+		"The number of backends of a VIP of OVN load balancer. A VIP without backends has the value 0.",
+		[]string{"system_id", "uuid", "lb", "vip", "protocol"}, nil,
+	)
+	lbHealthChecks = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_health_checks"),
+		"The number of health checks configured on OVN load balancer.",
+		[]string{"system_id", "uuid", "lb"}, nil,
+	)
+	lbAttachment = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_attachment"),
+		"The logical switch, logical router or load balancer group OVN load balancer is attached to. This metric is always up (1).",
+		[]string{"system_id", "uuid", "lb", "type", "name"}, nil,
+	)
+	lbBackendStatus = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_backend_status"),
+		"The health check status of a backend of OVN load balancer, online (1) or offline (0).",
+		[]string{"system_id", "uuid", "lb", "vip", "backend_ip", "port", "protocol", "logical_port"}, nil,
+	)
+	lbVIPBackendsHealthy = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_vip_backends_healthy"),
+		"The number of online backends of a health checked VIP of OVN load balancer.",
+		[]string{"system_id", "uuid", "lb", "vip", "protocol"}, nil,
+	)
+	lbVIPBackendsUnhealthy = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "lb_vip_backends_unhealthy"),
+		"The number of backends of a health checked VIP of OVN load balancer that are offline or not monitored.",
+		[]string{"system_id", "uuid", "lb", "vip", "protocol"}, nil,
+	)
 	networkPortUp = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "", "network_port"),
 		"The TCP port used for database connection. If the value is 0, then the port is not in use.",
@@ -280,33 +435,88 @@ var (
 type Exporter struct {
 	sync.RWMutex
 	Client               *ovsdb.OvnClient
-	timeout              int
+	timeout              int64
 	pollInterval         int64
 	errors               int64
 	errorsLocker         sync.RWMutex
 	nextCollectionTicker int64
-	metrics              []prometheus.Metric
+	snapshot             atomic.Pointer[metricSnapshot]
 	logger               log.Logger
+	source               DataSource
+	collectors           []namedCollector
+	clientLock           sync.RWMutex
+	databaseLock         chan struct{}
+	appCommands          *appCommandCache
+	clusterStates        *clusterStates
+	lastSuccess          map[string]time.Time
+	sections             map[string]section
+	connections          []*dbConnection
+	readiness            atomic.Pointer[Readiness]
+	recentErrors         recentErrors
+	config               atomic.Pointer[Config]
+	debugSnapshot        atomic.Bool
+	rawData              *rawRecorder
+	rawSnapshot          atomic.Pointer[RawSnapshot]
+	createdAt            time.Time
+	collectionTimestamps atomic.Bool
+	offline              atomic.Pointer[offlineDatabases]
 }
 
+// metricSnapshot holds the metrics of a completed collection, by section.
+// A snapshot is never modified once it has been published. The initial
+// snapshot, published before the first collection, is not completed. The
+// collectors are the names of the enabled collectors, in the registry
+// order.
+type metricSnapshot struct {
+	sections   []section
+	collectors []string
+	timestamp  time.Time
+	duration   time.Duration
+	up         bool
+	completed  bool
+}
+
+// Options are the options used to create an Exporter. The Collectors map
+// enables or disables collectors by name. The collectors not referenced
+// in the map keep their default state.
 type Options struct {
-	Timeout int
-	Logger  log.Logger
+	Timeout    int
+	Logger     log.Logger
+	Collectors map[string]bool
+	// DataSource replaces the OVN client as the source of the data of the
+	// collectors. It is nil by default.
+	DataSource DataSource
 }
 
-// NewLogger returns an instance of logger.
+// NewLogger returns an instance of logger. It logs in the logfmt format to
+// the standard error, with the time and the caller, the messages of the
+// level or above.
 func NewLogger(logLevel string) (log.Logger, error) {
-	allowedLogLevel := &promlog.AllowedLevel{}
-	if err := allowedLogLevel.Set(logLevel); err != nil {
-		return nil, err
+	var allowed level.Option
+	switch logLevel {
+	case "debug":
+		allowed = level.AllowDebug()
+	case "info":
+		allowed = level.AllowInfo()
+	case "warn":
+		allowed = level.AllowWarn()
+	case "error":
+		allowed = level.AllowError()
+	default:
+		return nil, fmt.Errorf("unrecognized log level %q", logLevel)
 	}
-	promlogConfig := &promlog.Config{
-		Level: allowedLogLevel,
-	}
-	logger := promlog.New(promlogConfig)
-	return logger, nil
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	logger = log.With(logger, "ts", logTimestamp, "caller", log.Caller(5))
+	return level.NewFilter(logger, allowed), nil
 }
 
+// logTimestamp formats the time of the log messages in UTC, with
+// milliseconds.
+var logTimestamp = log.TimestampFormat(
+	func() time.Time { return time.Now().UTC() },
+	"2006-01-02T15:04:05.000Z07:00",
+)
+
 // NewExporter returns an initialized Exporter.
 func NewExporter(opts Options) (*Exporter, error) {
 	version.Version = appVersion
@@ -314,14 +524,38 @@ func NewExporter(opts Options) (*Exporter, error) {
 	version.Branch = gitBranch
 	version.BuildUser = buildUser
 	version.BuildDate = buildDate
+	collectors, err := newCollectors(opts.Collectors)
+	if err != nil {
+		return nil, err
+	}
 	e := Exporter{
-		timeout: opts.Timeout,
-		logger:  opts.Logger,
+		timeout:      int64(opts.Timeout),
+		pollInterval: defaultPollInterval,
+		logger:       opts.Logger,
+		collectors:   collectors,
+		databaseLock: make(chan struct{}, 1),
+		lastSuccess:  make(map[string]time.Time),
+		sections:     make(map[string]section),
+		connections:  newDBConnections(),
+		createdAt:    time.Now(),
+	}
+	if e.logger == nil {
+		e.logger = log.NewNopLogger()
+	}
+	e.source = opts.DataSource
+	if e.source == nil {
+		e.source = &clientSource{e: &e}
 	}
 	client := ovsdb.NewOvnClient()
 	client.Timeout = opts.Timeout
 	e.Client = client
 	e.Client.GetSystemID()
+	now := time.Now()
+	e.snapshot.Store(&metricSnapshot{
+		sections:   []section{{metrics: e.newStatusMetrics(0), collectedAt: now}},
+		collectors: collectorNames(collectors),
+		timestamp:  now,
+	})
 	return &e, nil
 }
 
@@ -355,6 +589,12 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- info
 	ch <- requestErrors
 	ch <- nextPoll
+	ch <- scrapeCollectorDuration
+	ch <- scrapeCollectorSuccess
+	ch <- scrapeCollectorLastSuccess
+	ch <- scrapeCollectorCacheAge
+	ch <- dbConnectionUp
+	ch <- dbReconnects
 	ch <- pid
 	ch <- logFileSize
 	ch <- dbFileSize
@@ -367,6 +607,26 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- logicalSwitchTunnelKey
 	ch <- logicalSwitchPortInfo
 	ch <- logicalSwitchPortTunnelKey
+	ch <- logicalRouterInfo
+	ch <- logicalRouterExternalIDs
+	ch <- logicalRouterPorts
+	ch <- logicalRouterTunnelKey
+	ch <- logicalRouterPortInfo
+	ch <- logicalRouterStaticRoutes
+	ch <- logicalRouterDefaultRoute
+	ch <- logicalRouterBFDRoutes
+	ch <- logicalRouterPolicies
+	ch <- logicalRouterNATRules
+	ch <- logicalRouterNATRulesByMode
+	ch <- logicalRouterFloatingIPInfo
+	ch <- lbInfo
+	ch <- lbVIPs
+	ch <- lbVIPBackends
+	ch <- lbHealthChecks
+	ch <- lbAttachment
+	ch <- lbBackendStatus
+	ch <- lbVIPBackendsHealthy
+	ch <- lbVIPBackendsUnhealthy
 	ch <- networkPortUp
 	ch <- covAvg
 	ch <- covTotal
@@ -391,6 +651,8 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- clusterPeerInConnInfo
 	ch <- clusterPeerOutConnInfo
 	ch <- clusterGroup
+	ch <- serverDatabaseConnected
+	ch <- serverDatabaseLeader
 }
 
 // IncrementErrorCounter increases the counter of failed queries
@@ -401,829 +663,315 @@ func (e *Exporter) IncrementErrorCounter() {
 	atomic.AddInt64(&e.errors, 1)
 }
 
-// Collect implements prometheus.Collector.
+// Collect implements prometheus.Collector. It sends the metrics of the
+// last completed collection and never waits for a collection in progress.
+// The age of the sections and the state of the database connections are
+// always current.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.GatherMetrics()
-	level.Debug(e.logger).Log(
-		"msg", "Collect() calls RLock()",
-		"system_id", e.Client.System.ID,
-	)
-	e.RLock()
-	defer e.RUnlock()
-	if len(e.metrics) == 0 {
-		level.Debug(e.logger).Log(
-			"msg", "Collect() no metrics found",
-			"system_id", e.Client.System.ID,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			up,
-			prometheus.GaugeValue,
-			0,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			info,
-			prometheus.GaugeValue,
-			1,
-			e.Client.System.ID, e.Client.System.RunDir, e.Client.System.Hostname,
-			e.Client.System.Type, e.Client.System.Version,
-			e.Client.Database.Vswitch.Version, e.Client.Database.Vswitch.Schema.Version,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			requestErrors,
-			prometheus.CounterValue,
-			float64(e.errors),
-			e.Client.System.ID,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			nextPoll,
-			prometheus.CounterValue,
-			float64(e.nextCollectionTicker),
-			e.Client.System.ID,
-		)
+	e.collect(ch, nil)
+}
+
+// collect sends the metrics of the last completed collection. When keep is
+// not nil, only the sections of the collectors it keeps are sent, along
+// with the status metrics.
+func (e *Exporter) collect(ch chan<- prometheus.Metric, keep map[string]bool) {
+	snapshot := e.snapshot.Load()
+	if snapshot == nil {
 		return
 	}
 	level.Debug(e.logger).Log(
 		"msg", "Collect() sends metrics to a shared channel",
-		"system_id", e.Client.System.ID,
-		"metric_count", len(e.metrics),
+		"section_count", len(snapshot.sections),
+		"collected_at", snapshot.timestamp.Format(time.RFC3339),
 	)
-	for _, m := range e.metrics {
+	stamp := e.collectionTimestamps.Load()
+	now := time.Now()
+	for _, s := range snapshot.sections {
+		if keep != nil && s.name != "" && !keep[s.name] {
+			continue
+		}
+		for _, m := range s.metrics {
+			if stamp {
+				m = prometheus.NewMetricWithTimestamp(s.collectedAt, m)
+			}
+			ch <- m
+		}
+		for _, m := range s.scrape {
+			if stamp {
+				m = prometheus.NewMetricWithTimestamp(s.ranAt, m)
+			}
+			ch <- m
+		}
+		if s.name != "" {
+			ch <- prometheus.MustNewConstMetric(
+				scrapeCollectorCacheAge,
+				prometheus.GaugeValue,
+				now.Sub(s.collectedAt).Seconds(),
+				s.name,
+			)
+		}
+	}
+	if e.offline.Load() != nil {
+		return
+	}
+	for _, m := range e.newConnectionMetrics() {
 		ch <- m
 	}
 }
 
-// GatherMetrics collect data from OVN server and stores them
-// as Prometheus metrics.
+// Run collects metrics in the background until the context is cancelled.
+// The first collection runs every collector right away. The subsequent
+// ones start every tick and run the collectors due.
+func (e *Exporter) Run(ctx context.Context) {
+	for {
+		startedAt := time.Now()
+		e.gatherMetrics(ctx, false)
+		timer := time.NewTimer(time.Until(startedAt.Add(e.getTickInterval())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// GatherMetrics collect data from OVN server and publishes them
+// as a snapshot of Prometheus metrics. It runs every collector, whether
+// due or not.
 func (e *Exporter) GatherMetrics() {
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() called",
-		"system_id", e.Client.System.ID,
-	)
-	if time.Now().Unix() < e.nextCollectionTicker {
+	e.gatherMetrics(context.Background(), true)
+}
+
+// gatherMetrics runs a collection of the collectors due, or of all of them.
+// The metrics of the other collectors are served from the cache. The
+// collection as a whole is bounded by the longest interval of the
+// collectors it runs, and each of its steps by the exporter's timeout.
+func (e *Exporter) gatherMetrics(ctx context.Context, all bool) {
+	e.Lock()
+	defer e.Unlock()
+	startedAt := time.Now()
+	// In offline mode, there is no Open_vSwitch database to read the
+	// system information from.
+	offline := e.offline.Load() != nil
+	systemInfoDue := !offline && (all || e.isDue("system_info", e.getPollInterval(), startedAt))
+	due := []namedCollector{}
+	timeout := e.getPollInterval()
+	for _, c := range e.collectors {
+		interval := e.getCollectorInterval(c)
+		if all || e.isDue(c.name, interval, startedAt) {
+			due = append(due, c)
+			if interval > timeout {
+				timeout = interval
+			}
+		}
+	}
+	if !systemInfoDue && len(due) == 0 {
 		return
 	}
-	e.Lock()
 	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() locked",
+		"msg", "GatherMetrics() called",
 		"system_id", e.Client.System.ID,
+		"collector_count", len(due),
 	)
-	defer e.Unlock()
-	if len(e.metrics) > 0 {
-		e.metrics = e.metrics[:0]
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() cleared metrics",
-			"system_id", e.Client.System.ID,
-		)
-	}
-	upValue := 1
-	isClusterEnabled := false
-
-	var err error
-
-	err = e.Client.GetSystemInfo()
-	if err != nil {
-		level.Error(e.logger).Log(
-			"msg", "GetSystemInfo() failed",
-			"vswitch_name", e.Client.Database.Vswitch.Name,
-			"system_id", e.Client.System.ID,
-			"error", err.Error(),
-		)
-		e.IncrementErrorCounter()
-		upValue = 0
-	} else {
-		level.Debug(e.logger).Log(
-			"msg", "GetSystemInfo() successful",
-			"vswitch_name", e.Client.Database.Vswitch.Name,
-			"system_id", e.Client.System.ID,
-		)
-	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	components := []string{
-		"ovsdb-server",
-		"ovsdb-server-southbound",
-		"ovsdb-server-southbound-monitoring",
-		"ovsdb-server-northbound",
-		"ovsdb-server-northbound-monitoring",
-		"ovn-northd",
-		"ovn-northd-monitoring",
-		"ovs-vswitchd",
-	}
-	for _, component := range components {
-		p, err := e.Client.GetProcessInfo(component)
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() calls GetProcessInfo()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
+	if systemInfoDue {
+		stepStartedAt := time.Now()
+		err := e.updateSystemInfo(ctx)
 		if err != nil {
 			level.Error(e.logger).Log(
-				"msg", "GetProcessInfo() failed",
-				"component", component,
+				"msg", "GetSystemInfo() failed",
+				"vswitch_name", e.Client.Database.Vswitch.Name,
 				"system_id", e.Client.System.ID,
 				"error", err.Error(),
 			)
 			e.IncrementErrorCounter()
-			upValue = 0
+		} else {
+			level.Debug(e.logger).Log(
+				"msg", "GetSystemInfo() successful",
+				"vswitch_name", e.Client.Database.Vswitch.Name,
+				"system_id", e.Client.System.ID,
+			)
 		}
-		e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-			pid,
-			prometheus.GaugeValue,
-			float64(p.ID),
-			e.Client.System.ID,
-			component,
-			p.User,
-			p.Group,
-		))
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() completed GetProcessInfo()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
+		e.storeSection("system_info", collectorResult{duration: time.Since(stepStartedAt), err: err}, startedAt, 0)
+	}
+	if offline {
+		delete(e.sections, "system_info")
 	}
 
-	components = []string{
-		"ovsdb-server",
-		"ovsdb-server-southbound",
-		"ovsdb-server-northbound",
-		"ovn-northd",
-		"ovs-vswitchd",
+	e.rawData = nil
+	if e.debugSnapshot.Load() {
+		e.rawData = newRawRecorder(startedAt, e.rawSnapshot.Load())
 	}
-	for _, component := range components {
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() calls GetLogFileInfo()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		file, err := e.Client.GetLogFileInfo(component)
-		if err != nil {
-			level.Error(e.logger).Log(
-				"msg", "GetLogFileInfo() failed",
-				"component", component,
-				"system_id", e.Client.System.ID,
-				"error", err.Error(),
-			)
-			e.IncrementErrorCounter()
-			continue
-		}
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() completed GetLogFileInfo()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-			logFileSize,
-			prometheus.GaugeValue,
-			float64(file.Info.Size()),
-			e.Client.System.ID,
-			file.Component,
-			file.Path,
-		))
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() calls GetLogFileEventStats()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		eventStats, err := e.Client.GetLogFileEventStats(component)
-		if err != nil {
-			level.Error(e.logger).Log(
-				"msg", "GetLogFileEventStats() failed",
-				"component", component,
-				"system_id", e.Client.System.ID,
-				"error", err.Error(),
-			)
-			e.IncrementErrorCounter()
-			continue
-		}
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() completed GetLogFileEventStats()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		for sev, sources := range eventStats {
-			for source, count := range sources {
-				e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-					logEventStat,
-					prometheus.GaugeValue,
-					float64(count),
-					e.Client.System.ID,
-					component,
-					sev,
-					source,
-				))
-			}
-		}
+	results := e.runCollectors(ctx, due)
+	for i, c := range due {
+		e.storeSection(c.name, results[i], startedAt, c.cacheTTL)
 	}
 
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() calls GetChassis()",
-		"system_id", e.Client.System.ID,
-	)
-	if vteps, err := e.Client.GetChassis(); err != nil {
-		level.Error(e.logger).Log(
-			"msg", "GetChassis() failed",
-			"southbound_db_name", e.Client.Database.Southbound.Name,
-			"system_id", e.Client.System.ID,
-			"error", err.Error(),
-		)
-		e.IncrementErrorCounter()
-		upValue = 0
-	} else {
-		for _, vtep := range vteps {
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				chassisInfo,
-				prometheus.GaugeValue,
-				float64(vtep.Up),
-				e.Client.System.ID,
-				vtep.UUID,
-				vtep.Name,
-				vtep.IPAddress.String(),
-			))
+	e.readiness.Store(e.checkReadiness(ctx))
+
+	// The snapshot keeps the registry order.
+	sections := []section{}
+	upValue := 1
+	if s, exists := e.sections["system_info"]; exists {
+		sections = append(sections, s)
+		if s.err != nil {
+			upValue = 0
 		}
 	}
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() completed GetChassis()",
-		"system_id", e.Client.System.ID,
-	)
-
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() calls GetLogicalSwitches()",
-		"system_id", e.Client.System.ID,
-	)
-	lsws, err := e.Client.GetLogicalSwitches()
-	if err != nil {
-		level.Error(e.logger).Log(
-			"msg", "GetLogicalSwitches() failed",
-			"southbound_db_name", e.Client.Database.Southbound.Name,
-			"system_id", e.Client.System.ID,
-			"error", err.Error(),
-		)
-		e.IncrementErrorCounter()
-		upValue = 0
-	} else {
-		for _, lsw := range lsws {
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				logicalSwitchInfo,
-				prometheus.GaugeValue,
-				1,
-				e.Client.System.ID,
-				lsw.UUID,
-				lsw.Name,
-			))
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				logicalSwitchPorts,
-				prometheus.GaugeValue,
-				float64(len(lsw.Ports)),
-				e.Client.System.ID,
-				lsw.UUID,
-			))
-			if len(lsw.Ports) > 0 {
-				for _, p := range lsw.Ports {
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						logicalSwitchPortBinding,
-						prometheus.GaugeValue,
-						1,
-						e.Client.System.ID,
-						lsw.UUID,
-						p,
-					))
-				}
-			}
-			if len(lsw.ExternalIDs) > 0 {
-				for k, v := range lsw.ExternalIDs {
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						logicalSwitchExternalIDs,
-						prometheus.GaugeValue,
-						1,
-						e.Client.System.ID,
-						lsw.UUID,
-						k,
-						v,
-					))
-				}
-			}
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				logicalSwitchTunnelKey,
-				prometheus.GaugeValue,
-				float64(lsw.TunnelKey),
-				e.Client.System.ID,
-				lsw.UUID,
-			))
+	for _, c := range e.collectors {
+		s := e.sections[c.name]
+		sections = append(sections, s)
+		if s.err != nil && c.critical {
+			upValue = 0
 		}
 	}
+	atomic.StoreInt64(&e.nextCollectionTicker, e.nextRun().Unix())
+	sections = append(sections, section{metrics: e.newStatusMetrics(upValue), collectedAt: startedAt})
+	e.snapshot.Store(&metricSnapshot{
+		sections:   sections,
+		collectors: collectorNames(e.collectors),
+		timestamp:  startedAt,
+		duration:   time.Since(startedAt),
+		up:         upValue == 1,
+		completed:  true,
+	})
+	if e.rawData != nil {
+		e.rawSnapshot.Store(&e.rawData.snapshot)
+	}
 	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() completed GetLogicalSwitches()",
-		"system_id", e.Client.System.ID,
-	)
-
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() calls GetLogicalSwitchPorts()",
+		"msg", "GatherMetrics() returns",
 		"system_id", e.Client.System.ID,
+		"section_count", len(sections),
+		"duration", time.Since(startedAt),
 	)
-	lswps, err := e.Client.GetLogicalSwitchPorts()
-	if err != nil {
-		level.Error(e.logger).Log(
-			"msg", "GetLogicalSwitchPorts() failed",
-			"southbound_db_name", e.Client.Database.Southbound.Name,
-			"system_id", e.Client.System.ID,
-			"error", err.Error(),
-		)
-		e.IncrementErrorCounter()
-		upValue = 0
-	} else {
-		for _, port := range lswps {
-			macAddr := "<nil>"
-			ipAddr := "<nil>"
-
-			// Find first MAC address
-			for _, a := range port.Addresses {
-				if a.MacAddress != nil {
-					macAddr = a.MacAddress.String()
-					break
-				}
-			}
-
-			// Find first IP address
-			for _, a := range port.Addresses {
-				if len(a.IPAddresses) > 0 {
-					ipAddr = a.IPAddresses[0].String()
-					break
-				}
-			}
+}
 
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				logicalSwitchPortInfo,
-				prometheus.GaugeValue,
-				float64(1),
-				e.Client.System.ID,
-				port.UUID,
-				port.Name,
-				port.ChassisUUID,
-				port.LogicalSwitchName,
-				port.DatapathUUID,
-				port.PortBindingUUID,
-				macAddr,
-				ipAddr,
-			))
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				logicalSwitchPortTunnelKey,
-				prometheus.GaugeValue,
-				float64(port.TunnelKey),
-				e.Client.System.ID,
-				port.UUID,
-			))
+// runCollectors runs the collectors of a collection and returns their
+// results in the same order. The blocking collectors run one after
+// another, then the remaining collectors run concurrently. The caller
+// holds the exporter's lock.
+func (e *Exporter) runCollectors(ctx context.Context, collectors []namedCollector) []collectorResult {
+	e.appCommands = newAppCommandCache()
+	clusterCollectorEnabled := false
+	for _, c := range collectors {
+		if c.name == "cluster" {
+			clusterCollectorEnabled = true
 		}
 	}
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() completed GetLogicalSwitchPorts()",
-		"system_id", e.Client.System.ID,
-	)
-
-	northClusterID := ""
-	southClusterID := ""
-
-	components = []string{
-		"ovsdb-server",
-		"ovsdb-server-southbound",
-		"ovsdb-server-northbound",
+	// When the cluster collector does not run, the states of its last run
+	// are kept.
+	if clusterCollectorEnabled || e.clusterStates == nil {
+		e.clusterStates = newClusterStates()
+	}
+	if !clusterCollectorEnabled {
+		e.clusterStates.done()
 	}
 
-	for _, component := range components {
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() calls AppListCommands()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		if cmds, err := e.Client.AppListCommands(component); err != nil {
-			level.Error(e.logger).Log(
-				"msg", "AppListCommands() failed",
-				"component", component,
-				"system_id", e.Client.System.ID,
-				"error", err.Error(),
-			)
-			e.IncrementErrorCounter()
-			level.Debug(e.logger).Log(
-				"msg", "GatherMetrics() completed AppListCommands()",
-				"component", component,
-				"system_id", e.Client.System.ID,
-			)
-		} else {
-			level.Debug(e.logger).Log(
-				"msg", "GatherMetrics() completed AppListCommands()",
-				"component", component,
-				"system_id", e.Client.System.ID,
-			)
-			if cmds["coverage/show"] {
-				level.Debug(e.logger).Log(
-					"msg", "GatherMetrics() calls GetAppCoverageMetrics()",
-					"component", component,
-					"system_id", e.Client.System.ID,
-				)
-				if metrics, err := e.Client.GetAppCoverageMetrics(component); err != nil {
-					level.Error(e.logger).Log(
-						"msg", "GetAppCoverageMetrics() failed",
-						"component", component,
-						"system_id", e.Client.System.ID,
-						"error", err.Error(),
-					)
-					e.IncrementErrorCounter()
-				} else {
-					for event, metric := range metrics {
-						//log.Infof("%s: %s, %s", component, name, metric)
-						for period, value := range metric {
-							if period == "total" {
-								e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-									covTotal,
-									prometheus.CounterValue,
-									value,
-									e.Client.System.ID,
-									component,
-									event,
-								))
-							} else {
-								e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-									covAvg,
-									prometheus.GaugeValue,
-									value,
-									e.Client.System.ID,
-									component,
-									event,
-									period,
-								))
-							}
-						}
-					}
-				}
-				level.Debug(e.logger).Log(
-					"msg", "GatherMetrics() completed GetAppCoverageMetrics()",
-					"component", component,
-					"system_id", e.Client.System.ID,
-				)
-			}
-			if cmds["memory/show"] {
-				level.Debug(e.logger).Log(
-					"msg", "GatherMetrics() calls GetAppMemoryMetrics()",
-					"component", component,
-					"system_id", e.Client.System.ID,
-				)
-				if metrics, err := e.Client.GetAppMemoryMetrics(component); err != nil {
-					level.Error(e.logger).Log(
-						"msg", "GetAppMemoryMetrics() failed",
-						"component", component,
-						"system_id", e.Client.System.ID,
-						"error", err.Error(),
-					)
-					e.IncrementErrorCounter()
-				} else {
-					for facility, value := range metrics {
-						e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-							memUsage,
-							prometheus.GaugeValue,
-							value,
-							e.Client.System.ID,
-							component,
-							facility,
-						))
-					}
-				}
-				level.Debug(e.logger).Log(
-					"msg", "GatherMetrics() completed GetAppMemoryMetrics()",
-					"component", component,
-					"system_id", e.Client.System.ID,
-				)
-			}
-			if cmds["cluster/status DB"] {
-				level.Debug(e.logger).Log(
-					"msg", "GatherMetrics() calls GetAppClusteringInfo()",
-					"component", component,
-					"system_id", e.Client.System.ID,
-				)
-				if cluster, err := e.Client.GetAppClusteringInfo(component); err != nil {
-					isClusterEnabled = false
-					level.Error(e.logger).Log(
-						"msg", "GetAppClusteringInfo() failed",
-						"component", component,
-						"system_id", e.Client.System.ID,
-						"error", err.Error(),
-					)
-					//e.IncrementErrorCounter()
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterEnabled,
-						prometheus.GaugeValue,
-						0,
-						e.Client.System.ID,
-						component,
-					))
-				} else {
-					isClusterEnabled = true
-					switch component {
-					case "ovsdb-server-southbound":
-						southClusterID = cluster.ClusterID
-					case "ovsdb-server-northbound":
-						northClusterID = cluster.ClusterID
-					}
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterEnabled,
-						prometheus.GaugeValue,
-						1,
-						e.Client.System.ID,
-						component,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterRole,
-						prometheus.GaugeValue,
-						float64(cluster.Role),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.UUID,
-						cluster.ClusterID,
-						cluster.ClusterUUID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterStatus,
-						prometheus.GaugeValue,
-						float64(cluster.Status),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterTerm,
-						prometheus.CounterValue,
-						float64(cluster.Term),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterNotCommittedEntryCount,
-						prometheus.GaugeValue,
-						float64(cluster.NotCommittedEntries),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterNotAppliedEntryCount,
-						prometheus.GaugeValue,
-						float64(cluster.NotAppliedEntries),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterNextIndex,
-						prometheus.CounterValue,
-						float64(cluster.NextIndex),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterMatchIndex,
-						prometheus.CounterValue,
-						float64(cluster.MatchIndex),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterLogLowIndex,
-						prometheus.CounterValue,
-						float64(cluster.Log.Low),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterLogHighIndex,
-						prometheus.CounterValue,
-						float64(cluster.Log.High),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterLeaderSelf,
-						prometheus.GaugeValue,
-						float64(cluster.IsLeaderSelf),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterVoteSelf,
-						prometheus.GaugeValue,
-						float64(cluster.IsVotedSelf),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterPeerCount,
-						prometheus.GaugeValue,
-						float64(len(cluster.Peers)),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterPeerInConnTotal,
-						prometheus.GaugeValue,
-						float64(cluster.Connections.Inbound),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-						clusterPeerOutConnTotal,
-						prometheus.GaugeValue,
-						float64(cluster.Connections.Outbound),
-						e.Client.System.ID,
-						component,
-						cluster.ID,
-						cluster.ClusterID,
-					))
-					for peerID, peer := range cluster.Peers {
-						e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-							clusterPeerNextIndex,
-							prometheus.CounterValue,
-							float64(peer.NextIndex),
-							e.Client.System.ID,
-							component,
-							cluster.ID,
-							cluster.ClusterID,
-							peerID,
-						))
-						e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-							clusterPeerMatchIndex,
-							prometheus.CounterValue,
-							float64(peer.MatchIndex),
-							e.Client.System.ID,
-							component,
-							cluster.ID,
-							cluster.ClusterID,
-							peerID,
-						))
-						e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-							clusterPeerInConnInfo,
-							prometheus.GaugeValue,
-							float64(peer.Connection.Inbound),
-							e.Client.System.ID,
-							component,
-							cluster.ID,
-							cluster.ClusterID,
-							peerID,
-							peer.Address,
-						))
-						e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-							clusterPeerOutConnInfo,
-							prometheus.GaugeValue,
-							float64(peer.Connection.Outbound),
-							e.Client.System.ID,
-							component,
-							cluster.ID,
-							cluster.ClusterID,
-							peerID,
-							peer.Address,
-						))
-					}
-					//log.Infof("%s: %v", component, cluster)
-				}
-				level.Debug(e.logger).Log(
-					"msg", "GatherMetrics() completed GetAppClusteringInfo()",
-					"component", component,
-					"system_id", e.Client.System.ID,
-				)
-			}
+	results := make([]collectorResult, len(collectors))
+	var wg sync.WaitGroup
+	for i, c := range collectors {
+		if c.blocking {
+			results[i] = runCollector(ctx, e, c)
+			continue
 		}
+		wg.Add(1)
+		go func(i int, c namedCollector) {
+			defer wg.Done()
+			results[i] = runCollector(ctx, e, c)
+		}(i, c)
 	}
+	wg.Wait()
+	return results
+}
 
-	if northClusterID != "" && southClusterID != "" {
-		e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-			clusterGroup,
-			prometheus.GaugeValue,
-			1,
-			e.Client.System.ID,
-			northClusterID+southClusterID,
-		))
-	}
+// collectorResult is the outcome of a single run of a collector.
+type collectorResult struct {
+	metrics  []prometheus.Metric
+	duration time.Duration
+	err      error
+}
 
-	components = []string{
-		"ovsdb-server-southbound",
-		"ovsdb-server-northbound",
+func runCollector(ctx context.Context, e *Exporter, c namedCollector) collectorResult {
+	startedAt := time.Now()
+	metrics, err := c.collector.Update(ctx, e)
+	return collectorResult{
+		metrics:  metrics,
+		duration: time.Since(startedAt),
+		err:      err,
 	}
+}
 
-	for _, component := range components {
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() calls IsDefaultPortUp()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		defaultPortUp, err := e.Client.IsDefaultPortUp(component)
-		if err != nil {
-			level.Error(e.logger).Log(
-				"msg", "IsDefaultPortUp() failed",
-				"component", component,
-				"system_id", e.Client.System.ID,
-				"error", err.Error(),
-			)
-			e.IncrementErrorCounter()
-		}
-		e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-			networkPortUp,
+// newScrapeMetrics returns the metrics describing a run of a collection
+// step. The time of the last successful run is kept across collections.
+func (e *Exporter) newScrapeMetrics(name string, duration time.Duration, err error) []prometheus.Metric {
+	success := 0
+	if err == nil {
+		success = 1
+		e.lastSuccess[name] = time.Now()
+	}
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(
+			scrapeCollectorDuration,
 			prometheus.GaugeValue,
-			float64(defaultPortUp),
-			e.Client.System.ID,
-			component,
-			"default",
-		))
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() completed IsDefaultPortUp()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() calls IsSslPortUp()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
-		sslPortUp, err := e.Client.IsSslPortUp(component)
-		if err != nil {
-			level.Error(e.logger).Log(
-				"msg", "IsSslPortUp() failed",
-				"component", component,
-				"system_id", e.Client.System.ID,
-				"error", err.Error(),
-			)
-			e.IncrementErrorCounter()
-		}
-		e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-			networkPortUp,
+			duration.Seconds(),
+			name,
+		),
+		prometheus.MustNewConstMetric(
+			scrapeCollectorSuccess,
 			prometheus.GaugeValue,
-			float64(sslPortUp),
-			e.Client.System.ID,
-			component,
-			"ssl",
+			float64(success),
+			name,
+		),
+	}
+	if lastSuccess, exists := e.lastSuccess[name]; exists {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			scrapeCollectorLastSuccess,
+			prometheus.GaugeValue,
+			float64(lastSuccess.UnixNano())/1e9,
+			name,
 		))
-		level.Debug(e.logger).Log(
-			"msg", "GatherMetrics() completed IsSslPortUp()",
-			"component", component,
-			"system_id", e.Client.System.ID,
-		)
+	}
+	return metrics
+}
 
-		if isClusterEnabled {
-			level.Debug(e.logger).Log(
-				"msg", "GatherMetrics() calls IsRaftPortUp()",
-				"component", component,
-				"system_id", e.Client.System.ID,
-			)
-			raftPortUp, err := e.Client.IsRaftPortUp(component)
-			if err != nil {
-				level.Error(e.logger).Log(
-					"msg", "IsRaftPortUp() failed",
-					"component", component,
-					"system_id", e.Client.System.ID,
-					"error", err.Error(),
-				)
-				e.IncrementErrorCounter()
-			}
-			e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
-				networkPortUp,
-				prometheus.GaugeValue,
-				float64(raftPortUp),
-				e.Client.System.ID,
-				component,
-				"raft",
-			))
-			level.Debug(e.logger).Log(
-				"msg", "GatherMetrics() completed IsRaftPortUp()",
-				"component", component,
-				"system_id", e.Client.System.ID,
-			)
-		}
+// updateSystemInfo refreshes the system information of the OVN client.
+func (e *Exporter) updateSystemInfo(ctx context.Context) error {
+	src := e.dataSource()
+	var info SystemInfo
+	err := e.runDatabaseStep(ctx, "GetSystemInfo()", func() error {
+		var err error
+		info, err = src.GetSystemInfo()
+		return err
+	})
+	if err != nil {
+		return err
 	}
+	e.updateClient(func(c *ovsdb.OvnClient) {
+		c.System.ID = info.ID
+		c.System.RunDir = info.RunDir
+		c.System.Hostname = info.Hostname
+		c.System.Type = info.Type
+		c.System.Version = info.Version
+		c.Database.Vswitch.Version = info.OvsVersion
+		c.Database.Vswitch.Schema.Version = info.SchemaVersion
+	})
+	return nil
+}
 
-	e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
+// newStatusMetrics returns the metrics describing the exporter itself
+// and the OVN stack as a whole.
+func (e *Exporter) newStatusMetrics(upValue int) []prometheus.Metric {
+	metrics := []prometheus.Metric{}
+	metrics = append(metrics, prometheus.MustNewConstMetric(
 		up,
 		prometheus.GaugeValue,
 		float64(upValue),
 	))
 
-	e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
+	metrics = append(metrics, prometheus.MustNewConstMetric(
 		info,
 		prometheus.GaugeValue,
 		1,
@@ -1232,31 +980,25 @@ func (e *Exporter) GatherMetrics() {
 		e.Client.Database.Vswitch.Version, e.Client.Database.Vswitch.Schema.Version,
 	))
 
-	e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
+	metrics = append(metrics, newCounter(
 		requestErrors,
-		prometheus.CounterValue,
-		float64(e.errors),
+		float64(atomic.LoadInt64(&e.errors)),
+		e.createdAt,
 		e.Client.System.ID,
 	))
 
-	e.metrics = append(e.metrics, prometheus.MustNewConstMetric(
+	metrics = append(metrics, prometheus.MustNewConstMetric(
 		nextPoll,
 		prometheus.CounterValue,
-		float64(e.nextCollectionTicker),
+		float64(atomic.LoadInt64(&e.nextCollectionTicker)),
 		e.Client.System.ID,
 	))
 
-	e.nextCollectionTicker = time.Now().Add(time.Duration(e.pollInterval) * time.Second).Unix()
-
-	level.Debug(e.logger).Log(
-		"msg", "GatherMetrics() returns",
-		"system_id", e.Client.System.ID,
-	)
-	return
+	return metrics
 }
 
 func init() {
-	prometheus.MustRegister(version.NewCollector(namespace + "_exporter"))
+	prometheus.MustRegister(versioncollector.NewCollector(namespace + "_exporter"))
 }
 
 // GetVersionInfo returns exporter info.
@@ -1289,7 +1031,17 @@ func GetExporterVersion() string {
 	return app.Banner()
 }
 
-// SetPollInterval sets exporter's polling interval.
+// SetPollInterval sets exporter's polling interval, in seconds. It is the
+// interval of the system information and of the collectors without an
+// interval of their own.
 func (e *Exporter) SetPollInterval(i int64) {
-	e.pollInterval = i
+	atomic.StoreInt64(&e.pollInterval, i)
+}
+
+func (e *Exporter) getPollInterval() time.Duration {
+	i := atomic.LoadInt64(&e.pollInterval)
+	if i < 1 {
+		i = defaultPollInterval
+	}
+	return time.Duration(i) * time.Second
 }
@@ -14,10 +14,88 @@
 
 package ovn_exporter
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 func TestNewExporter(t *testing.T) {
 	if _, err := NewExporter(Options{}); err != nil {
 		t.Errorf("expected no error, but got %q", err)
 	}
 }
+
+func newTestExporter(t *testing.T) *Exporter {
+	t.Helper()
+	states := make(map[string]bool)
+	for _, name := range GetCollectorNames() {
+		states[name] = false
+	}
+	e, err := NewExporter(Options{Collectors: states})
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	return e
+}
+
+func TestCollectDoesNotWaitForCollection(t *testing.T) {
+	e := newTestExporter(t)
+
+	// Simulate a collection in progress.
+	e.Lock()
+	defer e.Unlock()
+
+	done := make(chan int)
+	go func() {
+		ch := make(chan prometheus.Metric, 16)
+		e.Collect(ch)
+		close(ch)
+		done <- len(ch)
+	}()
+	select {
+	case n := <-done:
+		if n == 0 {
+			t.Errorf("expected status metrics, but got none")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Collect() blocked on a collection in progress")
+	}
+}
+
+func TestRunPublishesSnapshot(t *testing.T) {
+	e := newTestExporter(t)
+	initial := e.snapshot.Load()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.snapshot.Load() == initial {
+		if time.Now().After(deadline) {
+			t.Fatalf("Run() did not publish a snapshot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGatherMetricsScrapeMetrics(t *testing.T) {
+	e := newTestExporter(t)
+	e.GatherMetrics()
+
+	ch := make(chan prometheus.Metric, 64)
+	e.Collect(ch)
+	close(ch)
+	found := false
+	for m := range ch {
+		if m.Desc() == scrapeCollectorDuration {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected %s metric, but got none", scrapeCollectorDuration)
+	}
+}
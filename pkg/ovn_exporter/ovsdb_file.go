@@ -0,0 +1,699 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The magic strings of the OVSDB file formats. A standalone database is a
+// log of transactions, and a clustered database is a raft log whose
+// entries hold the transactions.
+const (
+	dbFileMagicStandalone = "OVSDB JSON"
+	dbFileMagicClustered  = "OVSDB CLUSTER"
+)
+
+// dbFile is an OVSDB database read from its file, with all the
+// transactions of the file replayed.
+type dbFile struct {
+	path      string
+	clustered bool
+	schema    *dbSchema
+	tables    map[string]map[string]dbRow
+}
+
+// dbSchema holds the parts of an OVSDB schema needed to replay the
+// transactions: the name of the database and the types of the columns.
+type dbSchema struct {
+	name    string
+	version string
+	tables  map[string]map[string]dbColumnType
+}
+
+// dbColumnType is the type of a column. The value is empty unless the
+// column is a map. A max of -1 stands for "unlimited".
+type dbColumnType struct {
+	key   string
+	value string
+	min   int
+	max   int
+}
+
+// dbRow is a row of a table, by column name.
+type dbRow map[string]dbDatum
+
+// dbDatum is the value of a column. Scalars, sets and maps all hold their
+// atoms in the keys. Only maps have values.
+type dbDatum struct {
+	keys   []interface{}
+	values []interface{}
+}
+
+// dbUUID is an atom of the uuid type.
+type dbUUID string
+
+const dbZeroUUID = dbUUID("00000000-0000-0000-0000-000000000000")
+
+// readDBFile reads an OVSDB database file in the standalone or the
+// clustered format. A record cut short at the end of the file, as left
+// by a server writing it, is ignored.
+func readDBFile(path string) (*dbFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	magic, records, err := readDBRecords(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	db := &dbFile{
+		path:   path,
+		tables: make(map[string]map[string]dbRow),
+	}
+	switch magic {
+	case dbFileMagicStandalone:
+		err = db.replayStandalone(records)
+	case dbFileMagicClustered:
+		db.clustered = true
+		err = db.replayClustered(records)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if db.schema == nil {
+		return nil, fmt.Errorf("%s: no schema found", path)
+	}
+	return db, nil
+}
+
+// readDBRecords splits the file into its records. Each record is a header
+// line with the magic, the length and the SHA-1 of the JSON text that
+// follows it.
+func readDBRecords(r *bufio.Reader) (string, []interface{}, error) {
+	var magic string
+	records := []interface{}{}
+	for offset := 0; ; {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return magic, records, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 4 || fields[0] != "OVSDB" {
+			return "", nil, fmt.Errorf("offset %d: invalid record header %q", offset, strings.TrimSpace(header))
+		}
+		recordMagic := fields[0] + " " + fields[1]
+		switch {
+		case recordMagic != dbFileMagicStandalone && recordMagic != dbFileMagicClustered:
+			return "", nil, fmt.Errorf("offset %d: unsupported file format %q", offset, recordMagic)
+		case magic == "":
+			magic = recordMagic
+		case magic != recordMagic:
+			return "", nil, fmt.Errorf("offset %d: record of format %q in %q file", offset, recordMagic, magic)
+		}
+		length, err := strconv.Atoi(fields[2])
+		if err != nil || length < 0 {
+			return "", nil, fmt.Errorf("offset %d: invalid record length %q", offset, fields[2])
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return magic, records, nil
+			}
+			return "", nil, err
+		}
+		sum := sha1.Sum(data)
+		if hex.EncodeToString(sum[:]) != fields[3] {
+			return "", nil, fmt.Errorf("offset %d: record checksum mismatch", offset)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var record interface{}
+		if err := decoder.Decode(&record); err != nil {
+			return "", nil, fmt.Errorf("offset %d: %s", offset, err)
+		}
+		records = append(records, record)
+		offset += len(header) + length
+	}
+}
+
+// replayStandalone replays a standalone database. Its first record is the
+// schema and each of the others is a transaction.
+func (db *dbFile) replayStandalone(records []interface{}) error {
+	if len(records) == 0 {
+		return nil
+	}
+	schema, err := parseDBSchema(records[0])
+	if err != nil {
+		return err
+	}
+	db.schema = schema
+	for i, record := range records[1:] {
+		if err := db.applyTransaction(record); err != nil {
+			return fmt.Errorf("record %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// replayClustered replays a clustered database. The header, its first
+// record, holds the snapshot of the database up to the start of the log.
+// The entries of the log follow. An entry with the index of an earlier
+// entry replaces it and the entries after it, as the raft leader does
+// when a follower diverges.
+func (db *dbFile) replayClustered(records []interface{}) error {
+	if len(records) == 0 {
+		return nil
+	}
+	header, ok := records[0].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid raft header")
+	}
+	if data, exists := header["prev_data"]; exists {
+		if err := db.applyRaftData(data); err != nil {
+			return fmt.Errorf("snapshot: %s", err)
+		}
+	}
+	var firstIndex uint64 = 1
+	if n, ok := header["prev_index"].(json.Number); ok {
+		prevIndex, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid prev_index %q", n)
+		}
+		firstIndex = prevIndex + 1
+	}
+	entries := []interface{}{}
+	for i, record := range records[1:] {
+		r, ok := record.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("record %d: invalid raft record", i+1)
+		}
+		n, ok := r["index"].(json.Number)
+		if !ok {
+			// Votes, commits and leader notes do not change the data.
+			continue
+		}
+		index, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil || index < firstIndex || index > firstIndex+uint64(len(entries)) {
+			return fmt.Errorf("record %d: unexpected raft index %s", i+1, n)
+		}
+		entries = append(entries[:index-firstIndex], r["data"])
+	}
+	for i, data := range entries {
+		if data == nil {
+			continue
+		}
+		if err := db.applyRaftData(data); err != nil {
+			return fmt.Errorf("raft index %d: %s", firstIndex+uint64(i), err)
+		}
+	}
+	return nil
+}
+
+// applyRaftData applies the data of a raft entry, a pair of a schema and a
+// transaction. A schema with a transaction replaces the whole database.
+// A schema alone converts the database to it.
+func (db *dbFile) applyRaftData(data interface{}) error {
+	pair, ok := data.([]interface{})
+	if !ok || len(pair) != 2 {
+		return fmt.Errorf("invalid raft entry data")
+	}
+	if pair[0] != nil {
+		schema, err := parseDBSchema(pair[0])
+		if err != nil {
+			return err
+		}
+		db.schema = schema
+		if pair[1] != nil {
+			db.tables = make(map[string]map[string]dbRow)
+		} else {
+			db.convert()
+		}
+	}
+	if pair[1] == nil {
+		return nil
+	}
+	if db.schema == nil {
+		return fmt.Errorf("transaction without schema")
+	}
+	return db.applyTransaction(pair[1])
+}
+
+// convert drops the tables and columns missing from the schema, and adds
+// the new columns with their default values.
+func (db *dbFile) convert() {
+	for tableName, rows := range db.tables {
+		columns, exists := db.schema.tables[tableName]
+		if !exists {
+			delete(db.tables, tableName)
+			continue
+		}
+		for _, row := range rows {
+			for name := range row {
+				if _, exists := columns[name]; !exists {
+					delete(row, name)
+				}
+			}
+			for name, t := range columns {
+				if _, exists := row[name]; !exists {
+					row[name] = t.defaultDatum()
+				}
+			}
+		}
+	}
+}
+
+// applyTransaction applies a transaction record. The record maps the
+// tables to the changed rows, and a null row deletes it. The rows of a
+// transaction with "_is_diff" hold the differences of their columns
+// rather than the new values.
+func (db *dbFile) applyTransaction(record interface{}) error {
+	txn, ok := record.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("transaction is not an object")
+	}
+	isDiff, _ := txn["_is_diff"].(bool)
+	for tableName, v := range txn {
+		if strings.HasPrefix(tableName, "_") {
+			continue
+		}
+		columns, exists := db.schema.tables[tableName]
+		if !exists {
+			return fmt.Errorf("unknown table %s", tableName)
+		}
+		changes, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("table %s: changes are not an object", tableName)
+		}
+		rows := db.tables[tableName]
+		if rows == nil {
+			rows = make(map[string]dbRow)
+			db.tables[tableName] = rows
+		}
+		for uuid, change := range changes {
+			if change == nil {
+				if _, exists := rows[uuid]; !exists {
+					return fmt.Errorf("table %s: transaction deletes missing row %s", tableName, uuid)
+				}
+				delete(rows, uuid)
+				continue
+			}
+			values, ok := change.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("table %s: row %s is not an object", tableName, uuid)
+			}
+			row, existed := rows[uuid]
+			if !existed {
+				row = make(dbRow)
+				for name, t := range columns {
+					row[name] = t.defaultDatum()
+				}
+				rows[uuid] = row
+			}
+			for name, value := range values {
+				t, exists := columns[name]
+				if !exists {
+					if strings.HasPrefix(name, "_") {
+						continue
+					}
+					return fmt.Errorf("table %s: unknown column %s", tableName, name)
+				}
+				d, err := parseDBDatum(value, t)
+				if err != nil {
+					return fmt.Errorf("table %s: row %s: column %s: %s", tableName, uuid, name, err)
+				}
+				if existed && isDiff {
+					d = row[name].applyDiff(d, t)
+				}
+				row[name] = d
+			}
+		}
+	}
+	return nil
+}
+
+// parseDBSchema parses the parts of a schema needed to replay the
+// transactions.
+func parseDBSchema(v interface{}) (*dbSchema, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema is not an object")
+	}
+	schema := &dbSchema{tables: make(map[string]map[string]dbColumnType)}
+	schema.name, _ = obj["name"].(string)
+	schema.version, _ = obj["version"].(string)
+	if schema.name == "" {
+		return nil, fmt.Errorf("schema without name")
+	}
+	tables, ok := obj["tables"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema %s: no tables", schema.name)
+	}
+	for tableName, t := range tables {
+		table, _ := t.(map[string]interface{})
+		columns, ok := table["columns"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema %s: table %s: no columns", schema.name, tableName)
+		}
+		schema.tables[tableName] = make(map[string]dbColumnType)
+		for name, c := range columns {
+			column, _ := c.(map[string]interface{})
+			t, err := parseDBColumnType(column["type"])
+			if err != nil {
+				return nil, fmt.Errorf("schema %s: table %s: column %s: %s", schema.name, tableName, name, err)
+			}
+			schema.tables[tableName][name] = t
+		}
+	}
+	return schema, nil
+}
+
+func parseDBColumnType(v interface{}) (dbColumnType, error) {
+	t := dbColumnType{min: 1, max: 1}
+	switch v := v.(type) {
+	case string:
+		t.key = v
+		return t, nil
+	case map[string]interface{}:
+		var err error
+		if t.key, err = parseDBBaseType(v["key"]); err != nil {
+			return t, err
+		}
+		if value, exists := v["value"]; exists {
+			if t.value, err = parseDBBaseType(value); err != nil {
+				return t, err
+			}
+		}
+		if n, ok := v["min"].(json.Number); ok {
+			min, err := strconv.Atoi(n.String())
+			if err != nil {
+				return t, fmt.Errorf("invalid min %q", n)
+			}
+			t.min = min
+		}
+		switch n := v["max"].(type) {
+		case json.Number:
+			max, err := strconv.Atoi(n.String())
+			if err != nil {
+				return t, fmt.Errorf("invalid max %q", n)
+			}
+			t.max = max
+		case string:
+			if n != "unlimited" {
+				return t, fmt.Errorf("invalid max %q", n)
+			}
+			t.max = -1
+		}
+		return t, nil
+	}
+	return t, fmt.Errorf("invalid type")
+}
+
+func parseDBBaseType(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		if t, ok := v["type"].(string); ok {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid base type")
+}
+
+// isScalar returns true when the column holds exactly one atom.
+func (t dbColumnType) isScalar() bool {
+	return t.min == 1 && t.max == 1 && t.value == ""
+}
+
+// defaultDatum returns the value of the column in a new row.
+func (t dbColumnType) defaultDatum() dbDatum {
+	if t.min == 0 {
+		return dbDatum{}
+	}
+	d := dbDatum{keys: []interface{}{defaultDBAtom(t.key)}}
+	if t.value != "" {
+		d.values = []interface{}{defaultDBAtom(t.value)}
+	}
+	return d
+}
+
+func defaultDBAtom(t string) interface{} {
+	switch t {
+	case "integer":
+		return int64(0)
+	case "real":
+		return float64(0)
+	case "boolean":
+		return false
+	case "uuid":
+		return dbZeroUUID
+	}
+	return ""
+}
+
+// parseDBDatum parses a value in the OVSDB JSON notation. A set is either
+// ["set", [atoms]] or a single atom, and a map is ["map", [[key, value]]].
+func parseDBDatum(v interface{}, t dbColumnType) (dbDatum, error) {
+	d := dbDatum{}
+	if a, ok := v.([]interface{}); ok && len(a) == 2 {
+		switch a[0] {
+		case "set":
+			atoms, ok := a[1].([]interface{})
+			if !ok {
+				return d, fmt.Errorf("invalid set")
+			}
+			for _, atom := range atoms {
+				key, err := parseDBAtom(atom, t.key)
+				if err != nil {
+					return d, err
+				}
+				d.keys = append(d.keys, key)
+			}
+			return d, nil
+		case "map":
+			pairs, ok := a[1].([]interface{})
+			if !ok {
+				return d, fmt.Errorf("invalid map")
+			}
+			for _, p := range pairs {
+				pair, ok := p.([]interface{})
+				if !ok || len(pair) != 2 {
+					return d, fmt.Errorf("invalid map pair")
+				}
+				key, err := parseDBAtom(pair[0], t.key)
+				if err != nil {
+					return d, err
+				}
+				value, err := parseDBAtom(pair[1], t.value)
+				if err != nil {
+					return d, err
+				}
+				d.keys = append(d.keys, key)
+				d.values = append(d.values, value)
+			}
+			return d, nil
+		}
+	}
+	key, err := parseDBAtom(v, t.key)
+	if err != nil {
+		return d, err
+	}
+	d.keys = append(d.keys, key)
+	return d, nil
+}
+
+func parseDBAtom(v interface{}, t string) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return v, nil
+	case json.Number:
+		if t == "real" {
+			return v.Float64()
+		}
+		return v.Int64()
+	case []interface{}:
+		if len(v) == 2 && v[0] == "uuid" {
+			if s, ok := v[1].(string); ok {
+				return dbUUID(s), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid atom %v", v)
+}
+
+// applyDiff returns the datum changed by a diff. The diff of a scalar is
+// its new value. The atoms in the diff of a set are added when missing and
+// removed when present. The pairs in the diff of a map are added when the
+// key is missing, removed when the pair is present and otherwise replace
+// the value of the key.
+func (d dbDatum) applyDiff(diff dbDatum, t dbColumnType) dbDatum {
+	if t.isScalar() {
+		return diff
+	}
+	result := dbDatum{
+		keys: append([]interface{}{}, d.keys...),
+	}
+	if t.value != "" {
+		result.values = append([]interface{}{}, d.values...)
+	}
+	for i, key := range diff.keys {
+		j := result.find(key)
+		switch {
+		case j < 0:
+			result.keys = append(result.keys, key)
+			if t.value != "" {
+				result.values = append(result.values, diff.values[i])
+			}
+		case t.value != "" && result.values[j] != diff.values[i]:
+			result.values[j] = diff.values[i]
+		default:
+			result.keys = append(result.keys[:j], result.keys[j+1:]...)
+			if t.value != "" {
+				result.values = append(result.values[:j], result.values[j+1:]...)
+			}
+		}
+	}
+	return result
+}
+
+func (d dbDatum) find(key interface{}) int {
+	for i, k := range d.keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// dbTableRow is a row with its UUID.
+type dbTableRow struct {
+	uuid string
+	row  dbRow
+}
+
+// rows returns the rows of the table, ordered by UUID.
+func (db *dbFile) rows(table string) ([]dbTableRow, error) {
+	if _, exists := db.schema.tables[table]; !exists {
+		return nil, fmt.Errorf("%s: '%s' table error: unknown table", db.schema.name, table)
+	}
+	rows := []dbTableRow{}
+	for uuid, row := range db.tables[table] {
+		rows = append(rows, dbTableRow{uuid: uuid, row: row})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].uuid < rows[j].uuid
+	})
+	return rows, nil
+}
+
+// uuid returns the UUID held by the column, when it holds exactly one.
+func (r dbRow) uuid(column string) (string, bool) {
+	d := r[column]
+	if len(d.keys) != 1 {
+		return "", false
+	}
+	v, ok := d.keys[0].(dbUUID)
+	return string(v), ok
+}
+
+// uuids returns the UUIDs held by the column, in order.
+func (r dbRow) uuids(column string) []string {
+	uuids := []string{}
+	for _, k := range r[column].keys {
+		if v, ok := k.(dbUUID); ok {
+			uuids = append(uuids, string(v))
+		}
+	}
+	sort.Strings(uuids)
+	return uuids
+}
+
+// str returns the string held by the column, when it holds exactly one.
+func (r dbRow) str(column string) (string, bool) {
+	d := r[column]
+	if len(d.keys) != 1 {
+		return "", false
+	}
+	v, ok := d.keys[0].(string)
+	return v, ok
+}
+
+// strs returns the strings held by the column, in order.
+func (r dbRow) strs(column string) []string {
+	strs := []string{}
+	for _, k := range r[column].keys {
+		if v, ok := k.(string); ok {
+			strs = append(strs, v)
+		}
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// integer returns the integer held by the column, when it holds exactly
+// one.
+func (r dbRow) integer(column string) (int64, bool) {
+	d := r[column]
+	if len(d.keys) != 1 {
+		return 0, false
+	}
+	v, ok := d.keys[0].(int64)
+	return v, ok
+}
+
+// boolean returns the boolean held by the column, when it holds exactly
+// one.
+func (r dbRow) boolean(column string) (bool, bool) {
+	d := r[column]
+	if len(d.keys) != 1 {
+		return false, false
+	}
+	v, ok := d.keys[0].(bool)
+	return v, ok
+}
+
+// stringMap returns the column as a map of strings to strings.
+func (r dbRow) stringMap(column string) map[string]string {
+	m := make(map[string]string)
+	d := r[column]
+	for i, k := range d.keys {
+		key, ok := k.(string)
+		if !ok || i >= len(d.values) {
+			continue
+		}
+		if value, ok := d.values[i].(string); ok {
+			m[key] = value
+		}
+	}
+	return m
+}
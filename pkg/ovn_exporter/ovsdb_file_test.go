@@ -0,0 +1,188 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// dbRecord returns a record of a database file holding the JSON encoding
+// of v.
+func dbRecord(t *testing.T, magic string, v interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	b = append(b, '\n')
+	return append([]byte(fmt.Sprintf("%s %d %x\n", magic, len(b), sha1.Sum(b))), b...)
+}
+
+func TestReadDBFile(t *testing.T) {
+	testcases := []struct {
+		path      string
+		name      string
+		clustered bool
+		rows      map[string]int
+	}{
+		{
+			path: "testdata/ovnnb_db.db",
+			name: "OVN_Northbound",
+			rows: map[string]int{"NB_Global": 1, "Logical_Switch": 2, "Logical_Switch_Port": 3, "Logical_Router": 1, "Logical_Router_Port": 2, "Logical_Router_Static_Route": 4, "Logical_Router_Policy": 2, "NAT": 3, "Load_Balancer": 3, "Load_Balancer_Group": 1},
+		},
+		{
+			path:      "testdata/ovnsb_db.db",
+			name:      "OVN_Southbound",
+			clustered: true,
+			rows:      map[string]int{"Chassis": 2, "Encap": 2, "Datapath_Binding": 3, "Port_Binding": 3, "Service_Monitor": 2},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			db, err := readDBFile(tc.path)
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err)
+			}
+			if db.schema.name != tc.name {
+				t.Errorf("expected database %s, but got %s", tc.name, db.schema.name)
+			}
+			if db.clustered != tc.clustered {
+				t.Errorf("expected clustered %t, but got %t", tc.clustered, db.clustered)
+			}
+			for table, n := range tc.rows {
+				rows, err := db.rows(table)
+				if err != nil {
+					t.Fatalf("expected no error, but got %q", err)
+				}
+				if len(rows) != n {
+					t.Errorf("expected %d rows in %s, but got %d", n, table, len(rows))
+				}
+			}
+		})
+	}
+}
+
+func TestReadDBRecords(t *testing.T) {
+	schema := map[string]interface{}{"name": "test", "tables": map[string]interface{}{}}
+	valid := dbRecord(t, dbFileMagicStandalone, schema)
+	testcases := []struct {
+		name      string
+		data      []byte
+		records   int
+		shouldErr bool
+	}{
+		{
+			name:    "empty file",
+			records: 0,
+		},
+		{
+			name:    "single record",
+			data:    valid,
+			records: 1,
+		},
+		{
+			name:    "truncated last record",
+			data:    append(append([]byte{}, valid...), valid[:len(valid)-5]...),
+			records: 1,
+		},
+		{
+			name:      "checksum mismatch",
+			data:      bytes.Replace(valid, []byte("test"), []byte("tset"), 1),
+			shouldErr: true,
+		},
+		{
+			name:      "invalid header",
+			data:      []byte("SQLite format 3\n"),
+			shouldErr: true,
+		},
+		{
+			name:      "mixed formats",
+			data:      append(append([]byte{}, valid...), dbRecord(t, dbFileMagicClustered, schema)...),
+			shouldErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, records, err := readDBRecords(bufio.NewReader(bytes.NewReader(tc.data)))
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err)
+			}
+			if len(records) != tc.records {
+				t.Errorf("expected %d records, but got %d", tc.records, len(records))
+			}
+		})
+	}
+}
+
+func TestApplyDiff(t *testing.T) {
+	set := dbColumnType{key: "string", max: -1}
+	strMap := dbColumnType{key: "string", value: "string", max: -1}
+	testcases := []struct {
+		name string
+		t    dbColumnType
+		old  dbDatum
+		diff dbDatum
+		want dbDatum
+	}{
+		{
+			name: "scalar is replaced",
+			t:    dbColumnType{key: "integer", min: 1, max: 1},
+			old:  dbDatum{keys: []interface{}{int64(1)}},
+			diff: dbDatum{keys: []interface{}{int64(2)}},
+			want: dbDatum{keys: []interface{}{int64(2)}},
+		},
+		{
+			name: "set elements are toggled",
+			t:    set,
+			old:  dbDatum{keys: []interface{}{"a", "b"}},
+			diff: dbDatum{keys: []interface{}{"b", "c"}},
+			want: dbDatum{keys: []interface{}{"a", "c"}},
+		},
+		{
+			name: "optional reference is moved",
+			t:    dbColumnType{key: "uuid", max: 1},
+			old:  dbDatum{keys: []interface{}{dbUUID("x")}},
+			diff: dbDatum{keys: []interface{}{dbUUID("x"), dbUUID("y")}},
+			want: dbDatum{keys: []interface{}{dbUUID("y")}},
+		},
+		{
+			name: "map pairs are added, updated and removed",
+			t:    strMap,
+			old:  dbDatum{keys: []interface{}{"a", "b"}, values: []interface{}{"1", "2"}},
+			diff: dbDatum{keys: []interface{}{"a", "b", "c"}, values: []interface{}{"1", "3", "4"}},
+			want: dbDatum{keys: []interface{}{"b", "c"}, values: []interface{}{"3", "4"}},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.old.applyDiff(tc.diff, tc.t)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
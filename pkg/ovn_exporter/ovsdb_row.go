@@ -0,0 +1,104 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"github.com/greenpau/ovsdb"
+)
+
+// getStringColumn returns the value of a string column of a row, or an
+// empty string when the column is unset or has a different type.
+func getStringColumn(row ovsdb.Row, column string, columns map[string]string) string {
+	if _, exists := row[column]; !exists {
+		return ""
+	}
+	v, dt, err := row.GetColumnValue(column, columns)
+	if err != nil || dt != "string" {
+		return ""
+	}
+	return v.(string)
+}
+
+// getBoolColumn returns the value of a boolean column of a row, or false
+// when the column is unset or has a different type.
+func getBoolColumn(row ovsdb.Row, column string, columns map[string]string) bool {
+	if _, exists := row[column]; !exists {
+		return false
+	}
+	v, dt, err := row.GetColumnValue(column, columns)
+	if err != nil || dt != "bool" {
+		return false
+	}
+	return v.(bool)
+}
+
+// getStringsColumn returns the strings of a set column of a row. A set
+// holding a single string is returned as a scalar by the server.
+func getStringsColumn(row ovsdb.Row, column string, columns map[string]string) []string {
+	if _, exists := row[column]; !exists {
+		return []string{}
+	}
+	v, dt, err := row.GetColumnValue(column, columns)
+	if err != nil {
+		return []string{}
+	}
+	switch dt {
+	case "string":
+		return []string{v.(string)}
+	case "[]string":
+		return v.([]string)
+	}
+	return []string{}
+}
+
+// getIntegerColumn returns the value of an integer column of a row, or
+// false when the column is unset or has a different type.
+func getIntegerColumn(row ovsdb.Row, column string, columns map[string]string) (int64, bool) {
+	if _, exists := row[column]; !exists {
+		return 0, false
+	}
+	v, dt, err := row.GetColumnValue(column, columns)
+	if err != nil || dt != "integer" {
+		return 0, false
+	}
+	switch i := v.(type) {
+	case int64:
+		return i, true
+	case int:
+		return int64(i), true
+	}
+	return 0, false
+}
+
+// getStringMapColumn returns the value of a map column of strings to
+// strings of a row, or an empty map when the column is unset or has a
+// different type.
+func getStringMapColumn(row ovsdb.Row, column string, columns map[string]string) map[string]string {
+	if _, exists := row[column]; !exists {
+		return map[string]string{}
+	}
+	v, dt, err := row.GetColumnValue(column, columns)
+	if err != nil || dt != "map[string]string" {
+		return map[string]string{}
+	}
+	return v.(map[string]string)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
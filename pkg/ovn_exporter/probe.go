@@ -0,0 +1,262 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/greenpau/ovsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProbeModule describes how the /probe endpoint queries a target.
+type ProbeModule struct {
+	// Database is the database served by the target, either northbound
+	// or southbound.
+	Database string `yaml:"database"`
+	// Peer is the optional remote of the other database. The logical
+	// switch collectors join data from both databases.
+	Peer string `yaml:"peer,omitempty"`
+	// Timeout is the timeout, in seconds, of each step of a probe.
+	Timeout int `yaml:"timeout,omitempty"`
+	// Collectors are the collectors run by a probe. They must support
+	// remote servers.
+	Collectors []string `yaml:"collectors"`
+}
+
+// DefaultProbeModules returns the modules available when none are
+// configured.
+func DefaultProbeModules() map[string]ProbeModule {
+	return map[string]ProbeModule{
+		"northbound": {
+			Database:   "northbound",
+			Collectors: []string{"server_status"},
+		},
+		"southbound": {
+			Database:   "southbound",
+			Collectors: []string{"chassis", "server_status"},
+		},
+	}
+}
+
+// Validate checks the module.
+func (m ProbeModule) Validate() error {
+	switch m.Database {
+	case "northbound", "southbound":
+	default:
+		return fmt.Errorf("unsupported database %q, expected northbound or southbound", m.Database)
+	}
+	if m.Peer != "" {
+		if _, err := parseProbeTarget(m.Peer); err != nil {
+			return fmt.Errorf("invalid peer: %s", err)
+		}
+	}
+	if m.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if len(m.Collectors) == 0 {
+		return fmt.Errorf("no collectors")
+	}
+	if _, err := newRemoteCollectors(m.Collectors); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseProbeTarget returns the remote the OVSDB client dials for a target
+// given as tcp:host:port, host:port or unix:path.
+func parseProbeTarget(target string) (string, error) {
+	switch {
+	case target == "":
+		return "", fmt.Errorf("target is empty")
+	case strings.HasPrefix(target, "unix:"):
+		return target, nil
+	case strings.HasPrefix(target, "ssl:"):
+		return "", fmt.Errorf("ssl targets are not supported")
+	}
+	remote := strings.TrimPrefix(target, "tcp:")
+	if !strings.Contains(remote, ":") {
+		return "", fmt.Errorf("target %q has no port", target)
+	}
+	return remote, nil
+}
+
+// ProbeHandler serves the /probe endpoint. Each request connects to the
+// target, runs the collectors of the requested module and returns their
+// metrics, using the same descriptors as the /metrics endpoint.
+type ProbeHandler struct {
+	mu      sync.RWMutex
+	modules map[string]ProbeModule
+	timeout int
+	logger  log.Logger
+}
+
+// NewProbeHandler returns a handler for the modules. The timeout applies to
+// the modules without a timeout of their own.
+func NewProbeHandler(modules map[string]ProbeModule, timeout int, logger log.Logger) (*ProbeHandler, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	h := &ProbeHandler{logger: logger}
+	if err := h.SetModules(modules, timeout); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetModules replaces the modules of the handler and their default
+// timeout.
+func (h *ProbeHandler) SetModules(modules map[string]ProbeModule, timeout int) error {
+	apply, err := h.PrepareModules(modules, timeout)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// PrepareModules checks the modules, and returns the function replacing
+// the modules of the handler, which cannot fail.
+func (h *ProbeHandler) PrepareModules(modules map[string]ProbeModule, timeout int) (func(), error) {
+	for name, module := range modules {
+		if err := module.Validate(); err != nil {
+			return nil, fmt.Errorf("probe module %s: %s", name, err)
+		}
+	}
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.modules = modules
+		h.timeout = timeout
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	moduleName := r.URL.Query().Get("module")
+	if moduleName == "" {
+		moduleName = "northbound"
+	}
+	h.mu.RLock()
+	module, exists := h.modules[moduleName]
+	timeout := h.timeout
+	h.mu.RUnlock()
+	if !exists {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	target := r.URL.Query().Get("target")
+	remote, err := parseProbeTarget(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metrics := h.probe(r.Context(), target, remote, module, timeout)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&metricsCollector{metrics: metrics})
+	MetricsHandler(registry, h.logger).ServeHTTP(w, r)
+}
+
+// probe runs the collectors of the module against the target. The target
+// is reported as the system ID of its metrics.
+func (h *ProbeHandler) probe(ctx context.Context, target, remote string, module ProbeModule, timeout int) []prometheus.Metric {
+	if module.Timeout > 0 {
+		timeout = module.Timeout
+	}
+	collectors, _ := newRemoteCollectors(module.Collectors)
+	e := &Exporter{
+		timeout:      int64(timeout),
+		logger:       log.With(h.logger, "target", target),
+		collectors:   collectors,
+		databaseLock: make(chan struct{}, 1),
+		lastSuccess:  make(map[string]time.Time),
+	}
+	e.source = &clientSource{e: e}
+	e.Client = ovsdb.NewOvnClient()
+	e.Client.Timeout = timeout
+	e.Client.System.ID = target
+	// An abandoned step may still hold a client, so the clients are
+	// closed in the background.
+	defer func(cli *ovsdb.OvnClient) { go cli.Close() }(e.Client)
+
+	metrics := []prometheus.Metric{}
+	upValue := 1
+	primary, peer := &e.Client.Database.Northbound, &e.Client.Database.Southbound
+	if module.Database == "southbound" {
+		primary, peer = peer, primary
+	}
+	startedAt := time.Now()
+	err := connectProbeDatabase(primary, remote, timeout)
+	if err == nil && module.Peer != "" {
+		peerRemote, _ := parseProbeTarget(module.Peer)
+		err = connectProbeDatabase(peer, peerRemote, timeout)
+	}
+	metrics = append(metrics, e.newScrapeMetrics("connect", time.Since(startedAt), err)...)
+	if err != nil {
+		level.Error(e.logger).Log(
+			"msg", "probe failed to connect",
+			"error", err.Error(),
+		)
+		upValue = 0
+	} else {
+		for _, c := range e.collectors {
+			result := runCollector(ctx, e, c)
+			metrics = append(metrics, result.metrics...)
+			metrics = append(metrics, e.newScrapeMetrics(c.name, result.duration, result.err)...)
+			if result.err != nil && c.critical {
+				upValue = 0
+			}
+		}
+	}
+	metrics = append(metrics, prometheus.MustNewConstMetric(
+		up,
+		prometheus.GaugeValue,
+		float64(upValue),
+	))
+	return metrics
+}
+
+func connectProbeDatabase(db *ovsdb.OvsDatabase, remote string, timeout int) error {
+	db.Socket.Remote = remote
+	client, err := ovsdb.NewClient(remote, timeout)
+	if err != nil {
+		return fmt.Errorf("failed connecting to %s via %s: %s", db.Name, remote, err)
+	}
+	db.Client = &client
+	return nil
+}
+
+// metricsCollector exposes metrics gathered beforehand by a probe.
+type metricsCollector struct {
+	metrics []prometheus.Metric
+}
+
+// Describe implements prometheus.Collector. The collector is unchecked,
+// because the metrics are only known once the collectors have run.
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greenpau/ovn_exporter/pkg/ovntest"
+)
+
+func TestParseProbeTarget(t *testing.T) {
+	testcases := []struct {
+		target    string
+		want      string
+		shouldErr bool
+	}{
+		{target: "tcp:10.0.0.5:6641", want: "10.0.0.5:6641"},
+		{target: "10.0.0.5:6641", want: "10.0.0.5:6641"},
+		{target: "unix:/run/openvswitch/ovnnb_db.sock", want: "unix:/run/openvswitch/ovnnb_db.sock"},
+		{target: "ssl:10.0.0.5:6641", shouldErr: true},
+		{target: "10.0.0.5", shouldErr: true},
+		{target: "", shouldErr: true},
+	}
+	for _, tc := range testcases {
+		got, err := parseProbeTarget(tc.target)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("parseProbeTarget(%q): expected error, but got none", tc.target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseProbeTarget(%q): expected no error, but got %q", tc.target, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseProbeTarget(%q): expected %q, but got %q", tc.target, tc.want, got)
+		}
+	}
+}
+
+func TestNewProbeHandlerValidatesModules(t *testing.T) {
+	modules := map[string]ProbeModule{
+		"local": {Database: "northbound", Collectors: []string{"process"}},
+	}
+	if _, err := NewProbeHandler(modules, 2, nil); err == nil {
+		t.Fatalf("expected error, but got none")
+	}
+}
+
+func TestProbeHandler(t *testing.T) {
+	h, err := NewProbeHandler(DefaultProbeModules(), 1, nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	testcases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "unknown module",
+			query:      "target=tcp:127.0.0.1:6641&module=foo",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing target",
+			query:      "module=northbound",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unreachable target",
+			query:      "target=unix:/nonexistent/ovnsb_db.sock&module=southbound",
+			wantStatus: http.StatusOK,
+			wantBody:   "ovn_up 0",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/probe?"+tc.query, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, but got %d", tc.wantStatus, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Errorf("expected body to contain %q, but got %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProbeHandlerReachableTarget(t *testing.T) {
+	_, southbound, err := ovntest.NewTopology(ovntest.Topology{Chassis: 2})
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	server, err := ovntest.NewServer(filepath.Join(t.TempDir(), "ovnsb_db.sock"), southbound)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	defer server.Close()
+	h, err := NewProbeHandler(DefaultProbeModules(), 2, nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/probe?module=southbound&target="+server.Remote(), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d", http.StatusOK, rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"ovn_up 1",
+		`ovn_scrape_collector_success{collector="chassis"} 1`,
+		`ovn_scrape_collector_success{collector="server_status"} 1`,
+		`ovn_chassis_info{`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %q, but got %q", want, body)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// PushModeRemoteWrite sends the metrics to a Prometheus remote_write
+	// endpoint.
+	PushModeRemoteWrite = "remote_write"
+	// PushModePushgateway sends the metrics to a Prometheus Pushgateway.
+	PushModePushgateway = "pushgateway"
+)
+
+var (
+	pushRequests = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "push", "requests_total"),
+		"The number of requests sending metrics in push mode, by result.",
+		[]string{"mode", "result"}, nil,
+	)
+	pushLastSuccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "push", "last_success_timestamp_seconds"),
+		"The time of the last successful request sending metrics in push mode.",
+		[]string{"mode"}, nil,
+	)
+	pushBuffered = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "push", "buffered_requests"),
+		"The number of failed requests waiting to be retried.",
+		[]string{"mode"}, nil,
+	)
+	pushDropped = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "push", "dropped_requests_total"),
+		"The number of failed requests dropped without being retried.",
+		[]string{"mode"}, nil,
+	)
+)
+
+// PushConfig holds the settings of the push mode. The mode is disabled
+// when empty.
+type PushConfig struct {
+	Mode           string            `yaml:"mode"`
+	URL            string            `yaml:"url"`
+	Interval       time.Duration     `yaml:"interval"`
+	Timeout        time.Duration     `yaml:"timeout"`
+	Job            string            `yaml:"job"`
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
+	BufferSize     int               `yaml:"buffer_size"`
+	BasicAuth      *config.BasicAuth `yaml:"basic_auth,omitempty"`
+	TLSConfig      config.TLSConfig  `yaml:"tls_config,omitempty"`
+}
+
+// Validate checks the push settings.
+func (cfg PushConfig) Validate() []string {
+	errs := []string{}
+	switch cfg.Mode {
+	case "":
+		return errs
+	case PushModeRemoteWrite, PushModePushgateway:
+	default:
+		errs = append(errs, fmt.Sprintf("mode: unsupported mode %q, expected %s or %s", cfg.Mode, PushModeRemoteWrite, PushModePushgateway))
+	}
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Sprintf("url: must be an http or https URL, got %q", cfg.URL))
+	}
+	if cfg.Interval <= 0 {
+		errs = append(errs, fmt.Sprintf("interval: must be positive, got %s", cfg.Interval))
+	}
+	if cfg.Timeout <= 0 {
+		errs = append(errs, fmt.Sprintf("timeout: must be positive, got %s", cfg.Timeout))
+	}
+	if cfg.Mode == PushModePushgateway && cfg.Job == "" {
+		errs = append(errs, "job: must not be empty")
+	}
+	if cfg.BufferSize < 0 {
+		errs = append(errs, fmt.Sprintf("buffer_size: must not be negative, got %d", cfg.BufferSize))
+	}
+	for name := range cfg.ExternalLabels {
+		if !model.LabelName(name).IsValidLegacy() || name == model.MetricNameLabel {
+			errs = append(errs, fmt.Sprintf("external_labels: invalid label name %q", name))
+		}
+	}
+	httpClientConfig := cfg.httpClientConfig()
+	if err := httpClientConfig.Validate(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+func (cfg PushConfig) httpClientConfig() config.HTTPClientConfig {
+	return config.HTTPClientConfig{
+		BasicAuth:       cfg.BasicAuth,
+		TLSConfig:       cfg.TLSConfig,
+		FollowRedirects: true,
+		EnableHTTP2:     true,
+	}
+}
+
+// Pusher sends the gathered metrics to a remote_write endpoint or to a
+// Pushgateway every interval. Up to the buffer size of remote_write
+// requests failing with a retryable error are kept, and retried in order
+// before the next request. The oldest requests are dropped beyond that. The
+// Pushgateway keeps only the latest metrics of a group, so a failed push is
+// superseded by the next one.
+type Pusher struct {
+	gatherer prometheus.Gatherer
+	logger   log.Logger
+
+	// pushMu serializes the pushes and guards the buffer. It is not held
+	// while collecting the self-metrics, which are part of the pushed
+	// metrics.
+	pushMu sync.Mutex
+	buffer [][]byte
+
+	mu          sync.Mutex
+	cfg         PushConfig
+	client      *http.Client
+	success     float64
+	failure     float64
+	dropped     float64
+	buffered    int
+	lastSuccess time.Time
+}
+
+// NewPusher returns a pusher of the metrics of the gatherer.
+func NewPusher(cfg PushConfig, gatherer prometheus.Gatherer, logger log.Logger) (*Pusher, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	p := &Pusher{gatherer: gatherer, logger: logger}
+	if err := p.ApplyConfig(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ApplyConfig applies the push settings. The buffered requests are
+// dropped when the destination changes.
+func (p *Pusher) ApplyConfig(cfg PushConfig) error {
+	apply, err := p.PrepareConfig(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// PrepareConfig builds the client of the push settings, and returns the
+// function applying them, which cannot fail.
+func (p *Pusher) PrepareConfig(cfg PushConfig) (func(), error) {
+	var client *http.Client
+	if cfg.Mode != "" {
+		var err error
+		client, err = config.NewClientFromConfig(cfg.httpClientConfig(), GetExporterName())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func() {
+		p.pushMu.Lock()
+		defer p.pushMu.Unlock()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if cfg.Mode != p.cfg.Mode || cfg.URL != p.cfg.URL {
+			p.buffer = nil
+			p.buffered = 0
+		}
+		p.cfg = cfg
+		p.client = client
+	}, nil
+}
+
+// Run pushes the metrics every interval until the context is cancelled.
+func (p *Pusher) Run(ctx context.Context) {
+	for {
+		p.mu.Lock()
+		interval := p.cfg.Interval
+		p.mu.Unlock()
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		p.Push(ctx)
+	}
+}
+
+// Push sends the metrics once. It does nothing when the push mode is
+// disabled.
+func (p *Pusher) Push(ctx context.Context) error {
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+	p.mu.Lock()
+	cfg, client := p.cfg, p.client
+	p.mu.Unlock()
+	var err error
+	switch cfg.Mode {
+	case PushModeRemoteWrite:
+		err = p.pushRemoteWrite(ctx, cfg, client)
+	case PushModePushgateway:
+		err = p.pushPushgateway(ctx, cfg, client)
+	default:
+		return nil
+	}
+	if err != nil {
+		level.Error(p.logger).Log(
+			"msg", "failed pushing metrics",
+			"mode", cfg.Mode,
+			"url", cfg.URL,
+			"error", err.Error(),
+		)
+	}
+	return err
+}
+
+// recordResult updates the self-metrics after a request.
+func (p *Pusher) recordResult(err error, dropped int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.failure++
+	} else {
+		p.success++
+		p.lastSuccess = time.Now()
+	}
+	p.dropped += float64(dropped)
+	p.buffered = len(p.buffer)
+}
+
+func (p *Pusher) pushPushgateway(ctx context.Context, cfg PushConfig, client *http.Client) error {
+	pusher := push.New(cfg.URL, cfg.Job).
+		Gatherer(withoutTimestamps{p.gatherer}).
+		Client(client)
+	for _, name := range sortedKeys(cfg.ExternalLabels) {
+		pusher = pusher.Grouping(name, cfg.ExternalLabels[name])
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	err := pusher.PushContext(ctx)
+	p.recordResult(err, 0)
+	return err
+}
+
+func (p *Pusher) pushRemoteWrite(ctx context.Context, cfg PushConfig, client *http.Client) error {
+	mfs, err := p.gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		p.recordResult(err, 0)
+		return err
+	}
+	p.buffer = append(p.buffer, snappy.Encode(nil, encodeWriteRequest(mfs, remoteWriteLabels(cfg), time.Now())))
+	for len(p.buffer) > 0 {
+		retry, err := sendRemoteWrite(ctx, cfg, client, p.buffer[0])
+		if err != nil {
+			dropped := 0
+			if !retry {
+				// The request was rejected, and would be again.
+				p.buffer = p.buffer[1:]
+				dropped++
+			}
+			if len(p.buffer) > cfg.BufferSize {
+				dropped += len(p.buffer) - cfg.BufferSize
+				p.buffer = p.buffer[len(p.buffer)-cfg.BufferSize:]
+			}
+			p.recordResult(err, dropped)
+			return err
+		}
+		p.buffer = p.buffer[1:]
+		p.recordResult(nil, 0)
+	}
+	return nil
+}
+
+// remoteWriteLabels returns the labels added to the remote_write samples.
+// These are the job and, as Prometheus would add when scraping, the
+// instance, which is the hostname. The external labels override them.
+func remoteWriteLabels(cfg PushConfig) map[string]string {
+	labels := map[string]string{}
+	if cfg.Job != "" {
+		labels[model.JobLabel] = cfg.Job
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		labels[model.InstanceLabel] = hostname
+	}
+	for name, value := range cfg.ExternalLabels {
+		labels[name] = value
+	}
+	return labels
+}
+
+// sendRemoteWrite sends a remote_write request. The request is retryable
+// when it failed on the network, or with a server error or a rate limit.
+func sendRemoteWrite(ctx context.Context, cfg PushConfig, client *http.Client, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", GetExporterName()+"/"+GetVersion())
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// Describe implements prometheus.Collector.
+func (p *Pusher) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pushRequests
+	ch <- pushLastSuccess
+	ch <- pushBuffered
+	ch <- pushDropped
+}
+
+// Collect implements prometheus.Collector. The pusher reports nothing while
+// the push mode is disabled.
+func (p *Pusher) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mode := p.cfg.Mode
+	if mode == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(pushRequests, prometheus.CounterValue, p.success, mode, "success")
+	ch <- prometheus.MustNewConstMetric(pushRequests, prometheus.CounterValue, p.failure, mode, "failure")
+	var lastSuccess float64
+	if !p.lastSuccess.IsZero() {
+		lastSuccess = float64(p.lastSuccess.UnixNano()) / 1e9
+	}
+	ch <- prometheus.MustNewConstMetric(pushLastSuccess, prometheus.GaugeValue, lastSuccess, mode)
+	ch <- prometheus.MustNewConstMetric(pushBuffered, prometheus.GaugeValue, float64(p.buffered), mode)
+	ch <- prometheus.MustNewConstMetric(pushDropped, prometheus.CounterValue, p.dropped, mode)
+}
+
+// withoutTimestamps strips the timestamps of the samples, which the
+// Pushgateway rejects.
+type withoutTimestamps struct {
+	prometheus.Gatherer
+}
+
+func (g withoutTimestamps) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			m.TimestampMs = nil
+		}
+	}
+	return mfs, err
+}
+
+// timeSeries is a sample of the remote_write protocol.
+type timeSeries struct {
+	labels    []*dto.LabelPair
+	value     float64
+	timestamp int64
+}
+
+// encodeWriteRequest encodes the metric families as a remote_write
+// WriteRequest protobuf message. The samples without a timestamp get the
+// time of the request. The external labels do not override the labels of
+// the samples.
+func encodeWriteRequest(mfs []*dto.MetricFamily, externalLabels map[string]string, now time.Time) []byte {
+	var b []byte
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			timestamp := now.UnixMilli()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			for _, ts := range metricSeries(mf, m) {
+				ts.timestamp = timestamp
+				b = appendTimeSeries(b, ts, externalLabels)
+			}
+		}
+	}
+	return b
+}
+
+// metricSeries returns the series of a metric, with the metric name in the
+// __name__ label.
+func metricSeries(mf *dto.MetricFamily, m *dto.Metric) []timeSeries {
+	series := func(suffix string, value float64, extra ...*dto.LabelPair) timeSeries {
+		name := model.MetricNameLabel
+		metricName := mf.GetName() + suffix
+		labels := []*dto.LabelPair{{Name: &name, Value: &metricName}}
+		labels = append(labels, m.Label...)
+		labels = append(labels, extra...)
+		return timeSeries{labels: labels, value: value}
+	}
+	label := func(name, value string) *dto.LabelPair {
+		return &dto.LabelPair{Name: &name, Value: &value}
+	}
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		return []timeSeries{series("", m.GetCounter().GetValue())}
+	case dto.MetricType_GAUGE:
+		return []timeSeries{series("", m.GetGauge().GetValue())}
+	case dto.MetricType_UNTYPED:
+		return []timeSeries{series("", m.GetUntyped().GetValue())}
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		out := []timeSeries{}
+		for _, q := range s.Quantile {
+			out = append(out, series("", q.GetValue(), label(model.QuantileLabel, formatFloat(q.GetQuantile()))))
+		}
+		out = append(out, series("_sum", s.GetSampleSum()))
+		out = append(out, series("_count", float64(s.GetSampleCount())))
+		return out
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		h := m.GetHistogram()
+		out := []timeSeries{}
+		infSeen := false
+		for _, bucket := range h.Bucket {
+			if math.IsInf(bucket.GetUpperBound(), +1) {
+				infSeen = true
+			}
+			out = append(out, series("_bucket", float64(bucket.GetCumulativeCount()), label(model.BucketLabel, formatFloat(bucket.GetUpperBound()))))
+		}
+		if !infSeen {
+			out = append(out, series("_bucket", float64(h.GetSampleCount()), label(model.BucketLabel, "+Inf")))
+		}
+		out = append(out, series("_sum", h.GetSampleSum()))
+		out = append(out, series("_count", float64(h.GetSampleCount())))
+		return out
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// appendTimeSeries appends a TimeSeries message, as the field 1 of the
+// WriteRequest message. The labels are sorted by name, as required by the
+// protocol.
+func appendTimeSeries(b []byte, ts timeSeries, externalLabels map[string]string) []byte {
+	labels := make(map[string]string, len(ts.labels)+len(externalLabels))
+	for name, value := range externalLabels {
+		labels[name] = value
+	}
+	for _, l := range ts.labels {
+		labels[l.GetName()] = l.GetValue()
+	}
+	var msg []byte
+	for _, name := range sortedKeys(labels) {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, label)
+	}
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(ts.value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(ts.timestamp))
+	msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, sample)
+
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
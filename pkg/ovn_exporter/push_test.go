@@ -0,0 +1,354 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes the series of a remote_write request into
+// their labels and value.
+func decodeWriteRequest(t *testing.T, b []byte) []map[string]string {
+	t.Helper()
+	series := []map[string]string{}
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("malformed message: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			n = fn(num, typ, b)
+			if n < 0 {
+				t.Fatalf("malformed field: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+	fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		ts, n := protowire.ConsumeBytes(b)
+		labels := map[string]string{}
+		fields(ts, func(num protowire.Number, typ protowire.Type, b []byte) int {
+			msg, n := protowire.ConsumeBytes(b)
+			switch num {
+			case 1:
+				var name, value string
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					s, n := protowire.ConsumeString(b)
+					if num == 1 {
+						name = s
+					} else {
+						value = s
+					}
+					return n
+				})
+				labels[name] = value
+			case 2:
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if num == 1 {
+						v, n := protowire.ConsumeFixed64(b)
+						labels["__value__"] = formatFloat(math.Float64frombits(v))
+						return n
+					}
+					_, n := protowire.ConsumeVarint(b)
+					return n
+				})
+			}
+			return n
+		})
+		series = append(series, labels)
+		return n
+	})
+	return series
+}
+
+func newTestPushRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "ovn_up", Help: "Up."})
+	gauge.Set(1)
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "ovn_duration", Help: "Duration.", Objectives: map[float64]float64{0.5: 0.05}})
+	summary.Observe(2)
+	registry.MustRegister(gauge, summary)
+	return registry
+}
+
+func newTestPushConfig(mode, url string) PushConfig {
+	cfg := DefaultConfig().Push
+	cfg.Mode = mode
+	cfg.URL = url
+	cfg.ExternalLabels = map[string]string{"site": "edge"}
+	return cfg
+}
+
+func TestPusherRemoteWrite(t *testing.T) {
+	var mu sync.Mutex
+	var series []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if user, password, _ := r.BasicAuth(); user != "ovn" || password != "secret" {
+			t.Errorf("expected basic auth, but got %q:%q", user, password)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("expected snappy body, but got %q", err)
+		}
+		mu.Lock()
+		series = decodeWriteRequest(t, b)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := newTestPushConfig(PushModeRemoteWrite, server.URL)
+	cfg.BasicAuth = &config.BasicAuth{Username: "ovn", Password: "secret"}
+	p, err := NewPusher(cfg, newTestPushRegistry(), nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	hostname, _ := os.Hostname()
+	common := `instance="` + hostname + `",job="` + GetExporterName() + `"`
+	want := map[string]bool{
+		`ovn_up{` + common + `,site="edge"} 1`:                      false,
+		`ovn_duration{` + common + `,quantile="0.5",site="edge"} 2`: false,
+		`ovn_duration_sum{` + common + `,site="edge"} 2`:            false,
+		`ovn_duration_count{` + common + `,site="edge"} 1`:          false,
+	}
+	for _, labels := range series {
+		pairs := []string{}
+		for _, name := range sortedKeys(labels) {
+			if name != "__name__" && name != "__value__" {
+				pairs = append(pairs, name+`="`+labels[name]+`"`)
+			}
+		}
+		want[labels["__name__"]+"{"+strings.Join(pairs, ",")+"} "+labels["__value__"]] = true
+	}
+	for s, found := range want {
+		if !found {
+			t.Errorf("expected series %s, but got %v", s, series)
+		}
+	}
+}
+
+func TestRemoteWriteLabels(t *testing.T) {
+	hostname, _ := os.Hostname()
+	for _, test := range []struct {
+		name           string
+		job            string
+		externalLabels map[string]string
+		want           map[string]string
+	}{
+		{
+			name: "default",
+			job:  "ovn-exporter",
+			want: map[string]string{"job": "ovn-exporter", "instance": hostname},
+		},
+		{
+			name:           "external labels",
+			job:            "ovn-exporter",
+			externalLabels: map[string]string{"site": "edge"},
+			want:           map[string]string{"job": "ovn-exporter", "instance": hostname, "site": "edge"},
+		},
+		{
+			name:           "override",
+			job:            "ovn-exporter",
+			externalLabels: map[string]string{"job": "ovn", "instance": "chassis-1"},
+			want:           map[string]string{"job": "ovn", "instance": "chassis-1"},
+		},
+		{
+			name: "no job",
+			want: map[string]string{"instance": hostname},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultConfig().Push
+			cfg.Job = test.job
+			cfg.ExternalLabels = test.externalLabels
+			got := remoteWriteLabels(cfg)
+			if len(got) != len(test.want) {
+				t.Fatalf("expected labels %v, but got %v", test.want, got)
+			}
+			for name, value := range test.want {
+				if got[name] != value {
+					t.Errorf("expected label %s=%q, but got %q", name, value, got[name])
+				}
+			}
+		})
+	}
+}
+
+func TestPusherRemoteWriteRetry(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	setStatus := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = code
+		requests = 0
+	}
+
+	cfg := newTestPushConfig(PushModeRemoteWrite, server.URL)
+	cfg.BufferSize = 2
+	p, err := NewPusher(cfg, newTestPushRegistry(), nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := p.Push(context.Background()); err == nil {
+			t.Fatalf("expected error, but got none")
+		}
+	}
+	if p.buffered != 2 || p.dropped != 2 {
+		t.Errorf("expected 2 buffered and 2 dropped requests, but got %d and %v", p.buffered, p.dropped)
+	}
+
+	setStatus(http.StatusNoContent)
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if requests != 3 || p.buffered != 0 {
+		t.Errorf("expected 3 requests emptying the buffer, but got %d requests and %d buffered", requests, p.buffered)
+	}
+
+	// A rejected request is not retried.
+	setStatus(http.StatusBadRequest)
+	p.Push(context.Background())
+	if p.buffered != 0 || p.dropped != 3 {
+		t.Errorf("expected rejected request to be dropped, but got %d buffered and %v dropped", p.buffered, p.dropped)
+	}
+	if p.success != 3 || p.failure != 5 {
+		t.Errorf("expected 3 successes and 5 failures, but got %v and %v", p.success, p.failure)
+	}
+}
+
+func TestPusherPushgateway(t *testing.T) {
+	var mu sync.Mutex
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		method, path, body = r.Method, r.URL.Path, string(b)
+	}))
+	defer server.Close()
+
+	registry := newTestPushRegistry()
+	ts := prometheus.NewDesc("ovn_stamped", "Stamped.", nil, nil)
+	registry.MustRegister(stampedCollector{ts})
+	p, err := NewPusher(newTestPushConfig(PushModePushgateway, server.URL), registry, nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if method != http.MethodPut || path != "/metrics/job/ovn-exporter/site/edge" {
+		t.Errorf("unexpected request %s %s", method, path)
+	}
+	if !strings.Contains(body, "ovn_up") || !strings.Contains(body, "ovn_stamped") {
+		t.Errorf("expected metrics in body, but got %q", body)
+	}
+}
+
+// stampedCollector reports a sample with a timestamp, which the
+// Pushgateway would reject.
+type stampedCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c stampedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c stampedCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewMetricWithTimestamp(time.Unix(1000, 0), prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1))
+}
+
+func TestPushConfigValidate(t *testing.T) {
+	testcases := []struct {
+		name   string
+		modify func(cfg *PushConfig)
+		errors int
+	}{
+		{name: "disabled", modify: func(cfg *PushConfig) { cfg.Mode = "" }},
+		{name: "remote write", modify: func(cfg *PushConfig) {}},
+		{name: "unsupported mode", modify: func(cfg *PushConfig) { cfg.Mode = "graphite" }, errors: 1},
+		{name: "missing url", modify: func(cfg *PushConfig) { cfg.URL = "" }, errors: 1},
+		{name: "invalid label", modify: func(cfg *PushConfig) { cfg.ExternalLabels["0site"] = "edge" }, errors: 1},
+		{name: "invalid interval", modify: func(cfg *PushConfig) { cfg.Interval = 0 }, errors: 1},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestPushConfig(PushModeRemoteWrite, "http://localhost:9090/api/v1/write")
+			tc.modify(&cfg)
+			if errs := cfg.Validate(); len(errs) != tc.errors {
+				t.Errorf("expected %d errors, but got %v", tc.errors, errs)
+			}
+		})
+	}
+}
+
+func TestPusherPrepareConfig(t *testing.T) {
+	p, err := NewPusher(newTestPushConfig(PushModeRemoteWrite, "http://localhost:9090/api/v1/write"), newTestPushRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestPushConfig(PushModeRemoteWrite, "http://localhost:9090/api/v1/write")
+	cfg.TLSConfig.CAFile = "/nonexistent/ca.pem"
+	if _, err := p.PrepareConfig(cfg); err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+	apply, err := p.PrepareConfig(newTestPushConfig(PushModePushgateway, "http://localhost:9091"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cfg.Mode != PushModeRemoteWrite {
+		t.Fatalf("expected the settings to be applied by the returned function only, got mode %q", p.cfg.Mode)
+	}
+	apply()
+	if p.cfg.Mode != PushModePushgateway {
+		t.Fatalf("expected mode %q, got %q", PushModePushgateway, p.cfg.Mode)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scheduleSlack lets a collector run slightly before its interval has
+// passed, so that a tick firing early does not delay it by a whole tick.
+const scheduleSlack = 500 * time.Millisecond
+
+// section is the outcome of the last run of a collector, or of the system
+// information step. The collections keep a section per collector and serve
+// it until the collector runs again. When a run fails, the metrics of the
+// last successful run are served for the cache lifetime of the collector,
+// and the scrape metrics report the failure.
+type section struct {
+	name string
+	// metrics were collected at collectedAt, by the last successful run
+	// or by the last run.
+	metrics     []prometheus.Metric
+	collectedAt time.Time
+	// scrape are the scrape metrics of the last run, started at ranAt.
+	scrape      []prometheus.Metric
+	ranAt       time.Time
+	err         error
+	succeededAt time.Time
+}
+
+// storeSection records the result of a run of a collector started at
+// startedAt. The caller holds the exporter's lock.
+func (e *Exporter) storeSection(name string, result collectorResult, startedAt time.Time, cacheTTL time.Duration) {
+	s := e.sections[name]
+	s.name = name
+	s.scrape = e.newScrapeMetrics(name, result.duration, result.err)
+	s.ranAt = startedAt
+	s.err = result.err
+	stale := s.succeededAt.IsZero() || startedAt.Sub(s.succeededAt) > cacheTTL
+	if result.err == nil || stale {
+		s.metrics = result.metrics
+		s.collectedAt = startedAt
+	}
+	if result.err == nil {
+		s.succeededAt = startedAt
+	}
+	e.sections[name] = s
+}
+
+// isDue returns true when the section has not run within the interval. The
+// caller holds the exporter's lock.
+func (e *Exporter) isDue(name string, interval time.Duration, now time.Time) bool {
+	s, exists := e.sections[name]
+	return !exists || now.Sub(s.ranAt) >= interval-scheduleSlack
+}
+
+// nextRun returns the time at which the next collector is due. The caller
+// holds the exporter's lock.
+func (e *Exporter) nextRun() time.Time {
+	var next time.Time
+	due := func(name string, interval time.Duration) {
+		s, exists := e.sections[name]
+		if !exists {
+			return
+		}
+		if t := s.ranAt.Add(interval); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	due("system_info", e.getPollInterval())
+	for _, c := range e.collectors {
+		due(c.name, e.getCollectorInterval(c))
+	}
+	return next
+}
+
+// getCollectorInterval returns the interval between the runs of a
+// collector. It defaults to the poll interval.
+func (e *Exporter) getCollectorInterval(c namedCollector) time.Duration {
+	if c.interval > 0 {
+		return c.interval
+	}
+	return e.getPollInterval()
+}
+
+// getTickInterval returns the interval between the collections run in the
+// background. It is the greatest common divisor of the poll interval and of
+// the intervals of the collectors, so that every collector runs on time.
+func (e *Exporter) getTickInterval() time.Duration {
+	e.RLock()
+	defer e.RUnlock()
+	tick := e.getPollInterval()
+	for _, c := range e.collectors {
+		tick = gcd(tick, e.getCollectorInterval(c))
+	}
+	if tick < time.Second {
+		return time.Second
+	}
+	return tick
+}
+
+func gcd(a, b time.Duration) time.Duration {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+)
+
+func countSeries(series []string, name string) int {
+	n := 0
+	for _, s := range series {
+		if strings.HasPrefix(s, name+"{") {
+			n++
+		}
+	}
+	return n
+}
+
+func sortedCopy(s []string) []string {
+	c := append([]string{}, s...)
+	sort.Strings(c)
+	return c
+}
+
+// ageSections moves the runs of the cached sections back in time.
+func ageSections(e *Exporter, d time.Duration) {
+	for name, s := range e.sections {
+		s.ranAt = s.ranAt.Add(-d)
+		s.collectedAt = s.collectedAt.Add(-d)
+		if !s.succeededAt.IsZero() {
+			s.succeededAt = s.succeededAt.Add(-d)
+		}
+		e.sections[name] = s
+	}
+}
+
+// cacheAge returns the value of the cache age metric of a collector.
+func cacheAge(t *testing.T, series []string, collector string) float64 {
+	t.Helper()
+	prefix := `ovn_scrape_collector_cache_age_seconds{collector="` + collector + `"} `
+	for _, s := range series {
+		if strings.HasPrefix(s, prefix) {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(s, prefix), 64)
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("expected the cache age of %s, but got %v", collector, series)
+	return 0
+}
+
+func newScheduleTestExporter(t *testing.T, src *FakeDataSource, collectors map[string]CollectorConfig) *Exporter {
+	t.Helper()
+	e := newFakeExporter(t, src)
+	cfg := DefaultConfig()
+	for name := range cfg.Collectors {
+		enabled := false
+		cfg.Collectors[name] = CollectorConfig{Enabled: &enabled}
+	}
+	for name, c := range collectors {
+		enabled := true
+		c.Enabled = &enabled
+		cfg.Collectors[name] = c
+	}
+	if err := e.ApplyConfig(cfg); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	return e
+}
+
+func TestGatherMetricsRunsCollectorsDue(t *testing.T) {
+	src := &FakeDataSource{
+		System:          SystemInfo{ID: "host-1"},
+		Chassis:         []*ovsdb.OvnChassis{{UUID: "c1", Name: "compute-1", IPAddress: net.ParseIP("192.0.2.1")}},
+		LogicalSwitches: []*ovsdb.OvnLogicalSwitch{{UUID: "s1", Name: "net-1"}},
+	}
+	e := newScheduleTestExporter(t, src, map[string]CollectorConfig{
+		"chassis":        {Interval: 120},
+		"logical_switch": {Interval: 5},
+	})
+	if tick := e.getTickInterval(); tick != 5*time.Second {
+		t.Errorf("expected a tick of 5s, but got %s", tick)
+	}
+	e.gatherMetrics(context.Background(), false)
+	ageSections(e, time.Minute)
+	src.Chassis = append(src.Chassis, &ovsdb.OvnChassis{UUID: "c2", Name: "compute-2", IPAddress: net.ParseIP("192.0.2.2")})
+	src.LogicalSwitches = append(src.LogicalSwitches, &ovsdb.OvnLogicalSwitch{UUID: "s2", Name: "net-2"})
+
+	e.gatherMetrics(context.Background(), false)
+	series := collectSeries(t, e, "ovn_chassis_info", "ovn_logical_switch_info", "ovn_scrape_collector_cache_age_seconds")
+	if got := countSeries(series, "ovn_chassis_info"); got != 1 {
+		t.Errorf("expected the cached chassis, but got %d series", got)
+	}
+	if got := countSeries(series, "ovn_logical_switch_info"); got != 2 {
+		t.Errorf("expected the switches of the last run, but got %d series", got)
+	}
+	if age := cacheAge(t, series, "chassis"); age < 60 {
+		t.Errorf("expected the chassis to be at least 60s old, but got %v", age)
+	}
+	if age := cacheAge(t, series, "logical_switch"); age >= 60 {
+		t.Errorf("expected fresh switches, but got an age of %v", age)
+	}
+	// The system information runs every poll interval, which has passed.
+	if age := cacheAge(t, series, "system_info"); age >= 60 {
+		t.Errorf("expected fresh system information, but got an age of %v", age)
+	}
+
+	// Nothing is due, so the snapshot is kept.
+	snapshot := e.snapshot.Load()
+	e.gatherMetrics(context.Background(), false)
+	if e.snapshot.Load() != snapshot {
+		t.Errorf("expected no collection while no collector is due")
+	}
+
+	// GatherMetrics runs every collector.
+	e.GatherMetrics()
+	series = collectSeries(t, e, "ovn_chassis_info")
+	if got := countSeries(series, "ovn_chassis_info"); got != 2 {
+		t.Errorf("expected the chassis of the last run, but got %d series", got)
+	}
+}
+
+func TestGatherMetricsCacheTTL(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cacheTTL int
+		want     []string
+	}{
+		{
+			name:     "failed run within the cache lifetime",
+			cacheTTL: 300,
+			want: []string{
+				`ovn_chassis_info{ip="192.0.2.1",name="compute-1",system_id="host-1",uuid="c1"} 0`,
+				`ovn_scrape_collector_success{collector="chassis"} 0`,
+				`ovn_up{} 0`,
+			},
+		},
+		{
+			name: "failed run without cache lifetime",
+			want: []string{
+				`ovn_scrape_collector_success{collector="chassis"} 0`,
+				`ovn_up{} 0`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &FakeDataSource{
+				System:  SystemInfo{ID: "host-1"},
+				Chassis: []*ovsdb.OvnChassis{{UUID: "c1", Name: "compute-1", IPAddress: net.ParseIP("192.0.2.1")}},
+			}
+			e := newScheduleTestExporter(t, src, map[string]CollectorConfig{
+				"chassis": {CacheTTL: tc.cacheTTL},
+			})
+			e.GatherMetrics()
+			ageSections(e, time.Minute)
+			src.Errors = map[string]error{"GetChassis": errors.New("connection refused")}
+			e.GatherMetrics()
+			got := collectSeries(t, e, "ovn_chassis_info", "ovn_scrape_collector_success", "ovn_up")
+			want := append([]string{`ovn_scrape_collector_success{collector="system_info"} 1`}, tc.want...)
+			if !reflect.DeepEqual(got, sortedCopy(want)) {
+				t.Errorf("expected %v, but got %v", sortedCopy(want), got)
+			}
+		})
+	}
+}
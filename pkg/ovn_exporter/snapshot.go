@@ -0,0 +1,188 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RawEntry is the data returned by a single call to OVN.
+type RawEntry struct {
+	FetchedAt time.Time   `json:"fetched_at"`
+	Error     string      `json:"error,omitempty"`
+	Data      interface{} `json:"data"`
+}
+
+// RawSnapshot holds the data returned by the calls to OVN in a collection,
+// before it is turned into metrics. The per-component entries are keyed by
+// the component name.
+type RawSnapshot struct {
+	CollectedAt        time.Time           `json:"collected_at"`
+	Chassis            *RawEntry           `json:"chassis,omitempty"`
+	LogicalSwitches    *RawEntry           `json:"logical_switches,omitempty"`
+	LogicalSwitchPorts *RawEntry           `json:"logical_switch_ports,omitempty"`
+	LogicalRouters     *RawEntry           `json:"logical_routers,omitempty"`
+	LogicalRouterPorts *RawEntry           `json:"logical_router_ports,omitempty"`
+	StaticRoutes       *RawEntry           `json:"logical_router_static_routes,omitempty"`
+	RouterPolicies     *RawEntry           `json:"logical_router_policies,omitempty"`
+	NAT                *RawEntry           `json:"logical_router_nat,omitempty"`
+	LoadBalancers      *RawEntry           `json:"load_balancers,omitempty"`
+	ServiceMonitors    *RawEntry           `json:"service_monitors,omitempty"`
+	Coverage           map[string]RawEntry `json:"coverage"`
+	Memory             map[string]RawEntry `json:"memory"`
+	Cluster            map[string]RawEntry `json:"cluster"`
+}
+
+// rawRecorder records the data of a collection. A nil recorder discards
+// the data, so that the collectors record unconditionally.
+type rawRecorder struct {
+	mu       sync.Mutex
+	snapshot RawSnapshot
+}
+
+// newRawRecorder returns a recorder starting with the entries of the
+// previous snapshot, if any, since a collection only runs the collectors
+// due.
+func newRawRecorder(collectedAt time.Time, previous *RawSnapshot) *rawRecorder {
+	r := &rawRecorder{
+		snapshot: RawSnapshot{
+			CollectedAt: collectedAt,
+			Coverage:    make(map[string]RawEntry),
+			Memory:      make(map[string]RawEntry),
+			Cluster:     make(map[string]RawEntry),
+		},
+	}
+	if previous == nil {
+		return r
+	}
+	r.snapshot.Chassis = previous.Chassis
+	r.snapshot.LogicalSwitches = previous.LogicalSwitches
+	r.snapshot.LogicalSwitchPorts = previous.LogicalSwitchPorts
+	r.snapshot.LogicalRouters = previous.LogicalRouters
+	r.snapshot.LogicalRouterPorts = previous.LogicalRouterPorts
+	r.snapshot.StaticRoutes = previous.StaticRoutes
+	r.snapshot.RouterPolicies = previous.RouterPolicies
+	r.snapshot.NAT = previous.NAT
+	r.snapshot.LoadBalancers = previous.LoadBalancers
+	r.snapshot.ServiceMonitors = previous.ServiceMonitors
+	for component, entry := range previous.Coverage {
+		r.snapshot.Coverage[component] = entry
+	}
+	for component, entry := range previous.Memory {
+		r.snapshot.Memory[component] = entry
+	}
+	for component, entry := range previous.Cluster {
+		r.snapshot.Cluster[component] = entry
+	}
+	return r
+}
+
+func newRawEntry(data interface{}, err error) RawEntry {
+	entry := RawEntry{FetchedAt: time.Now(), Data: data}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// record records the data returned by a call to the databases. The data
+// must not be passed when the call failed, because a call abandoned after a
+// timeout may still be writing it.
+func (r *rawRecorder) record(kind string, data interface{}, err error) {
+	if r == nil {
+		return
+	}
+	entry := newRawEntry(data, err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch kind {
+	case "chassis":
+		r.snapshot.Chassis = &entry
+	case "logical_switches":
+		r.snapshot.LogicalSwitches = &entry
+	case "logical_switch_ports":
+		r.snapshot.LogicalSwitchPorts = &entry
+	case "logical_routers":
+		r.snapshot.LogicalRouters = &entry
+	case "logical_router_ports":
+		r.snapshot.LogicalRouterPorts = &entry
+	case "logical_router_static_routes":
+		r.snapshot.StaticRoutes = &entry
+	case "logical_router_policies":
+		r.snapshot.RouterPolicies = &entry
+	case "logical_router_nat":
+		r.snapshot.NAT = &entry
+	case "load_balancers":
+		r.snapshot.LoadBalancers = &entry
+	case "service_monitors":
+		r.snapshot.ServiceMonitors = &entry
+	}
+}
+
+// recordComponent records the data returned by a call to the control
+// socket of a component.
+func (r *rawRecorder) recordComponent(kind, component string, data interface{}, err error) {
+	if r == nil {
+		return
+	}
+	entry := newRawEntry(data, err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch kind {
+	case "coverage":
+		r.snapshot.Coverage[component] = entry
+	case "memory":
+		r.snapshot.Memory[component] = entry
+	case "cluster":
+		r.snapshot.Cluster[component] = entry
+	}
+}
+
+// SetDebugSnapshot enables recording the data of each collection for the
+// debug snapshot endpoint. Recording is off by default, because the data
+// includes every logical switch port.
+func (e *Exporter) SetDebugSnapshot(enabled bool) {
+	e.debugSnapshot.Store(enabled)
+	if !enabled {
+		e.rawSnapshot.Store(nil)
+	}
+}
+
+// GetRawSnapshot returns the data of the last collection, or nil when
+// recording is disabled or no collection has completed since enabling it.
+func (e *Exporter) GetRawSnapshot() *RawSnapshot {
+	return e.rawSnapshot.Load()
+}
+
+// SnapshotHandler serves the data of the last collection as JSON. It
+// responds with 404 while recording is disabled.
+func (e *Exporter) SnapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !e.debugSnapshot.Load() {
+			http.Error(w, "debug snapshot is disabled", http.StatusNotFound)
+			return
+		}
+		snapshot := e.GetRawSnapshot()
+		if snapshot == nil {
+			http.Error(w, "no collection has completed yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshot)
+	})
+}
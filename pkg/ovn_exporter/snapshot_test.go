@@ -0,0 +1,83 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRawRecorder(t *testing.T) {
+	var disabled *rawRecorder
+	disabled.record("chassis", nil, errors.New("failed"))
+
+	r := newRawRecorder(time.Now(), nil)
+	r.record("chassis", nil, errors.New("failed"))
+	r.record("logical_switches", []string{"ls0"}, nil)
+	r.recordComponent("memory", "ovsdb-server", map[string]float64{"cells": 1}, nil)
+	if r.snapshot.Chassis == nil || r.snapshot.Chassis.Error != "failed" {
+		t.Errorf("expected chassis error, but got %+v", r.snapshot.Chassis)
+	}
+	if r.snapshot.LogicalSwitches == nil || r.snapshot.LogicalSwitches.FetchedAt.IsZero() {
+		t.Errorf("expected logical switches fetch time, but got %+v", r.snapshot.LogicalSwitches)
+	}
+	if r.snapshot.LogicalSwitchPorts != nil {
+		t.Errorf("expected no logical switch ports, but got %+v", r.snapshot.LogicalSwitchPorts)
+	}
+	if _, ok := r.snapshot.Memory["ovsdb-server"]; !ok {
+		t.Errorf("expected memory of ovsdb-server, but got %v", r.snapshot.Memory)
+	}
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	e := newTestExporter(t)
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.SnapshotHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/snapshot", nil))
+		return rec
+	}
+
+	e.GatherMetrics()
+	if rec := get(); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d while disabled, but got %d", http.StatusNotFound, rec.Code)
+	}
+
+	e.SetDebugSnapshot(true)
+	if rec := get(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d before a collection, but got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	e.GatherMetrics()
+	rec := get()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d", http.StatusOK, rec.Code)
+	}
+	var s RawSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("expected JSON body, but got %q", rec.Body.String())
+	}
+	if s.CollectedAt.IsZero() {
+		t.Errorf("expected collection time, but got none")
+	}
+
+	e.SetDebugSnapshot(false)
+	if rec := get(); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after disabling, but got %d", http.StatusNotFound, rec.Code)
+	}
+}
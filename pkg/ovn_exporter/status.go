@@ -0,0 +1,319 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+	"gopkg.in/yaml.v2"
+)
+
+// maxRecentErrors is the number of failed steps kept for the status page.
+const maxRecentErrors = 20
+
+// StepError is a failed call to OVN.
+type StepError struct {
+	Time      time.Time `json:"time"`
+	Step      string    `json:"step"`
+	Component string    `json:"component,omitempty"`
+	Error     string    `json:"error"`
+}
+
+// recentErrors keeps the most recent failed steps. The zero value is ready
+// to use.
+type recentErrors struct {
+	mu     sync.Mutex
+	errors []StepError
+}
+
+func (r *recentErrors) add(step, component string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, StepError{
+		Time:      time.Now(),
+		Step:      step,
+		Component: component,
+		Error:     err.Error(),
+	})
+	if len(r.errors) > maxRecentErrors {
+		r.errors = r.errors[len(r.errors)-maxRecentErrors:]
+	}
+}
+
+// list returns the failed steps, most recent first.
+func (r *recentErrors) list() []StepError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errors := make([]StepError, len(r.errors))
+	for i, err := range r.errors {
+		errors[len(r.errors)-1-i] = err
+	}
+	return errors
+}
+
+// SystemStatus describes the host and the Open vSwitch installation.
+type SystemStatus struct {
+	ID         string `json:"id"`
+	Hostname   string `json:"hostname"`
+	RunDir     string `json:"run_dir"`
+	Type       string `json:"type"`
+	Version    string `json:"version"`
+	OVSVersion string `json:"ovs_version"`
+	DBVersion  string `json:"db_version"`
+}
+
+// CollectionStatus describes the last completed collection.
+type CollectionStatus struct {
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration_seconds"`
+	Up       bool      `json:"up"`
+}
+
+// ProcessStatus describes an OVN or Open vSwitch daemon. The database and
+// the control socket state are nil when they have not been checked yet.
+type ProcessStatus struct {
+	Name              string `json:"name"`
+	PID               int    `json:"pid"`
+	Database          string `json:"database,omitempty"`
+	DatabaseRemote    string `json:"database_remote,omitempty"`
+	DatabaseUp        *bool  `json:"database_up,omitempty"`
+	ControlSocket     string `json:"control_socket"`
+	ControlSocketUp   *bool  `json:"control_socket_up,omitempty"`
+	ControlSocketInfo string `json:"control_socket_error,omitempty"`
+}
+
+// Status is the state of the exporter served by the status endpoints.
+type Status struct {
+	Version        string                 `json:"version"`
+	System         SystemStatus           `json:"system"`
+	LastCollection *CollectionStatus      `json:"last_collection"`
+	Components     []ProcessStatus        `json:"components"`
+	Readiness      *Readiness             `json:"readiness"`
+	RecentErrors   []StepError            `json:"recent_errors"`
+	Config         map[string]interface{} `json:"config"`
+}
+
+// GetStatus returns the state of the exporter.
+func (e *Exporter) GetStatus() *Status {
+	cli := e.clientView()
+	status := &Status{
+		Version: GetVersionInfo(),
+		System: SystemStatus{
+			ID:         cli.System.ID,
+			Hostname:   cli.System.Hostname,
+			RunDir:     cli.System.RunDir,
+			Type:       cli.System.Type,
+			Version:    cli.System.Version,
+			OVSVersion: cli.Database.Vswitch.Version,
+			DBVersion:  cli.Database.Vswitch.Schema.Version,
+		},
+		Readiness:    e.GetReadiness(),
+		RecentErrors: e.recentErrors.list(),
+		Config:       e.configMap(),
+	}
+	if snapshot := e.snapshot.Load(); snapshot != nil && snapshot.completed {
+		status.LastCollection = &CollectionStatus{
+			Time:     snapshot.timestamp,
+			Duration: snapshot.duration.Seconds(),
+			Up:       snapshot.up,
+		}
+	}
+
+	reachable := make(map[string]ComponentStatus)
+	if status.Readiness != nil {
+		for _, c := range status.Readiness.Components {
+			reachable[c.Kind+"/"+c.Name] = c
+		}
+	}
+	controlSocket := func(p *ProcessStatus) {
+		if c, ok := reachable["control_socket/"+p.Name]; ok {
+			ready := c.Ready
+			p.ControlSocketUp = &ready
+			p.ControlSocketInfo = c.Error
+		}
+	}
+	for _, conn := range e.connections {
+		db := conn.database(cli)
+		up, _ := conn.state()
+		p := ProcessStatus{
+			Name:           conn.component,
+			PID:            db.Process.ID,
+			Database:       db.Name,
+			DatabaseRemote: db.Socket.Remote,
+			DatabaseUp:     &up,
+			ControlSocket:  db.Socket.Control,
+		}
+		controlSocket(&p)
+		status.Components = append(status.Components, p)
+	}
+	for _, s := range []struct {
+		name   string
+		daemon ovsdb.OvsDaemon
+	}{
+		{"ovn-northd", cli.Service.Northd},
+		{"ovs-vswitchd", cli.Service.Vswitchd},
+	} {
+		status.Components = append(status.Components, ProcessStatus{
+			Name:          s.name,
+			PID:           s.daemon.Process.ID,
+			ControlSocket: s.daemon.Socket.Control,
+		})
+	}
+	return status
+}
+
+// configMap returns the effective configuration as generic values, so
+// that it is rendered with the names used in the configuration file.
+func (e *Exporter) configMap() map[string]interface{} {
+	cfg := e.config.Load()
+	if cfg == nil {
+		return nil
+	}
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+	m, _ := jsonValue(v).(map[string]interface{})
+	return m
+}
+
+// jsonValue converts the maps decoded by yaml.v2, which have interface
+// keys, to maps which can be encoded to JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(k)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonValue(value)
+		}
+		return v
+	}
+	return v
+}
+
+// StatusHandler serves the state of the exporter as JSON.
+func (e *Exporter) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(e.GetStatus())
+	})
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"state": func(up *bool) string {
+		switch {
+		case up == nil:
+			return "unknown"
+		case *up:
+			return "up"
+		}
+		return "down"
+	},
+	"yaml": func(v interface{}) string {
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err.Error()
+		}
+		return string(b)
+	},
+}).Parse(`<html>
+<head><title>OVN Exporter</title></head>
+<body>
+<h1>OVN Exporter</h1>
+<p>
+<a href="{{.TelemetryPath}}">Metrics</a> |
+<a href="{{.ProbePath}}">Probe</a> |
+<a href="/-/ready">Readiness</a> |
+<a href="/api/v1/status">Status (JSON)</a>
+</p>
+{{with .Status}}
+<h2>System</h2>
+<table>
+<tr><th align="left">Version</th><td><pre>{{.Version}}</pre></td></tr>
+<tr><th align="left">System ID</th><td>{{.System.ID}}</td></tr>
+<tr><th align="left">Hostname</th><td>{{.System.Hostname}}</td></tr>
+<tr><th align="left">OVS Version</th><td>{{.System.OVSVersion}}</td></tr>
+<tr><th align="left">DB Schema Version</th><td>{{.System.DBVersion}}</td></tr>
+{{with .LastCollection}}
+<tr><th align="left">Last Collection</th><td>{{.Time.Format "2006-01-02T15:04:05Z07:00"}} ({{printf "%.3f" .Duration}}s, up={{.Up}})</td></tr>
+{{else}}
+<tr><th align="left">Last Collection</th><td>none</td></tr>
+{{end}}
+</table>
+<h2>Components</h2>
+<table border="1" cellpadding="4">
+<tr><th>Name</th><th>PID</th><th>Database</th><th>Control Socket</th></tr>
+{{range .Components}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{.PID}}</td>
+<td>{{if .Database}}{{.Database}} at {{.DatabaseRemote}} ({{state .DatabaseUp}}){{end}}</td>
+<td>{{.ControlSocket}} ({{state .ControlSocketUp}}){{with .ControlSocketInfo}}: {{.}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+<h2>Recent Errors</h2>
+{{if .RecentErrors}}
+<table border="1" cellpadding="4">
+<tr><th>Time</th><th>Step</th><th>Component</th><th>Error</th></tr>
+{{range .RecentErrors}}
+<tr><td>{{.Time.Format "2006-01-02T15:04:05Z07:00"}}</td><td>{{.Step}}</td><td>{{.Component}}</td><td>{{.Error}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>none</p>
+{{end}}
+<h2>Configuration</h2>
+<pre>{{yaml .Config}}</pre>
+{{end}}
+</body>
+</html>
+`))
+
+// StatusPageHandler serves the landing page, which shows the state of the
+// exporter and links to its endpoints.
+func (e *Exporter) StatusPageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		cfg := e.config.Load()
+		if cfg == nil {
+			cfg = DefaultConfig()
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		statusPageTemplate.Execute(w, struct {
+			TelemetryPath string
+			ProbePath     string
+			Status        *Status
+		}{cfg.Web.TelemetryPath, cfg.Web.ProbePath, e.GetStatus()})
+	})
+}
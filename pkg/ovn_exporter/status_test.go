@@ -0,0 +1,131 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/config"
+)
+
+func TestRecentErrors(t *testing.T) {
+	var r recentErrors
+	for i := 0; i < maxRecentErrors+5; i++ {
+		r.add(fmt.Sprintf("Step%d()", i), "ovsdb-server", errors.New("failed"))
+	}
+	errs := r.list()
+	if len(errs) != maxRecentErrors {
+		t.Fatalf("expected %d errors, but got %d", maxRecentErrors, len(errs))
+	}
+	if want := fmt.Sprintf("Step%d()", maxRecentErrors+4); errs[0].Step != want {
+		t.Errorf("expected most recent error %s first, but got %s", want, errs[0].Step)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	e := newTestExporter(t)
+	cfg := DefaultConfig()
+	disabled := false
+	for name := range cfg.Collectors {
+		cfg.Collectors[name] = CollectorConfig{Enabled: &disabled}
+	}
+	if err := e.ApplyConfig(cfg); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	get := func() Status {
+		rec := httptest.NewRecorder()
+		e.StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+		var s Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatalf("expected JSON body, but got %q", rec.Body.String())
+		}
+		return s
+	}
+
+	s := get()
+	if s.LastCollection != nil {
+		t.Errorf("expected no collection, but got %+v", s.LastCollection)
+	}
+	if s.Config["web"] == nil {
+		t.Errorf("expected web configuration, but got %v", s.Config)
+	}
+
+	// None of the databases and control sockets exist in tests.
+	e.GatherMetrics()
+	s = get()
+	if s.LastCollection == nil || s.LastCollection.Time.IsZero() {
+		t.Fatalf("expected last collection, but got %+v", s.LastCollection)
+	}
+	if len(s.RecentErrors) == 0 {
+		t.Errorf("expected recent errors, but got none")
+	}
+	names := []string{}
+	for _, c := range s.Components {
+		names = append(names, c.Name)
+		if c.Database != "" && (c.DatabaseUp == nil || *c.DatabaseUp) {
+			t.Errorf("expected %s database to be down, but got %v", c.Name, c.DatabaseUp)
+		}
+	}
+	want := "ovsdb-server ovsdb-server-northbound ovsdb-server-southbound ovn-northd ovs-vswitchd"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("expected components %q, but got %q", want, got)
+	}
+}
+
+func TestStatusHidesOTLPHeaders(t *testing.T) {
+	e := newTestExporter(t)
+	cfg := DefaultConfig()
+	cfg.OTLP.Headers = map[string]config.Secret{"Authorization": "Bearer s3cr3t-t0k3n"}
+	if err := e.ApplyConfig(cfg); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	for _, h := range []http.Handler{e.StatusHandler(), e.StatusPageHandler()} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		body := rec.Body.String()
+		if strings.Contains(body, "s3cr3t-t0k3n") {
+			t.Errorf("expected the token to be hidden, but got %q", body)
+		}
+		if !strings.Contains(body, "Authorization") {
+			t.Errorf("expected the header name to be shown, but got %q", body)
+		}
+	}
+}
+
+func TestStatusPageHandler(t *testing.T) {
+	e := newTestExporter(t)
+	rec := httptest.NewRecorder()
+	e.StatusPageHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d", http.StatusOK, rec.Code)
+	}
+	for _, s := range []string{`href="/metrics"`, `href="/api/v1/status"`, "ovsdb-server-northbound"} {
+		if !strings.Contains(rec.Body.String(), s) {
+			t.Errorf("expected page to contain %q", s)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	e.StatusPageHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, but got %d", http.StatusNotFound, rec.Code)
+	}
+}
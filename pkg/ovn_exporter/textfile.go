@@ -0,0 +1,73 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// IsUp reports whether the last collection completed with ovn_up set to 1.
+func (e *Exporter) IsUp() bool {
+	snapshot := e.snapshot.Load()
+	return snapshot != nil && snapshot.completed && snapshot.up
+}
+
+// WriteTextfile writes the metrics of the last collection to a file, in the
+// text exposition format read by the textfile collector of node_exporter.
+// The file is replaced atomically, so that the collector never reads a
+// partial file. The samples are written without timestamps, which the
+// collector rejects.
+func (e *Exporter) WriteTextfile(path string) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(e); err != nil {
+		return err
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := expfmt.NewEncoder(tmp, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			m.TimestampMs = nil
+		}
+		if err := enc.Encode(mf); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed writing %s: %s", tmp.Name(), err)
+		}
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn_exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	e := newTestExporter(t)
+	e.SetCollectionTimestamps(true)
+	if e.IsUp() {
+		t.Errorf("expected down before a collection")
+	}
+	// None of the databases exist in tests.
+	e.GatherMetrics()
+	if e.IsUp() {
+		t.Errorf("expected down without databases")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ovn.prom")
+	if err := os.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteTextfile(path); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	found := false
+	for _, line := range lines {
+		if line == "ovn_up 0" {
+			found = true
+		}
+		if !strings.HasPrefix(line, "#") && len(strings.Fields(line)) != 2 {
+			t.Errorf("expected sample without timestamp, but got %q", line)
+		}
+	}
+	if !found {
+		t.Errorf("expected ovn_up 0, but got:\n%s", b)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the textfile, but got %v", entries)
+	}
+
+	if err := e.WriteTextfile(filepath.Join(dir, "missing", "ovn.prom")); err == nil {
+		t.Errorf("expected error, but got none")
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Row is a row of a table by column, with the values in the OVSDB JSON
+// notation of RFC 7047: strings, numbers and booleans, or the values
+// returned by UUID, Set and Map. The columns missing from a row get their
+// default values.
+type Row map[string]interface{}
+
+// UUID returns a UUID value.
+func UUID(id string) interface{} {
+	return []interface{}{"uuid", id}
+}
+
+// Set returns a set value.
+func Set(elements ...interface{}) interface{} {
+	if elements == nil {
+		elements = []interface{}{}
+	}
+	return []interface{}{"set", elements}
+}
+
+// Map returns a map value of strings, sorted by key.
+func Map(m map[string]string) interface{} {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []interface{}{}
+	for _, k := range keys {
+		pairs = append(pairs, []interface{}{k, m[k]})
+	}
+	return []interface{}{"map", pairs}
+}
+
+// Database is the content of a database served by a Server. It is safe
+// for concurrent use. The changes made while it is served are sent to the
+// clients monitoring it.
+type Database struct {
+	// Model, Leader, ClusterID and ServerID describe the database in the
+	// Database table of the _Server database. They must be set before
+	// the database is served. The model is "standalone" by default.
+	Model     string
+	Leader    bool
+	ClusterID string
+	ServerID  string
+
+	schema   *schema
+	mu       sync.RWMutex
+	tables   map[string]map[string]*storedRow
+	nextID   uint64
+	watchers map[*watcher]struct{}
+}
+
+// storedRow is a row with its version.
+type storedRow struct {
+	version string
+	columns Row
+}
+
+// watcher is notified of the changes of a database. The old row is nil
+// for an insert and the new row is nil for a delete.
+type watcher struct {
+	fn func(table, id string, old, new Row)
+}
+
+// NewDatabase returns an empty database of one of the OVN_Northbound,
+// OVN_Southbound and Open_vSwitch schemas.
+func NewDatabase(name string) (*Database, error) {
+	s, err := loadSchema(name)
+	if err != nil {
+		return nil, err
+	}
+	db := &Database{
+		Model:    "standalone",
+		schema:   s,
+		tables:   make(map[string]map[string]*storedRow),
+		watchers: make(map[*watcher]struct{}),
+	}
+	for table := range s.tables {
+		db.tables[table] = make(map[string]*storedRow)
+	}
+	return db, nil
+}
+
+// LoadFixtures reads databases from a JSON file. The file maps the names
+// of the databases to their tables, the tables to their rows by UUID, and
+// the rows to their columns, in the notation of Row.
+func LoadFixtures(path string) (map[string]*Database, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures map[string]map[string]map[string]Row
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	databases := make(map[string]*Database)
+	for name, tables := range fixtures {
+		db, err := NewDatabase(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		for table, rows := range tables {
+			for id, row := range rows {
+				if err := db.InsertWithUUID(table, id, row); err != nil {
+					return nil, fmt.Errorf("%s: %s", path, err)
+				}
+			}
+		}
+		databases[name] = db
+	}
+	return databases, nil
+}
+
+// Name returns the name of the database.
+func (db *Database) Name() string {
+	return db.schema.name
+}
+
+// newUUID returns a new UUID. The UUIDs are sequential, so that the
+// content of a database built the same way is the same.
+func (db *Database) newUUID() string {
+	db.nextID++
+	return fmt.Sprintf("%08x-0000-4000-8000-%012x", len(db.schema.name), db.nextID)
+}
+
+// Insert adds a row to a table and returns its UUID.
+func (db *Database) Insert(table string, row Row) (string, error) {
+	db.mu.Lock()
+	id := db.newUUID()
+	db.mu.Unlock()
+	return id, db.InsertWithUUID(table, id, row)
+}
+
+// InsertWithUUID adds a row with the given UUID to a table.
+func (db *Database) InsertWithUUID(table, id string, row Row) error {
+	columns, err := db.normalize(table, row)
+	if err != nil {
+		return err
+	}
+	for column, ct := range db.schema.tables[table] {
+		if _, exists := columns[column]; !exists {
+			columns[column] = ct.defaultValue()
+		}
+	}
+	db.mu.Lock()
+	if _, exists := db.tables[table][id]; exists {
+		db.mu.Unlock()
+		return fmt.Errorf("%s: duplicate row %s", table, id)
+	}
+	db.tables[table][id] = &storedRow{version: db.newUUID(), columns: columns}
+	watchers := db.watcherList()
+	db.mu.Unlock()
+	for _, w := range watchers {
+		w.fn(table, id, nil, columns)
+	}
+	return nil
+}
+
+// Update sets columns of a row.
+func (db *Database) Update(table, id string, row Row) error {
+	columns, err := db.normalize(table, row)
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	r, exists := db.tables[table][id]
+	if !exists {
+		db.mu.Unlock()
+		return fmt.Errorf("%s: no row %s", table, id)
+	}
+	old := r.columns
+	updated := make(Row, len(old))
+	for column, value := range old {
+		updated[column] = value
+	}
+	for column, value := range columns {
+		updated[column] = value
+	}
+	db.tables[table][id] = &storedRow{version: db.newUUID(), columns: updated}
+	watchers := db.watcherList()
+	db.mu.Unlock()
+	for _, w := range watchers {
+		w.fn(table, id, old, updated)
+	}
+	return nil
+}
+
+// Delete removes a row.
+func (db *Database) Delete(table, id string) error {
+	db.mu.Lock()
+	r, exists := db.tables[table][id]
+	if !exists {
+		db.mu.Unlock()
+		return fmt.Errorf("%s: no row %s", table, id)
+	}
+	delete(db.tables[table], id)
+	watchers := db.watcherList()
+	db.mu.Unlock()
+	for _, w := range watchers {
+		w.fn(table, id, r.columns, nil)
+	}
+	return nil
+}
+
+// normalize checks the columns of a row and converts their values to the
+// types decoded from JSON.
+func (db *Database) normalize(table string, row Row) (Row, error) {
+	columns, exists := db.schema.tables[table]
+	if !exists {
+		return nil, fmt.Errorf("%s: unknown table", table)
+	}
+	for column := range row {
+		if _, exists := columns[column]; !exists {
+			return nil, fmt.Errorf("%s: unknown column %s", table, column)
+		}
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", table, err)
+	}
+	normalized := Row{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return nil, fmt.Errorf("%s: %s", table, err)
+	}
+	return normalized, nil
+}
+
+// watch registers a function called on every change of the database.
+func (db *Database) watch(fn func(table, id string, old, new Row)) func() {
+	w := &watcher{fn: fn}
+	db.mu.Lock()
+	db.watchers[w] = struct{}{}
+	db.mu.Unlock()
+	return func() {
+		db.mu.Lock()
+		delete(db.watchers, w)
+		db.mu.Unlock()
+	}
+}
+
+func (db *Database) watcherList() []*watcher {
+	watchers := make([]*watcher, 0, len(db.watchers))
+	for w := range db.watchers {
+		watchers = append(watchers, w)
+	}
+	return watchers
+}
+
+// tableRow is a row with its UUID.
+type tableRow struct {
+	id string
+	*storedRow
+}
+
+// rows returns the rows of a table, sorted by UUID.
+func (db *Database) rows(table string) ([]tableRow, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	t, exists := db.tables[table]
+	if !exists {
+		return nil, false
+	}
+	rows := make([]tableRow, 0, len(t))
+	for id, r := range t {
+		rows = append(rows, tableRow{id: id, storedRow: r})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+	return rows, true
+}
@@ -0,0 +1,174 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+)
+
+// writeTimeout bounds the time a write to a client not reading its
+// messages blocks.
+const writeTimeout = 5 * time.Second
+
+// rpcHandler handles a JSON-RPC request. It returns either the result or
+// the error of the response, or responded when it sent the response
+// itself.
+type rpcHandler func(c *rpcConn, m rpcMessage) (interface{}, interface{})
+
+// responded is returned by the handlers sending their response.
+type responded struct{}
+
+// rpcServer serves JSON-RPC 1.0 on a unix socket.
+type rpcServer struct {
+	path     string
+	listener net.Listener
+	handle   rpcHandler
+	mu       sync.Mutex
+	conns    map[*rpcConn]struct{}
+	wg       sync.WaitGroup
+}
+
+// rpcConn is a connection of a client.
+type rpcConn struct {
+	net.Conn
+	mu  sync.Mutex
+	enc *json.Encoder
+	// cancels holds the functions stopping the monitors of the client,
+	// by monitor ID.
+	cancels map[string]func()
+}
+
+// rpcMessage is a request, a notification or a response.
+type rpcMessage struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func (m rpcMessage) isNotification() bool {
+	return len(m.ID) == 0 || bytes.Equal(m.ID, []byte("null"))
+}
+
+// rpcResponse is the response to a request.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+// rpcNotification is a message sent to a client without a request.
+type rpcNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+func listen(path string, handle rpcHandler) (*rpcServer, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &rpcServer{
+		path:     path,
+		listener: listener,
+		handle:   handle,
+		conns:    make(map[*rpcConn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+func (s *rpcServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &rpcConn{Conn: conn, cancels: make(map[string]func())}
+		c.enc = json.NewEncoder(conn)
+		// The OVS daemons do not escape the HTML characters, and the
+		// output of some commands, e.g. cluster/status, has some.
+		c.enc.SetEscapeHTML(false)
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+func (s *rpcServer) serve(c *rpcConn) {
+	defer s.wg.Done()
+	defer func() {
+		c.Close()
+		c.mu.Lock()
+		for _, cancel := range c.cancels {
+			cancel()
+		}
+		c.mu.Unlock()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+	dec := json.NewDecoder(c)
+	for {
+		var m rpcMessage
+		if err := dec.Decode(&m); err != nil {
+			return
+		}
+		if m.Method == "" {
+			// A response to a request of the server.
+			continue
+		}
+		result, rpcErr := s.handle(c, m)
+		if _, ok := result.(responded); ok || m.isNotification() {
+			continue
+		}
+		if err := c.send(rpcResponse{ID: m.ID, Result: result, Error: rpcErr}); err != nil {
+			return
+		}
+	}
+}
+
+// send writes a message to the client.
+func (c *rpcConn) send(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendLocked(v)
+}
+
+func (c *rpcConn) sendLocked(v interface{}) error {
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.enc.Encode(v)
+}
+
+// close stops accepting connections, closes the connections of the
+// clients and waits for their goroutines to return.
+func (s *rpcServer) close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
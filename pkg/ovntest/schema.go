@@ -0,0 +1,178 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ovntest provides in-process OVSDB and unixctl servers serving
+// canned OVN data, for testing and benchmarking the exporter without OVN.
+package ovntest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+)
+
+//go:embed schemas/*.ovsschema
+var schemaFiles embed.FS
+
+// schemaFileNames maps the names of the databases to their schema files.
+var schemaFileNames = map[string]string{
+	"OVN_Northbound": "schemas/ovn-nb.ovsschema",
+	"OVN_Southbound": "schemas/ovn-sb.ovsschema",
+	"Open_vSwitch":   "schemas/vswitch.ovsschema",
+	"_Server":        "schemas/_server.ovsschema",
+}
+
+// zeroUUID is the default value of a UUID column.
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// schema is a database schema, with the raw JSON served by get_schema.
+type schema struct {
+	raw     json.RawMessage
+	name    string
+	version string
+	tables  map[string]map[string]columnType
+}
+
+// columnType is the type of a column. A max of -1 means unlimited.
+type columnType struct {
+	key   string
+	value string
+	min   int
+	max   int
+}
+
+// loadSchema returns the schema of a database.
+func loadSchema(name string) (*schema, error) {
+	path, exists := schemaFileNames[name]
+	if !exists {
+		return nil, fmt.Errorf("no schema for the %s database", name)
+	}
+	b, err := schemaFiles.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSchema(b)
+}
+
+func parseSchema(b []byte) (*schema, error) {
+	var doc struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Tables  map[string]struct {
+			Columns map[string]struct {
+				Type json.RawMessage `json:"type"`
+			} `json:"columns"`
+		} `json:"tables"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("schema: %s", err)
+	}
+	s := &schema{
+		raw:     json.RawMessage(b),
+		name:    doc.Name,
+		version: doc.Version,
+		tables:  make(map[string]map[string]columnType),
+	}
+	for table, t := range doc.Tables {
+		s.tables[table] = make(map[string]columnType)
+		for column, c := range t.Columns {
+			ct, err := parseColumnType(c.Type)
+			if err != nil {
+				return nil, fmt.Errorf("schema: %s.%s: %s", table, column, err)
+			}
+			s.tables[table][column] = ct
+		}
+	}
+	return s, nil
+}
+
+func parseColumnType(b json.RawMessage) (columnType, error) {
+	ct := columnType{min: 1, max: 1}
+	var atomic string
+	if err := json.Unmarshal(b, &atomic); err == nil {
+		ct.key = atomic
+		return ct, nil
+	}
+	var t struct {
+		Key   json.RawMessage `json:"key"`
+		Value json.RawMessage `json:"value"`
+		Min   *int            `json:"min"`
+		Max   interface{}     `json:"max"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return ct, err
+	}
+	var err error
+	if ct.key, err = parseBaseType(t.Key); err != nil {
+		return ct, err
+	}
+	if t.Value != nil {
+		if ct.value, err = parseBaseType(t.Value); err != nil {
+			return ct, err
+		}
+	}
+	if t.Min != nil {
+		ct.min = *t.Min
+	}
+	switch max := t.Max.(type) {
+	case nil:
+	case float64:
+		ct.max = int(max)
+	case string:
+		if max != "unlimited" {
+			return ct, fmt.Errorf("invalid max %q", max)
+		}
+		ct.max = -1
+	default:
+		return ct, fmt.Errorf("invalid max %v", max)
+	}
+	return ct, nil
+}
+
+func parseBaseType(b json.RawMessage) (string, error) {
+	var atomic string
+	if err := json.Unmarshal(b, &atomic); err == nil {
+		return atomic, nil
+	}
+	var t struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", err
+	}
+	if t.Type == "" {
+		return "", fmt.Errorf("no type in %s", b)
+	}
+	return t.Type, nil
+}
+
+// defaultValue returns the value of a column not set in a row, in the
+// OVSDB JSON notation.
+func (ct columnType) defaultValue() interface{} {
+	if ct.value != "" {
+		return []interface{}{"map", []interface{}{}}
+	}
+	if ct.min == 0 || ct.max != 1 {
+		return []interface{}{"set", []interface{}{}}
+	}
+	switch ct.key {
+	case "integer", "real":
+		return float64(0)
+	case "boolean":
+		return false
+	case "uuid":
+		return []interface{}{"uuid", zeroUUID}
+	}
+	return ""
+}
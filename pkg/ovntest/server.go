@@ -0,0 +1,523 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Server is an in-process OVSDB server listening on a unix socket. It
+// serves the list_dbs, get_schema, transact, monitor, monitor_cancel and
+// echo methods of RFC 7047 for its databases and for the _Server database
+// describing them. The transactions only support the select operation.
+type Server struct {
+	*rpcServer
+	databases map[string]*Database
+}
+
+// NewServer starts a server of databases on a unix socket.
+func NewServer(path string, databases ...*Database) (*Server, error) {
+	s := &Server{databases: make(map[string]*Database)}
+	for _, db := range databases {
+		if _, exists := s.databases[db.Name()]; exists {
+			return nil, fmt.Errorf("duplicate %s database", db.Name())
+		}
+		s.databases[db.Name()] = db
+	}
+	server, err := newServerDatabase(databases)
+	if err != nil {
+		return nil, err
+	}
+	s.databases[server.Name()] = server
+	s.rpcServer, err = listen(path, s.handle)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newServerDatabase returns the _Server database describing databases.
+func newServerDatabase(databases []*Database) (*Database, error) {
+	server, err := NewDatabase("_Server")
+	if err != nil {
+		return nil, err
+	}
+	for _, db := range append([]*Database{server}, databases...) {
+		row := Row{
+			"name":      db.Name(),
+			"model":     db.Model,
+			"connected": true,
+			"leader":    db.Model != "clustered" || db.Leader,
+			"schema":    string(db.schema.raw),
+		}
+		if db.ClusterID != "" {
+			row["cid"] = UUID(db.ClusterID)
+		}
+		if db.ServerID != "" {
+			row["sid"] = UUID(db.ServerID)
+		}
+		if _, err := server.Insert("Database", row); err != nil {
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
+// Remote returns the address of the server, in the format of the OVS
+// tools, e.g. "unix:/run/ovn/ovnnb_db.sock".
+func (s *Server) Remote() string {
+	return "unix:" + s.path
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.close()
+}
+
+// rpcError returns the error of a response.
+func rpcError(err, details string) interface{} {
+	return map[string]string{"error": err, "details": details}
+}
+
+func (s *Server) handle(c *rpcConn, m rpcMessage) (interface{}, interface{}) {
+	switch m.Method {
+	case "echo":
+		return m.Params, nil
+	case "list_dbs":
+		names := []string{}
+		for name := range s.databases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	case "get_schema":
+		db, rpcErr := s.database(m.Params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return db.schema.raw, nil
+	case "transact":
+		db, rpcErr := s.database(m.Params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return transact(db, m.Params[1:]), nil
+	case "monitor":
+		return s.monitor(c, m)
+	case "monitor_cancel":
+		if len(m.Params) != 1 {
+			return nil, rpcError("syntax error", "monitor_cancel takes a monitor ID")
+		}
+		c.mu.Lock()
+		cancel, exists := c.cancels[string(m.Params[0])]
+		delete(c.cancels, string(m.Params[0]))
+		c.mu.Unlock()
+		if !exists {
+			return nil, rpcError("unknown monitor", string(m.Params[0]))
+		}
+		cancel()
+		return map[string]interface{}{}, nil
+	}
+	return nil, rpcError("unknown method", m.Method)
+}
+
+// database returns the database named by the first parameter.
+func (s *Server) database(params []json.RawMessage) (*Database, interface{}) {
+	if len(params) == 0 {
+		return nil, rpcError("syntax error", "no database name")
+	}
+	var name string
+	if err := json.Unmarshal(params[0], &name); err != nil {
+		return nil, rpcError("syntax error", "the database name is not a string")
+	}
+	db, exists := s.databases[name]
+	if !exists {
+		return nil, rpcError("unknown database", fmt.Sprintf("%s is not a valid database name", name))
+	}
+	return db, nil
+}
+
+// operation is an operation of a transaction.
+type operation struct {
+	Op      string          `json:"op"`
+	Table   string          `json:"table"`
+	Where   [][]interface{} `json:"where"`
+	Columns []string        `json:"columns"`
+}
+
+// transact runs the operations of a transaction. After an operation
+// failing, the results of the remaining operations are null.
+func transact(db *Database, params []json.RawMessage) []interface{} {
+	results := make([]interface{}, len(params))
+	for i, p := range params {
+		var op operation
+		if err := json.Unmarshal(p, &op); err != nil {
+			results[i] = rpcError("syntax error", err.Error())
+			break
+		}
+		if op.Op != "select" {
+			results[i] = rpcError("not supported", fmt.Sprintf("the %q operation is not supported", op.Op))
+			break
+		}
+		rows, err := selectRows(db, op)
+		if err != nil {
+			results[i] = err
+			break
+		}
+		results[i] = map[string]interface{}{"rows": rows}
+	}
+	return results
+}
+
+// selectRows returns the rows of a select operation, or its error.
+func selectRows(db *Database, op operation) ([]map[string]interface{}, interface{}) {
+	columns, exists := db.schema.tables[op.Table]
+	if !exists {
+		return nil, rpcError("unknown table", op.Table)
+	}
+	selected := op.Columns
+	if selected == nil {
+		selected = []string{"_uuid", "_version"}
+		for column := range columns {
+			selected = append(selected, column)
+		}
+	}
+	for _, column := range selected {
+		if _, exists := columns[column]; !exists && column != "_uuid" && column != "_version" {
+			return nil, rpcError("unknown column", fmt.Sprintf("%s has no %s column", op.Table, column))
+		}
+	}
+	all, _ := db.rows(op.Table)
+	rows := []map[string]interface{}{}
+	for _, r := range all {
+		matched, err := matchConditions(r, op.Where)
+		if err != nil {
+			return nil, rpcError("syntax error", err.Error())
+		}
+		if matched {
+			rows = append(rows, encodeRow(r, selected))
+		}
+	}
+	return rows, nil
+}
+
+// columnValue returns the value of a column of a row, including the
+// _uuid and _version columns.
+func columnValue(r tableRow, column string) interface{} {
+	switch column {
+	case "_uuid":
+		return UUID(r.id)
+	case "_version":
+		return UUID(r.version)
+	}
+	return r.columns[column]
+}
+
+// encodeRow returns the columns of a row as sent to the clients.
+func encodeRow(r tableRow, columns []string) map[string]interface{} {
+	row := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		row[column] = wireValue(columnValue(r, column))
+	}
+	return row
+}
+
+// wireValue returns a value as sent by ovsdb-server, which sends the sets
+// of a single element as that element.
+func wireValue(v interface{}) interface{} {
+	if a, ok := v.([]interface{}); ok && len(a) == 2 && a[0] == "set" {
+		if elements, ok := a[1].([]interface{}); ok && len(elements) == 1 {
+			return elements[0]
+		}
+	}
+	return v
+}
+
+// matchConditions evaluates the conditions of an operation on a row.
+func matchConditions(r tableRow, conditions [][]interface{}) (bool, error) {
+	for _, cond := range conditions {
+		if len(cond) != 3 {
+			return false, fmt.Errorf("invalid condition %v", cond)
+		}
+		column, ok := cond[0].(string)
+		if !ok {
+			return false, fmt.Errorf("invalid condition %v", cond)
+		}
+		function, ok := cond[1].(string)
+		if !ok {
+			return false, fmt.Errorf("invalid condition %v", cond)
+		}
+		matched, err := evalCondition(columnValue(r, column), function, cond[2])
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func evalCondition(value interface{}, function string, arg interface{}) (bool, error) {
+	switch function {
+	case "==":
+		return canonical(value) == canonical(arg), nil
+	case "!=":
+		return canonical(value) != canonical(arg), nil
+	case "includes", "excludes":
+		have := make(map[string]bool)
+		for _, e := range elements(value) {
+			have[canonicalAtom(e)] = true
+		}
+		for _, e := range elements(arg) {
+			if have[canonicalAtom(e)] != (function == "includes") {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "<", "<=", ">", ">=":
+		x, ok1 := atom(value).(float64)
+		y, ok2 := atom(arg).(float64)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("the %s function only applies to numbers", function)
+		}
+		switch function {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		}
+		return x >= y, nil
+	}
+	return false, fmt.Errorf("unknown function %s", function)
+}
+
+// atom returns the atom of a value, unwrapping the UUIDs and the sets of
+// a single element.
+func atom(v interface{}) interface{} {
+	if a, ok := v.([]interface{}); ok && len(a) == 2 {
+		switch a[0] {
+		case "uuid", "named-uuid":
+			return a[1]
+		case "set":
+			if elements, ok := a[1].([]interface{}); ok && len(elements) == 1 {
+				return atom(elements[0])
+			}
+		}
+	}
+	return v
+}
+
+// elements returns the elements of a set, the pairs of a map, or the
+// atom of a scalar.
+func elements(v interface{}) []interface{} {
+	if a, ok := v.([]interface{}); ok && len(a) == 2 && (a[0] == "set" || a[0] == "map") {
+		if elements, ok := a[1].([]interface{}); ok {
+			return elements
+		}
+	}
+	return []interface{}{v}
+}
+
+func canonicalAtom(v interface{}) string {
+	if pair, ok := v.([]interface{}); ok && len(pair) == 2 {
+		if _, isString := pair[0].(string); isString && pair[0] != "uuid" && pair[0] != "named-uuid" {
+			return canonicalAtom(pair[0]) + "=" + canonicalAtom(pair[1])
+		}
+	}
+	b, _ := json.Marshal(atom(v))
+	return string(b)
+}
+
+// canonical returns a representation of a value equal for the values
+// equal in OVSDB, whatever the order of their elements.
+func canonical(v interface{}) string {
+	keys := []string{}
+	for _, e := range elements(v) {
+		keys = append(keys, canonicalAtom(e))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// monitorRequest is the request of a monitor for a table.
+type monitorRequest struct {
+	Columns []string `json:"columns"`
+	Select  *struct {
+		Initial *bool `json:"initial"`
+		Insert  *bool `json:"insert"`
+		Delete  *bool `json:"delete"`
+		Modify  *bool `json:"modify"`
+	} `json:"select"`
+}
+
+// selects returns whether a kind of changes is monitored.
+func (r monitorRequest) selects(kind string) bool {
+	if r.Select == nil {
+		return true
+	}
+	var b *bool
+	switch kind {
+	case "initial":
+		b = r.Select.Initial
+	case "insert":
+		b = r.Select.Insert
+	case "delete":
+		b = r.Select.Delete
+	case "modify":
+		b = r.Select.Modify
+	}
+	return b == nil || *b
+}
+
+// monitor replies with the content of the monitored tables, then sends
+// their changes in update notifications.
+func (s *Server) monitor(c *rpcConn, m rpcMessage) (interface{}, interface{}) {
+	if len(m.Params) != 3 {
+		return nil, rpcError("syntax error", "monitor takes a database, a monitor ID and requests")
+	}
+	db, rpcErr := s.database(m.Params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(m.Params[2], &raw); err != nil {
+		return nil, rpcError("syntax error", err.Error())
+	}
+	requests := make(map[string][]monitorRequest)
+	for table, b := range raw {
+		columns, exists := db.schema.tables[table]
+		if !exists {
+			return nil, rpcError("unknown table", table)
+		}
+		var reqs []monitorRequest
+		if err := json.Unmarshal(b, &reqs); err != nil {
+			var req monitorRequest
+			if err := json.Unmarshal(b, &req); err != nil {
+				return nil, rpcError("syntax error", err.Error())
+			}
+			reqs = []monitorRequest{req}
+		}
+		for i := range reqs {
+			if reqs[i].Columns == nil {
+				for column := range columns {
+					reqs[i].Columns = append(reqs[i].Columns, column)
+				}
+				sort.Strings(reqs[i].Columns)
+			}
+			for _, column := range reqs[i].Columns {
+				if _, exists := columns[column]; !exists {
+					return nil, rpcError("unknown column", fmt.Sprintf("%s has no %s column", table, column))
+				}
+			}
+		}
+		requests[table] = reqs
+	}
+	id := string(m.Params[1])
+
+	// The changes are sent after the response, which holds the lock of
+	// the connection.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.cancels[id]; exists {
+		return nil, rpcError("duplicate monitor ID", id)
+	}
+	monitorID := m.Params[1]
+	c.cancels[id] = db.watch(func(table, rowID string, old, new Row) {
+		if update := tableUpdate(requests[table], old, new); update != nil {
+			c.send(rpcNotification{
+				Method: "update",
+				Params: []interface{}{monitorID, map[string]interface{}{table: map[string]interface{}{rowID: update}}},
+			})
+		}
+	})
+	updates := make(map[string]interface{})
+	for table, reqs := range requests {
+		rows, _ := db.rows(table)
+		t := make(map[string]interface{})
+		for _, r := range rows {
+			if update := tableUpdate(initialRequests(reqs), nil, r.columns); update != nil {
+				t[r.id] = update
+			}
+		}
+		if len(t) > 0 {
+			updates[table] = t
+		}
+	}
+	if !m.isNotification() {
+		c.sendLocked(rpcResponse{ID: m.ID, Result: updates})
+	}
+	return responded{}, nil
+}
+
+// initialRequests returns the requests for the initial content of a
+// table, as requests for inserts.
+func initialRequests(reqs []monitorRequest) []monitorRequest {
+	initial := []monitorRequest{}
+	for _, req := range reqs {
+		if req.selects("initial") {
+			initial = append(initial, monitorRequest{Columns: req.Columns})
+		}
+	}
+	return initial
+}
+
+// tableUpdate returns the update of a row for the requests of its table,
+// or nil when the change is not monitored. The old row is nil for an
+// insert, and the new row for a delete.
+func tableUpdate(reqs []monitorRequest, old, new Row) map[string]interface{} {
+	kind := "modify"
+	if old == nil {
+		kind = "insert"
+	} else if new == nil {
+		kind = "delete"
+	}
+	oldColumns := make(map[string]interface{})
+	newColumns := make(map[string]interface{})
+	for _, req := range reqs {
+		if !req.selects(kind) {
+			continue
+		}
+		for _, column := range req.Columns {
+			switch kind {
+			case "insert":
+				newColumns[column] = wireValue(new[column])
+			case "delete":
+				oldColumns[column] = wireValue(old[column])
+			default:
+				newColumns[column] = wireValue(new[column])
+				if canonical(old[column]) != canonical(new[column]) {
+					oldColumns[column] = wireValue(old[column])
+				}
+			}
+		}
+	}
+	update := make(map[string]interface{})
+	if len(oldColumns) > 0 {
+		update["old"] = oldColumns
+	}
+	if len(newColumns) > 0 && (kind != "modify" || len(oldColumns) > 0) {
+		update["new"] = newColumns
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	return update
+}
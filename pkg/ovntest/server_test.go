@@ -0,0 +1,266 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/greenpau/ovsdb"
+)
+
+// rpcClient is a raw JSON-RPC client of the servers.
+type rpcClient struct {
+	t    *testing.T
+	conn net.Conn
+	dec  *json.Decoder
+	id   int
+}
+
+func newRPCClient(t *testing.T, path string) *rpcClient {
+	t.Helper()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &rpcClient{t: t, conn: conn, dec: json.NewDecoder(conn)}
+}
+
+// call sends a request and returns the message received next.
+func (c *rpcClient) call(method string, params ...interface{}) map[string]interface{} {
+	c.t.Helper()
+	if params == nil {
+		params = []interface{}{}
+	}
+	c.id++
+	req := map[string]interface{}{"id": c.id, "method": method, "params": params}
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		c.t.Fatalf("expected no error, but got %q", err)
+	}
+	return c.receive()
+}
+
+func (c *rpcClient) receive() map[string]interface{} {
+	c.t.Helper()
+	var m map[string]interface{}
+	if err := c.dec.Decode(&m); err != nil {
+		c.t.Fatalf("expected no error, but got %q", err)
+	}
+	return m
+}
+
+func newTestServer(t *testing.T, databases ...*Database) *Server {
+	t.Helper()
+	s, err := NewServer(filepath.Join(t.TempDir(), "db.sock"), databases...)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestServerWithClient(t *testing.T) {
+	nb, _, err := NewTopology(Topology{Chassis: 2, Switches: 2, PortsPerSwitch: 3, Routers: 1})
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	s := newTestServer(t, nb)
+	cli, err := ovsdb.NewClient(s.Remote(), 2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	defer cli.Close()
+
+	dbs, err := cli.Databases()
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if want := []string{"OVN_Northbound", "_Server"}; !reflect.DeepEqual(dbs, want) {
+		t.Errorf("expected databases %v, but got %v", want, dbs)
+	}
+
+	for query, want := range map[string]int{
+		"SELECT _uuid, name, ports FROM Logical_Switch":                    2,
+		"SELECT _uuid, addresses, up FROM Logical_Switch_Port":             6,
+		"SELECT _uuid, name, ports FROM Logical_Router":                    1,
+		"SELECT _uuid, networks, gateway_chassis FROM Logical_Router_Port": 3,
+		`SELECT _uuid FROM Logical_Switch WHERE name == "switch-2"`:        1,
+		`SELECT _uuid FROM Logical_Switch WHERE name != "switch-2"`:        1,
+		`SELECT _uuid FROM Logical_Switch WHERE name == "switch-3"`:        0,
+		"SELECT name, model, connected, leader, cid, sid FROM Database":    0,
+	} {
+		result, err := cli.Transact("OVN_Northbound", query)
+		if query == "SELECT name, model, connected, leader, cid, sid FROM Database" {
+			result, err = cli.Transact("_Server", query)
+			want = 2
+		}
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got %q", query, err)
+		}
+		if len(result.Rows) != want {
+			t.Errorf("%s: expected %d rows, but got %d", query, want, len(result.Rows))
+		}
+	}
+
+	result, err := cli.Transact("OVN_Northbound", "SELECT _uuid, addresses, up FROM Logical_Switch_Port")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	for _, row := range result.Rows {
+		if v, dt, err := row.GetColumnValue("addresses", result.Columns); err != nil || dt != "string" {
+			t.Errorf("expected a single address, but got %v (%s): %v", v, dt, err)
+		}
+		if v, dt, err := row.GetColumnValue("up", result.Columns); err != nil || dt != "bool" || v != true {
+			t.Errorf("expected the port to be up, but got %v (%s): %v", v, dt, err)
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	nb, err := NewDatabase("OVN_Northbound")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	c := newRPCClient(t, newTestServer(t, nb).path)
+
+	for _, tc := range []struct {
+		method string
+		params []interface{}
+		want   interface{}
+	}{
+		{"get_schema", []interface{}{"OVN_Southbound"}, "unknown database"},
+		{"frobnicate", nil, "unknown method"},
+	} {
+		resp := c.call(tc.method, tc.params...)
+		rpcErr, _ := resp["error"].(map[string]interface{})
+		if rpcErr["error"] != tc.want {
+			t.Errorf("%s: expected error %q, but got %v", tc.method, tc.want, resp)
+		}
+	}
+
+	resp := c.call("transact", "OVN_Northbound",
+		map[string]interface{}{"op": "insert", "table": "Logical_Switch", "row": map[string]interface{}{}},
+		map[string]interface{}{"op": "select", "table": "Logical_Switch", "where": []interface{}{}},
+	)
+	results, _ := resp["result"].([]interface{})
+	if len(results) != 2 || results[1] != nil {
+		t.Fatalf("expected the operations after the failed one to be skipped, but got %v", resp)
+	}
+	if r, _ := results[0].(map[string]interface{}); r["error"] != "not supported" {
+		t.Errorf("expected the insert to be unsupported, but got %v", results[0])
+	}
+}
+
+func TestServerMonitor(t *testing.T) {
+	nb, err := NewDatabase("OVN_Northbound")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	id, err := nb.Insert("Logical_Switch", Row{"name": "sw0"})
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	c := newRPCClient(t, newTestServer(t, nb).path)
+
+	resp := c.call("monitor", "OVN_Northbound", "m1", map[string]interface{}{
+		"Logical_Switch": map[string]interface{}{"columns": []string{"name"}},
+	})
+	want := map[string]interface{}{
+		"Logical_Switch": map[string]interface{}{
+			id: map[string]interface{}{"new": map[string]interface{}{"name": "sw0"}},
+		},
+	}
+	if !reflect.DeepEqual(resp["result"], want) {
+		t.Fatalf("expected initial content %v, but got %v", want, resp)
+	}
+
+	// A change of a column not monitored is not sent.
+	if err := nb.Update("Logical_Switch", id, Row{"external_ids": Map(map[string]string{"k": "v"})}); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if err := nb.Update("Logical_Switch", id, Row{"name": "sw1"}); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	if err := nb.Delete("Logical_Switch", id); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	for _, want := range []map[string]interface{}{
+		{"old": map[string]interface{}{"name": "sw0"}, "new": map[string]interface{}{"name": "sw1"}},
+		{"old": map[string]interface{}{"name": "sw1"}},
+	} {
+		m := c.receive()
+		params, _ := m["params"].([]interface{})
+		if m["method"] != "update" || len(params) != 2 || params[0] != "m1" {
+			t.Fatalf("expected an update of m1, but got %v", m)
+		}
+		got := params[1].(map[string]interface{})["Logical_Switch"].(map[string]interface{})[id]
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected update %v, but got %v", want, got)
+		}
+	}
+
+	resp = c.call("monitor_cancel", "m1")
+	if resp["error"] != nil {
+		t.Errorf("expected no error, but got %v", resp)
+	}
+	if _, err := nb.Insert("Logical_Switch", Row{"name": "sw2"}); err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	resp = c.call("echo", "ping")
+	if !reflect.DeepEqual(resp["result"], []interface{}{"ping"}) {
+		t.Errorf("expected the echo reply after the monitor was cancelled, but got %v", resp)
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	databases, err := LoadFixtures("testdata/fixtures.json")
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	names := []string{}
+	for name := range databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"OVN_Northbound", "OVN_Southbound"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected databases %v, but got %v", want, names)
+	}
+	rows, _ := databases["OVN_Northbound"].rows("Logical_Switch_Port")
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 ports, but got %d", len(rows))
+	}
+	// The columns missing from the fixtures have their default values.
+	if got := encodeRow(rows[0], []string{"addresses", "tag", "type"}); !reflect.DeepEqual(got, map[string]interface{}{
+		"addresses": "0a:00:00:00:00:01 192.168.0.11",
+		"tag":       []interface{}{"set", []interface{}{}},
+		"type":      "",
+	}) {
+		t.Errorf("unexpected row %v", got)
+	}
+
+	if _, err := NewDatabase("OVN_IC_Northbound"); err == nil {
+		t.Errorf("expected an error for a database without a schema")
+	}
+	if err := databases["OVN_Northbound"].InsertWithUUID("Logical_Switch", "x", Row{"color": "blue"}); err == nil {
+		t.Errorf("expected an error for an unknown column")
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topology describes a synthetic OVN deployment: logical switches with
+// the same number of ports, bound to the chassis in turn, and logical
+// routers and load balancers, attached to the switches in turn.
+type Topology struct {
+	Chassis        int
+	Switches       int
+	PortsPerSwitch int
+	Routers        int
+	LoadBalancers  int
+}
+
+// NewTopology returns the Northbound and Southbound databases of a
+// synthetic deployment. The names of the chassis, switches and ports are
+// "chassis-<n>", "switch-<n>" and "switch-<n>-port-<n>", counting from 1.
+// Router "router-<n>" has a port "router-<n>-switch-<n>" to each of its
+// switches and a gateway port "router-<n>-gateway", hosted by the first
+// two chassis, a default route, a policy allowing the traffic between the
+// switches, a SNAT rule and a floating IP. Load balancer "lb-<n>" has a
+// health checked VIP balancing the traffic to the ports of its switch, of
+// which the first one is offline, and all the load balancers are in the
+// group "lb-group".
+func NewTopology(t Topology) (northbound, southbound *Database, err error) {
+	if northbound, err = NewDatabase("OVN_Northbound"); err != nil {
+		return nil, nil, err
+	}
+	if southbound, err = NewDatabase("OVN_Southbound"); err != nil {
+		return nil, nil, err
+	}
+	chassis := []string{}
+	for i := 1; i <= t.Chassis; i++ {
+		name := fmt.Sprintf("chassis-%d", i)
+		encap, err := southbound.Insert("Encap", Row{
+			"type":         "geneve",
+			"ip":           ipAddress(10<<24, i),
+			"chassis_name": name,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		id, err := southbound.Insert("Chassis", Row{
+			"name":     name,
+			"hostname": name,
+			"encaps":   Set(UUID(encap)),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		chassis = append(chassis, id)
+	}
+	switchLBs := make([][]interface{}, t.Switches)
+	lbs := []interface{}{}
+	for i := 1; i <= t.LoadBalancers; i++ {
+		vip := ipAddress(10<<24|96<<16, i) + ":80"
+		backends := []string{}
+		mappings := make(map[string]string)
+		if t.Switches > 0 {
+			sw := (i-1)%t.Switches + 1
+			for j := 1; j <= t.PortsPerSwitch; j++ {
+				ip := ipAddress(100<<24|64<<16, (sw-1)*t.PortsPerSwitch+j)
+				name := fmt.Sprintf("switch-%d-port-%d", sw, j)
+				backends = append(backends, ip+":8080")
+				mappings[ip] = name + ":" + ipAddress(100<<24|64<<16|255<<8, 254)
+				status := "online"
+				if j == 1 {
+					status = "offline"
+				}
+				_, err := southbound.Insert("Service_Monitor", Row{
+					"ip":           ip,
+					"port":         8080,
+					"protocol":     "tcp",
+					"logical_port": name,
+					"status":       status,
+				})
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		healthCheck, err := northbound.Insert("Load_Balancer_Health_Check", Row{
+			"vip": vip,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		id, err := northbound.Insert("Load_Balancer", Row{
+			"name":             fmt.Sprintf("lb-%d", i),
+			"protocol":         "tcp",
+			"vips":             Map(map[string]string{vip: strings.Join(backends, ",")}),
+			"health_check":     Set(UUID(healthCheck)),
+			"ip_port_mappings": Map(mappings),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		lbs = append(lbs, UUID(id))
+		if t.Switches > 0 {
+			switchLBs[(i-1)%t.Switches] = append(switchLBs[(i-1)%t.Switches], UUID(id))
+		}
+	}
+	if len(lbs) > 0 {
+		_, err := northbound.Insert("Load_Balancer_Group", Row{
+			"name":          "lb-group",
+			"load_balancer": Set(lbs...),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	routerPorts := make([][]interface{}, t.Routers)
+	port := 0
+	for i := 1; i <= t.Switches; i++ {
+		switchName := fmt.Sprintf("switch-%d", i)
+		ports := []interface{}{}
+		bindings := []Row{}
+		for j := 1; j <= t.PortsPerSwitch; j++ {
+			port++
+			name := fmt.Sprintf("%s-port-%d", switchName, j)
+			id, err := northbound.Insert("Logical_Switch_Port", Row{
+				"name":      name,
+				"addresses": Set(fmt.Sprintf("%s %s", macAddress(port), ipAddress(100<<24|64<<16, port))),
+				"up":        true,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			ports = append(ports, UUID(id))
+			binding := Row{
+				"logical_port": name,
+				"tunnel_key":   j,
+				"mac":          Set(macAddress(port)),
+			}
+			if len(chassis) > 0 {
+				binding["chassis"] = Set(UUID(chassis[(port-1)%len(chassis)]))
+			}
+			bindings = append(bindings, binding)
+		}
+		id, err := northbound.Insert("Logical_Switch", Row{
+			"name":          switchName,
+			"ports":         Set(ports...),
+			"load_balancer": Set(switchLBs[i-1]...),
+			"external_ids":  Map(map[string]string{"neutron:network_name": switchName}),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		datapath, err := southbound.Insert("Datapath_Binding", Row{
+			"tunnel_key":   i,
+			"external_ids": Map(map[string]string{"logical-switch": id, "name": switchName}),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, binding := range bindings {
+			binding["datapath"] = UUID(datapath)
+			if _, err := southbound.Insert("Port_Binding", binding); err != nil {
+				return nil, nil, err
+			}
+		}
+		if t.Routers == 0 {
+			continue
+		}
+		router := (i - 1) % t.Routers
+		id, err = northbound.Insert("Logical_Router_Port", Row{
+			"name":     fmt.Sprintf("router-%d-%s", router+1, switchName),
+			"mac":      macAddress(1<<24 | i),
+			"networks": Set(ipAddress(172<<24|16<<16, i<<8|1) + "/24"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		routerPorts[router] = append(routerPorts[router], UUID(id))
+	}
+	for i := 1; i <= t.Routers; i++ {
+		routerName := fmt.Sprintf("router-%d", i)
+		gateways := []interface{}{}
+		for j := 0; j < len(chassis) && j < 2; j++ {
+			id, err := northbound.Insert("Gateway_Chassis", Row{
+				"name":         fmt.Sprintf("%s-gateway-chassis-%d", routerName, j+1),
+				"chassis_name": fmt.Sprintf("chassis-%d", j+1),
+				"priority":     20 - 10*j,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			gateways = append(gateways, UUID(id))
+		}
+		id, err := northbound.Insert("Logical_Router_Port", Row{
+			"name":            routerName + "-gateway",
+			"mac":             macAddress(2<<24 | i),
+			"networks":        Set(ipAddress(192<<24|168<<16, i) + "/16"),
+			"gateway_chassis": Set(gateways...),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		route, err := northbound.Insert("Logical_Router_Static_Route", Row{
+			"ip_prefix": "0.0.0.0/0",
+			"nexthop":   "192.168.255.254",
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		policy, err := northbound.Insert("Logical_Router_Policy", Row{
+			"priority": 1000,
+			"match":    "ip4.dst == 172.16.0.0/12",
+			"action":   "allow",
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		snat, err := northbound.Insert("NAT", Row{
+			"type":        "snat",
+			"external_ip": ipAddress(192<<24|168<<16, i),
+			"logical_ip":  "172.16.0.0/12",
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		floatingIP, err := northbound.Insert("NAT", Row{
+			"type":        "dnat_and_snat",
+			"external_ip": ipAddress(192<<24|168<<16|128<<8, i),
+			"logical_ip":  ipAddress(172<<24|16<<16, 1<<8|10),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		id, err = northbound.Insert("Logical_Router", Row{
+			"name":          routerName,
+			"ports":         Set(append(routerPorts[i-1], UUID(id))...),
+			"static_routes": Set(UUID(route)),
+			"policies":      Set(UUID(policy)),
+			"nat":           Set(UUID(snat), UUID(floatingIP)),
+			"external_ids":  Map(map[string]string{"neutron:router_name": routerName}),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = southbound.Insert("Datapath_Binding", Row{
+			"tunnel_key":   t.Switches + i,
+			"external_ids": Map(map[string]string{"logical-router": id, "name": routerName}),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return northbound, southbound, nil
+}
+
+// NewVswitchDatabase returns an Open_vSwitch database describing a
+// system, as the exporter reads it at startup.
+func NewVswitchDatabase(systemID, hostname, runDir string) (*Database, error) {
+	db, err := NewDatabase("Open_vSwitch")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Insert("Open_vSwitch", Row{
+		"ovs_version":    "3.3.0",
+		"db_version":     db.schema.version,
+		"system_type":    "ubuntu",
+		"system_version": "24.04",
+		"external_ids": Map(map[string]string{
+			"system-id": systemID,
+			"hostname":  hostname,
+			"rundir":    runDir,
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// ipAddress returns the n-th IPv4 address after a base address.
+func ipAddress(base uint32, n int) string {
+	ip := base + uint32(n)
+	return fmt.Sprintf("%d.%d.%d.%d", ip>>24, ip>>16&0xff, ip>>8&0xff, ip&0xff)
+}
+
+// macAddress returns the n-th locally administered MAC address.
+func macAddress(n int) string {
+	return fmt.Sprintf("0a:00:%02x:%02x:%02x:%02x", n>>24&0xff, n>>16&0xff, n>>8&0xff, n&0xff)
+}
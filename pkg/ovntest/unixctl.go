@@ -0,0 +1,242 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UnixctlHandler runs a unixctl command and returns its output.
+type UnixctlHandler func(args []string) (string, error)
+
+// UnixctlServer is an in-process control socket of an OVS daemon, as used
+// by ovs-appctl. It answers list-commands and the commands it handles.
+type UnixctlServer struct {
+	*rpcServer
+	mu       sync.RWMutex
+	commands map[string]unixctlCommand
+}
+
+type unixctlCommand struct {
+	usage   string
+	handler UnixctlHandler
+}
+
+// NewUnixctlServer starts a control socket.
+func NewUnixctlServer(path string) (*UnixctlServer, error) {
+	s := &UnixctlServer{commands: make(map[string]unixctlCommand)}
+	var err error
+	s.rpcServer, err = listen(path, s.handle)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Handle registers a command. The command is its name, optionally
+// followed by its usage as listed by list-commands, e.g.
+// "cluster/status DB".
+func (s *UnixctlServer) Handle(command string, handler UnixctlHandler) {
+	name, usage, _ := strings.Cut(command, " ")
+	s.mu.Lock()
+	s.commands[name] = unixctlCommand{usage: usage, handler: handler}
+	s.mu.Unlock()
+}
+
+// HandleOutput registers a command answering with a canned output.
+func (s *UnixctlServer) HandleOutput(command, output string) {
+	s.Handle(command, func([]string) (string, error) {
+		return output, nil
+	})
+}
+
+// Remote returns the address of the control socket, in the format of the
+// OVS tools.
+func (s *UnixctlServer) Remote() string {
+	return "unix:" + s.path
+}
+
+// Close stops the server.
+func (s *UnixctlServer) Close() error {
+	return s.close()
+}
+
+func (s *UnixctlServer) handle(c *rpcConn, m rpcMessage) (interface{}, interface{}) {
+	args := []string{}
+	for _, p := range m.Params {
+		var arg string
+		if err := json.Unmarshal(p, &arg); err != nil {
+			return nil, "command arguments must be strings"
+		}
+		args = append(args, arg)
+	}
+	if m.Method == "list-commands" {
+		return s.listCommands(), nil
+	}
+	s.mu.RLock()
+	command, exists := s.commands[m.Method]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Sprintf("%q is not a valid command (use \"list-commands\" to see a list of valid commands)", m.Method)
+	}
+	output, err := command.handler(args)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return output, nil
+}
+
+func (s *UnixctlServer) listCommands() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lines := []string{"  list-commands"}
+	for name, command := range s.commands {
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("  %-24s %s", name, command.usage), " "))
+	}
+	sort.Strings(lines)
+	return "The available commands are:\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// CoverageCounter is a counter of the output of coverage/show.
+type CoverageCounter struct {
+	Name string
+	// Rates are the average rates per second over the last 5 seconds,
+	// the last minute and the last hour.
+	Rates [3]float64
+	Total int
+}
+
+// CoverageOutput returns the output of coverage/show.
+func CoverageOutput(counters []CoverageCounter) string {
+	var b strings.Builder
+	b.WriteString("Event coverage, avg rate over last: 5 seconds, last minute, last hour,  hash=00000000:\n")
+	for _, c := range counters {
+		fmt.Fprintf(&b, "%-24s %8.1f/sec %10.3f/sec %11.4f/sec   total: %d\n", c.Name, c.Rates[0], c.Rates[1], c.Rates[2], c.Total)
+	}
+	fmt.Fprintf(&b, "%d events never hit\n", 0)
+	return b.String()
+}
+
+// MemoryOutput returns the output of memory/show.
+func MemoryOutput(usage map[string]int) string {
+	facilities := make([]string, 0, len(usage))
+	for facility := range usage {
+		facilities = append(facilities, facility)
+	}
+	sort.Strings(facilities)
+	items := []string{}
+	for _, facility := range facilities {
+		items = append(items, fmt.Sprintf("%s:%d", facility, usage[facility]))
+	}
+	return strings.Join(items, " ") + "\n"
+}
+
+// ClusterStatus is the raft state of a clustered database, as reported by
+// cluster/status.
+type ClusterStatus struct {
+	Database  string
+	ClusterID string
+	ServerID  string
+	Address   string
+	// Role is "leader", "candidate" or "follower".
+	Role string
+	Term uint64
+	// Leader and Vote are "self", the short ID of another server or
+	// "unknown".
+	Leader       string
+	Vote         string
+	LogLow       uint64
+	LogHigh      uint64
+	NotCommitted uint64
+	NotApplied   uint64
+	Servers      []ClusterServer
+}
+
+// ClusterServer is a server of a cluster. The servers other than the one
+// reporting are connected both ways.
+type ClusterServer struct {
+	ID         string
+	Address    string
+	NextIndex  uint64
+	MatchIndex uint64
+}
+
+// ClusterStatusOutput returns the output of cluster/status.
+func ClusterStatusOutput(status ClusterStatus) string {
+	var b strings.Builder
+	sid := shortID(status.ServerID)
+	fmt.Fprintf(&b, "%s\n", sid)
+	fmt.Fprintf(&b, "Name: %s\n", status.Database)
+	fmt.Fprintf(&b, "Cluster ID: %s (%s)\n", shortID(status.ClusterID), status.ClusterID)
+	fmt.Fprintf(&b, "Server ID: %s (%s)\n", sid, status.ServerID)
+	fmt.Fprintf(&b, "Address: %s\n", status.Address)
+	fmt.Fprintf(&b, "Status: cluster member\n")
+	fmt.Fprintf(&b, "Role: %s\n", status.Role)
+	fmt.Fprintf(&b, "Term: %d\n", status.Term)
+	fmt.Fprintf(&b, "Leader: %s\n", status.Leader)
+	fmt.Fprintf(&b, "Vote: %s\n", status.Vote)
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "Log: [%d, %d]\n", status.LogLow, status.LogHigh)
+	fmt.Fprintf(&b, "Entries not yet committed: %d\n", status.NotCommitted)
+	fmt.Fprintf(&b, "Entries not yet applied: %d\n", status.NotApplied)
+	connections := []string{}
+	for _, server := range status.Servers {
+		if server.ID == status.ServerID {
+			continue
+		}
+		connections = append(connections, "->"+shortID(server.ID), "<-"+shortID(server.ID))
+	}
+	fmt.Fprintf(&b, "Connections: %s\n", strings.Join(connections, " "))
+	fmt.Fprintf(&b, "Disconnections: 0\n")
+	fmt.Fprintf(&b, "Servers:\n")
+	for _, server := range status.Servers {
+		self := ""
+		if server.ID == status.ServerID {
+			self = " (self)"
+		}
+		fmt.Fprintf(&b, "    %s (%s at %s)%s next_index=%d match_index=%d\n",
+			shortID(server.ID), shortID(server.ID), server.Address, self, server.NextIndex, server.MatchIndex)
+	}
+	return b.String()
+}
+
+// ClusterStatusHandler returns the handler of cluster/status for the
+// given databases.
+func ClusterStatusHandler(statuses ...ClusterStatus) UnixctlHandler {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("\"cluster/status\" command requires at least 1 arguments")
+		}
+		for _, status := range statuses {
+			if status.Database == args[0] {
+				return ClusterStatusOutput(status), nil
+			}
+		}
+		return "", fmt.Errorf("%s: unknown database", args[0])
+	}
+}
+
+// shortID returns the first four characters of a UUID, as the OVS tools
+// show them.
+func shortID(id string) string {
+	if len(id) < 4 {
+		return id
+	}
+	return id[:4]
+}
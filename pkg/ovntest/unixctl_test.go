@@ -0,0 +1,83 @@
+// Copyright 2018 Paul Greenberg (greenpau@outlook.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovntest
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnixctlServer(t *testing.T) {
+	s, err := NewUnixctlServer(filepath.Join(t.TempDir(), "app.ctl"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %q", err)
+	}
+	defer s.Close()
+	s.HandleOutput("memory/show", MemoryOutput(map[string]int{"cells": 100, "monitors": 2}))
+	s.Handle("cluster/status DB", ClusterStatusHandler(ClusterStatus{
+		Database:  "OVN_Southbound",
+		ClusterID: "4c2c8e4a-7a0b-4f3c-9d7e-0e1f2a3b4c5d",
+		ServerID:  "9f1e2d3c-4b5a-4978-8695-a4b3c2d1e0f9",
+		Role:      "leader",
+		Leader:    "self",
+		Vote:      "self",
+	}))
+	s.Handle("vlog/set", func(args []string) (string, error) {
+		return "", errors.New("not allowed")
+	})
+	c := newRPCClient(t, s.path)
+
+	for _, tc := range []struct {
+		method   string
+		params   []interface{}
+		contains string
+		err      string
+	}{
+		{method: "list-commands", contains: "  cluster/status           DB\n"},
+		{method: "memory/show", contains: "cells:100 monitors:2\n"},
+		{method: "cluster/status", params: []interface{}{"OVN_Southbound"}, contains: "Server ID: 9f1e (9f1e2d3c-4b5a-4978-8695-a4b3c2d1e0f9)\n"},
+		{method: "cluster/status", params: []interface{}{"OVN_Northbound"}, err: "OVN_Northbound: unknown database"},
+		{method: "vlog/set", err: "not allowed"},
+		{method: "coverage/show", err: `"coverage/show" is not a valid command`},
+	} {
+		resp := c.call(tc.method, tc.params...)
+		if tc.err != "" {
+			if msg, _ := resp["error"].(string); !strings.HasPrefix(msg, tc.err) {
+				t.Errorf("%s: expected error %q, but got %v", tc.method, tc.err, resp)
+			}
+			continue
+		}
+		if output, _ := resp["result"].(string); !strings.Contains(output, tc.contains) {
+			t.Errorf("%s: expected output containing %q, but got %v", tc.method, tc.contains, resp)
+		}
+	}
+}
+
+func TestCoverageOutput(t *testing.T) {
+	output := CoverageOutput([]CoverageCounter{
+		{Name: "txn_unchanged", Rates: [3]float64{0.2, 0.1, 0.05}, Total: 42},
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header, a counter and a footer, but got %q", output)
+	}
+	fields := strings.Fields(lines[1])
+	want := []string{"txn_unchanged", "0.2/sec", "0.100/sec", "0.0500/sec", "total:", "42"}
+	if strings.Join(fields, " ") != strings.Join(want, " ") {
+		t.Errorf("expected fields %v, but got %v", want, fields)
+	}
+}